@@ -0,0 +1,204 @@
+/*
+Copyright © 2020 Michael Rubin <mhr@neverthere.org>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"fmt"
+
+	exifsort "github.com/matchstick/exifsort/lib"
+	"github.com/spf13/cobra"
+)
+
+func catalogLongHelp() string {
+	return `Keep and query the catalog database of a sorted directory.
+
+	exifsort catalog update <dir>
+	exifsort catalog has <dir> <file or dir>...
+
+	dir
+	sorted directory whose catalog, .exifsort.db, to use
+
+	update creates the catalog of dir if it has none and brings it up to
+	date: media that is new or changed is added and media that is gone is
+	forgotten. sort, resort, merge and verify --fix keep it up to date after
+	that.
+
+	has tells which media of the files and directories given dir already
+	has, whatever its name or directory, by looking up its SHA-256. It exits
+	with 3 when some of it is not in dir.
+	`
+}
+
+func catalogUpdateSummary(update exifsort.CatalogUpdate, entries int) {
+	fmt.Printf("## Unchanged: %d\n", update.Unchanged)
+
+	lists := []struct {
+		name  string
+		paths []string
+	}{
+		{"Added", update.Added},
+		{"Removed", update.Removed},
+	}
+
+	for _, list := range lists {
+		fmt.Printf("## %s: %d\n", list.name, len(list.paths))
+
+		for _, path := range sortedPaths(list.paths) {
+			fmt.Printf("##\t%s\n", path)
+		}
+	}
+
+	if len(update.Errors) != 0 {
+		fmt.Println("## Errors were:")
+
+		for path, err := range update.Errors {
+			fmt.Printf("##\t%s: (%s)\n", path, err)
+		}
+	}
+
+	fmt.Printf("## Entries: %d\n", entries)
+}
+
+func catalogHasSummary(lookup exifsort.CatalogLookup) {
+	fmt.Printf("## Archived: %d\n", len(lookup.Archived))
+
+	for _, archived := range transfers(lookup.Archived) {
+		fmt.Printf("##\t%s => %s\n", archived.Src, archived.Dst)
+	}
+
+	fmt.Printf("## Not archived: %d\n", len(lookup.NotArchived))
+
+	for _, path := range sortedPaths(lookup.NotArchived) {
+		fmt.Printf("##\t%s\n", path)
+	}
+
+	if len(lookup.Errors) != 0 {
+		fmt.Println("## Errors were:")
+
+		for path, err := range lookup.Errors {
+			fmt.Printf("##\t%s: (%s)\n", path, err)
+		}
+	}
+}
+
+// catalogUpdate brings the catalog of root up to date and returns what it did
+// and how many entries it has now.
+func catalogUpdate(opts globalOptions, root string) (exifsort.CatalogUpdate, int, error) {
+	c, err := exifsort.OpenCatalog(root)
+	if err != nil {
+		return exifsort.CatalogUpdate{}, 0, err
+	}
+
+	observer, finish := stageObserver(opts, "Cataloging",
+		func() int { return countFiles(root) })
+	update, err := c.Update(opts.exts, opts.rules, observer)
+
+	finish()
+
+	var entries []exifsort.CatalogEntry
+	if err == nil {
+		entries, err = c.Entries()
+	}
+
+	return update, len(entries), closeCatalog(c, err)
+}
+
+func catalogUpdateExecute(cmd *cobra.Command, root string) int {
+	opts := getGlobalOptions(cmd)
+	r := &report{Command: "catalog"}
+
+	update, entries, err := catalogUpdate(opts, root)
+	if err != nil {
+		printError(opts, r, err, func() { fmt.Printf("%s\n", err.Error()) })
+		return exitFatal
+	}
+
+	r.Catalog = newCatalogUpdateReport(root, update, entries)
+
+	emitReport(opts, r, func() { catalogUpdateSummary(update, entries) })
+
+	return perFileStatus(len(update.Errors))
+}
+
+func catalogHasExecute(cmd *cobra.Command, root string, paths []string) int {
+	opts := getGlobalOptions(cmd)
+	r := &report{Command: "catalog"}
+
+	var err error
+	if !exifsort.CatalogExists(root) {
+		err = fmt.Errorf("%s has no catalog", root)
+	}
+
+	var c *exifsort.Catalog
+	if err == nil {
+		c, err = exifsort.OpenCatalog(root)
+	}
+
+	if err != nil {
+		printError(opts, r, err, func() { fmt.Printf("%s\n", err.Error()) })
+		return exitInvalid
+	}
+
+	lookup, err := c.Lookup(paths, opts.exts, opts.rules)
+
+	err = closeCatalog(c, err)
+	if err != nil {
+		printError(opts, r, err, func() { fmt.Printf("%s\n", err.Error()) })
+		return exitFatal
+	}
+
+	r.Catalog = newCatalogHasReport(root, lookup)
+
+	emitReport(opts, r, func() { catalogHasSummary(lookup) })
+
+	return perFileStatus(len(lookup.NotArchived) + len(lookup.Errors))
+}
+
+func newCatalogCmd() *cobra.Command {
+	const (
+		numUpdateCmdArgs = 1
+		numHasCmdArgs    = 2
+	)
+
+	catalogCmd := &cobra.Command{
+		Use:   "catalog",
+		Short: "Keep and query the catalog database of a sorted directory",
+		Long:  catalogLongHelp(),
+	}
+
+	catalogCmd.AddCommand(&cobra.Command{
+		Use:   "update",
+		Short: "Create the catalog of a sorted directory or bring it up to date",
+		Long:  catalogLongHelp(),
+		Args:  cobra.ExactArgs(numUpdateCmdArgs),
+		RunE: runStatus(func(cmd *cobra.Command, args []string) int {
+			return catalogUpdateExecute(cmd, args[0])
+		}),
+	})
+
+	catalogCmd.AddCommand(&cobra.Command{
+		Use:   "has",
+		Short: "Tell which media a sorted directory already has",
+		Long:  catalogLongHelp(),
+		Args:  cobra.MinimumNArgs(numHasCmdArgs),
+		RunE: runStatus(func(cmd *cobra.Command, args []string) int {
+			return catalogHasExecute(cmd, args[0], args[1:])
+		}),
+	})
+
+	return catalogCmd
+}
@@ -17,7 +17,13 @@ limitations under the License.
 package cmd
 
 import (
+	"errors"
+	"fmt"
+	"os"
+
+	exifsort "github.com/matchstick/exifsort/lib"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 )
 
 type cmdStringFlag struct {
@@ -36,3 +42,334 @@ func setStringFlags(cmd *cobra.Command, flags []cmdStringFlag) {
 		}
 	}
 }
+
+// globalOptions are set by the persistent flags of the root command.
+type globalOptions struct {
+	progress bool
+	strict   bool
+	output   string
+	timezone string
+	exts     exifsort.Extensions
+	rules    *exifsort.Rules
+}
+
+func setGlobalFlags(cmd *cobra.Command) {
+	cmd.PersistentFlags().Bool("progress", false,
+		"display progress with an ETA instead of logging every file.")
+	cmd.PersistentFlags().StringP("output", "o", outputText,
+		"output format: text, json or ndjson (json per file event).")
+	cmd.PersistentFlags().Bool("strict", false,
+		"exit with an error when any file has exif or index errors.")
+	cmd.PersistentFlags().String(configFlag, "",
+		"config file (default is $HOME/.exifsort).")
+	cmd.PersistentFlags().String(configProfile, "",
+		"profile of the config file to take settings from.")
+	cmd.PersistentFlags().String("timezone", "",
+		"IANA timezone exif times are in, such as Europe/Lisbon (default is local).")
+	cmd.PersistentFlags().StringSlice("ext", nil,
+		"set how an extension is handled as <ext>=<category>, such as .heic=exif.\n"+
+			"categories: exif, movie-metadata, modtime-only, sidecar, skip.")
+	cmd.PersistentFlags().StringSlice("exclude", nil,
+		"leave out files and directories matching a .gitignore style pattern.")
+	cmd.PersistentFlags().StringSlice("include", nil,
+		"include again what --exclude or a preset left out.")
+	cmd.PersistentFlags().StringSlice("preset", []string{exifsort.PresetSynology},
+		"exclude presets: synology, macos, windows, photos.")
+}
+
+// getRules returns the rules of the --preset, --exclude and --include flags.
+func getRules(cmd *cobra.Command) (*exifsort.Rules, error) {
+	var rules exifsort.Rules
+
+	presets, _ := cmd.Flags().GetStringSlice("preset")
+	for _, preset := range presets {
+		err := rules.AddPreset(preset)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	excludes, _ := cmd.Flags().GetStringSlice("exclude")
+	for _, pattern := range excludes {
+		err := rules.Exclude(pattern)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	includes, _ := cmd.Flags().GetStringSlice("include")
+	for _, pattern := range includes {
+		err := rules.Include(pattern)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &rules, nil
+}
+
+// getExtensions returns the default extensions changed by the --ext flags.
+func getExtensions(cmd *cobra.Command) (exifsort.Extensions, error) {
+	exts := exifsort.NewExtensions()
+
+	specs, _ := cmd.Flags().GetStringSlice("ext")
+	for _, spec := range specs {
+		err := exts.Parse(spec)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return exts, nil
+}
+
+func getGlobalOptions(cmd *cobra.Command) globalOptions {
+	var opts globalOptions
+
+	opts.progress, _ = cmd.Flags().GetBool("progress")
+	opts.strict, _ = cmd.Flags().GetBool("strict")
+	opts.output, _ = cmd.Flags().GetString("output")
+	opts.timezone, _ = cmd.Flags().GetString("timezone")
+	// preRun already made sure they parse.
+	opts.exts, _ = getExtensions(cmd)
+	opts.rules, _ = getRules(cmd)
+
+	return opts
+}
+
+// preRun reads the config and validates the global flags before any command
+// runs.
+func preRun(cmd *cobra.Command, args []string) error {
+	// The arguments parsed so usage is no help from here.
+	cmd.SilenceUsage = true
+
+	err := initConfig(cmd)
+	if err != nil {
+		return err
+	}
+
+	err = applyConfig(cmd)
+	if err != nil {
+		return err
+	}
+
+	_, err = getExtensions(cmd)
+	if err != nil {
+		return err
+	}
+
+	_, err = getRules(cmd)
+	if err != nil {
+		return err
+	}
+
+	opts := getGlobalOptions(cmd)
+
+	err = outputValid(opts.output)
+	if err != nil {
+		return err
+	}
+
+	return applyTimezone(opts.timezone)
+}
+
+const defaultJobs = 1
+
+func setJobsFlag(flags *pflag.FlagSet) {
+	flags.Int("jobs", defaultJobs, "number of files to scan at the same time.")
+}
+
+func setSniffFlag(flags *pflag.FlagSet) {
+	flags.Bool("sniff", false,
+		"tell file types by their contents and report misnamed files.")
+}
+
+func setCameraFlag(flags *pflag.FlagSet) {
+	flags.StringSlice("camera", nil,
+		"only media from cameras whose make and model contain this, or \"unknown\".")
+}
+
+func setNearFlag(flags *pflag.FlagSet) {
+	flags.String("near", "",
+		"only media taken within <lat>,<lon>,<radius km>, such as 38.72,-9.14,25.")
+}
+
+func getNear(flags *pflag.FlagSet) (exifsort.Near, error) {
+	str, _ := flags.GetString("near")
+	if str == "" {
+		return exifsort.Near{}, nil
+	}
+
+	return exifsort.ParseNear(str)
+}
+
+func setVerifyFlag(flags *pflag.FlagSet) {
+	flags.Bool("verify", false,
+		"read every file back after transferring it and compare its SHA-256.")
+}
+
+func setPolicyFlags(flags *pflag.FlagSet) {
+	flags.String("collision", exifsort.CollisionRename.String(),
+		"when a name is taken: \"rename\", \"skip\", \"newer\" or \"fail\".")
+	flags.String("duplicates", exifsort.DuplicateAuto.String(),
+		"duplicates in src: \"auto\", \"delete\", \"keep\" or \"move\".")
+	flags.String("duplicates-dir", "",
+		"directory --duplicates move moves duplicates to.")
+	flags.String("naming", exifsort.NamingCounter.String(),
+		"names of renamed files: \"counter\" or \"hash\" of their contents.")
+}
+
+// policies is what we do with name collisions and duplicates.
+type policies struct {
+	collision    exifsort.Collision
+	duplicate    exifsort.Duplicate
+	duplicateDir string
+	naming       exifsort.Naming
+}
+
+func getPolicies(flags *pflag.FlagSet) (policies, error) {
+	var p policies
+
+	var err error
+
+	collisionStr, _ := flags.GetString("collision")
+
+	p.collision, err = exifsort.CollisionParse(collisionStr)
+	if err != nil {
+		return p, err
+	}
+
+	duplicateStr, _ := flags.GetString("duplicates")
+
+	p.duplicate, err = exifsort.DuplicateParse(duplicateStr)
+	if err != nil {
+		return p, err
+	}
+
+	namingStr, _ := flags.GetString("naming")
+
+	p.naming, err = exifsort.NamingParse(namingStr)
+	if err != nil {
+		return p, err
+	}
+
+	p.duplicateDir, _ = flags.GetString("duplicates-dir")
+	if p.duplicate == exifsort.DuplicateMove && p.duplicateDir == "" {
+		return p, errors.New("--duplicates move needs --duplicates-dir")
+	}
+
+	return p, nil
+}
+
+// policySummary prints the policies and what they did.
+func policySummary(p policies, collisions int, replaced int, duplicates int) {
+	fmt.Printf("## Collision policy: %s\n", p.collision)
+	fmt.Printf("## Duplicate policy: %s\n", p.duplicate)
+	fmt.Printf("## Naming: %s\n", p.naming)
+
+	if collisions != 0 {
+		fmt.Printf("## Skipped collisions: %d\n", collisions)
+	}
+
+	if replaced != 0 {
+		fmt.Printf("## Replaced older files: %d\n", replaced)
+	}
+
+	if duplicates != 0 {
+		fmt.Printf("## Duplicates: %d\n", duplicates)
+	}
+}
+
+func setManifestFlag(flags *pflag.FlagSet) {
+	flags.Bool("manifest", false,
+		"keep a SHA-256 manifest of dst for fsck, always done when dst has one.")
+}
+
+// getManifest returns the manifest of root when we are asked to keep one or
+// root already has one, otherwise nil.
+func getManifest(flags *pflag.FlagSet, root string) (*exifsort.Manifest, error) {
+	keep, _ := flags.GetBool("manifest")
+	if !keep && !exifsort.ManifestExists(root) {
+		return nil, nil
+	}
+
+	return exifsort.LoadManifest(root)
+}
+
+func setCatalogFlag(flags *pflag.FlagSet) {
+	flags.Bool("catalog", false,
+		"keep a catalog database of dst, always done when dst has one.")
+}
+
+// openCatalog opens the catalog of root when we are asked to keep one or
+// root already has one, otherwise it returns nil.
+func openCatalog(keep bool, root string) (*exifsort.Catalog, error) {
+	if !keep && !exifsort.CatalogExists(root) {
+		return nil, nil
+	}
+
+	return exifsort.OpenCatalog(root)
+}
+
+// closeCatalog closes c if there is one and returns err, or the error
+// closing it.
+func closeCatalog(c *exifsort.Catalog, err error) error {
+	if c == nil {
+		return err
+	}
+
+	closeErr := c.Close()
+	if err == nil {
+		err = closeErr
+	}
+
+	return err
+}
+
+func setDateRangeFlags(flags *pflag.FlagSet) {
+	flags.String("after", "",
+		"only media from this date on: YYYY, YYYY-MM or YYYY-MM-DD.")
+	flags.String("before", "",
+		"only media from before this date: YYYY, YYYY-MM or YYYY-MM-DD.")
+	flags.String("on", "",
+		"only media from this year, month or day, such as 2020-04.")
+}
+
+func getDateRange(flags *pflag.FlagSet) (exifsort.DateRange, error) {
+	after, _ := flags.GetString("after")
+	before, _ := flags.GetString("before")
+	on, _ := flags.GetString("on")
+
+	return exifsort.ParseDateRange(after, before, on)
+}
+
+// loadScan scans input when it is a directory, such as a sorted one, or loads
+// it when it is a json file from scan.
+func loadScan(opts globalOptions, input string, jobs int) (exifsort.Scanner, error) {
+	scanner := exifsort.NewScanner()
+	scanner.Jobs = jobs
+	scanner.Extensions = opts.exts
+	scanner.Rules = opts.rules
+
+	info, err := os.Stat(input)
+	if err != nil {
+		return scanner, err
+	}
+
+	if !info.IsDir() {
+		return scanner, scanner.Load(input)
+	}
+
+	// What we print is read by scripts so the scan only shows progress, on
+	// stderr.
+	if !opts.progress {
+		return scanner, scanner.ScanDir(input, exifsort.NopObserver{})
+	}
+
+	p := newProgress(os.Stderr, "Scanning", countFiles(input))
+	err = scanner.ScanDir(input, p)
+
+	p.Finish()
+
+	return scanner, err
+}
@@ -0,0 +1,216 @@
+/*
+Copyright © 2020 Michael Rubin <mhr@neverthere.org>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/mitchellh/go-homedir"
+	"github.com/spf13/cast"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// Every flag can be given a value outside of the command line. In order of
+// precedence a flag not set on the command line is taken from:
+//
+// 1. The environment variable EXIFSORT_<FLAG>, with '-' replaced by '_'.
+// 2. The "profiles.<profile>.<flag>" key of the config file.
+// 3. The "<flag>" key of the config file.
+//
+// The config file is ~/.exifsort (YAML), or ~/.exifsort.<ext> for any format
+// viper reads, unless --config names one.
+
+const (
+	envPrefix     = "EXIFSORT"
+	configName    = ".exifsort"
+	configType    = "yaml"
+	configProfile = "profile"
+	configFlag    = "config"
+	profilesKey   = "profiles"
+)
+
+func envName(flagName string) string {
+	name := strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+	return envPrefix + "_" + name
+}
+
+// configFile finds the config file to read. An empty string means there is
+// none.
+func configFile(cfgFile string) (string, error) {
+	if cfgFile != "" {
+		return cfgFile, nil
+	}
+
+	home, err := homedir.Dir()
+	if err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(home, configName)
+	if _, err := os.Stat(path); err == nil {
+		return path, nil
+	}
+
+	for _, ext := range viper.SupportedExts {
+		path := filepath.Join(home, configName+"."+ext)
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+	}
+
+	return "", nil
+}
+
+func configExtKnown(path string) bool {
+	ext := strings.TrimPrefix(filepath.Ext(path), ".")
+
+	for _, known := range viper.SupportedExts {
+		if ext == known {
+			return true
+		}
+	}
+
+	return false
+}
+
+// initConfig reads in the config file if there is one.
+func initConfig(cmd *cobra.Command) error {
+	cfgFile, _ := cmd.Flags().GetString(configFlag)
+	if env, ok := os.LookupEnv(envName(configFlag)); ok && cfgFile == "" {
+		cfgFile = env
+	}
+
+	path, err := configFile(cfgFile)
+	if err != nil || path == "" {
+		return err
+	}
+
+	viper.SetConfigFile(path)
+
+	// Files without a known extension, like ~/.exifsort, are YAML.
+	if !configExtKnown(path) {
+		viper.SetConfigType(configType)
+	}
+
+	err = viper.ReadInConfig()
+	if err != nil {
+		return fmt.Errorf("config file %s: %s", path, err.Error())
+	}
+
+	return nil
+}
+
+// configString converts a config value to what a flag accepts. Lists become
+// comma separated.
+func configString(val interface{}) string {
+	list, ok := val.([]interface{})
+	if !ok {
+		return cast.ToString(val)
+	}
+
+	strs := make([]string, 0, len(list))
+	for _, item := range list {
+		strs = append(strs, cast.ToString(item))
+	}
+
+	return strings.Join(strs, ",")
+}
+
+func configLookup(profile string, name string) (string, bool) {
+	if val, ok := os.LookupEnv(envName(name)); ok {
+		return val, true
+	}
+
+	keys := []string{name}
+	if profile != "" {
+		keys = []string{profilesKey + "." + profile + "." + name, name}
+	}
+
+	for _, key := range keys {
+		if viper.IsSet(key) {
+			return configString(viper.Get(key)), true
+		}
+	}
+
+	return "", false
+}
+
+func configSetFlag(flags *pflag.FlagSet, profile string, f *pflag.Flag) error {
+	if f.Changed || f.Name == configFlag {
+		return nil
+	}
+
+	val, ok := configLookup(profile, f.Name)
+	if !ok {
+		return nil
+	}
+
+	err := flags.Set(f.Name, val)
+	if err != nil {
+		return fmt.Errorf("config %s: %s", f.Name, err.Error())
+	}
+
+	return nil
+}
+
+// applyConfig sets every flag of cmd that was not given on the command line
+// from the environment or config file.
+func applyConfig(cmd *cobra.Command) error {
+	flags := cmd.Flags()
+
+	// The profile decides where other flags come from so it goes first.
+	err := configSetFlag(flags, "", flags.Lookup(configProfile))
+	if err != nil {
+		return err
+	}
+
+	profile, _ := flags.GetString(configProfile)
+	if profile != "" && !viper.IsSet(profilesKey+"."+profile) {
+		return fmt.Errorf("unknown profile %s", profile)
+	}
+
+	flags.VisitAll(func(f *pflag.Flag) {
+		if err == nil {
+			err = configSetFlag(flags, profile, f)
+		}
+	})
+
+	return err
+}
+
+// applyTimezone makes the timezone option the local time used to interpret
+// exif times and to name sorted directories.
+func applyTimezone(name string) error {
+	if name == "" {
+		return nil
+	}
+
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return err
+	}
+
+	time.Local = loc
+
+	return nil
+}
@@ -0,0 +1,259 @@
+/*
+Copyright © 2020 Michael Rubin <mhr@neverthere.org>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"fmt"
+
+	exifsort "github.com/matchstick/exifsort/lib"
+	"github.com/spf13/cobra"
+)
+
+func dedupeLongHelp() string {
+	return `Find media with the same contents in one or more directories.
+
+	exifsort dedupe <dir>... [--keep <keep>] [--prefer <dir>] [--action <action>] [--trash <dir>]
+
+	dir
+	directories to look for duplicates in, names and directories don't matter
+
+	Files are compared by size and then SHA-256. One file of every group of
+	duplicates is kept, --keep chooses which:
+
+	oldest     the file with the oldest modtime
+	shortest   the file with the shortest path
+	preferred  a file inside the directory given with --prefer
+
+	Ties go to the oldest file. --action chooses what is done with the others:
+
+	report     nothing, they are only reported
+	delete     they are removed
+	hardlink   they are replaced by a hard link to the file kept
+	trash      they are moved below the directory given with --trash
+
+	The manifests and catalogs of the directories are kept up to date.
+	`
+}
+
+func dedupeSummary(d *exifsort.Deduper, action exifsort.DedupeAction) {
+	fmt.Printf("## Duplicate groups: %d\n", len(d.Groups))
+
+	for _, group := range d.Groups {
+		fmt.Printf("##\t%s\n", group.Paths[0])
+
+		for _, path := range group.Paths[1:] {
+			fmt.Printf("##\t\t%s\n", path)
+		}
+	}
+
+	fmt.Printf("## Wasted bytes: %d\n", d.Wasted())
+
+	if action != exifsort.DedupeReport {
+		fmt.Printf("## Duplicates %s: %d\n", action, len(d.Done))
+	}
+
+	if len(d.Errors) != 0 {
+		fmt.Println("## Errors were:")
+
+		for path, err := range d.Errors {
+			fmt.Printf("##\t%s: (%s)\n", path, err)
+		}
+	}
+}
+
+// dedupeParse reads the flags of the dedupe command.
+func dedupeParse(cmd *cobra.Command, d *exifsort.Deduper) (exifsort.DedupeAction, error) {
+	actionStr, _ := cmd.Flags().GetString("action")
+
+	action, err := exifsort.DedupeActionParse(actionStr)
+	if err != nil {
+		return action, err
+	}
+
+	keepStr, _ := cmd.Flags().GetString("keep")
+
+	d.Keep, err = exifsort.KeepParse(keepStr)
+	if err != nil {
+		return action, err
+	}
+
+	d.Preferred, _ = cmd.Flags().GetString("prefer")
+	if d.Keep == exifsort.KeepPreferred && d.Preferred == "" {
+		return action, fmt.Errorf("keep %s needs --prefer", d.Keep)
+	}
+
+	d.Trash, _ = cmd.Flags().GetString("trash")
+	if action == exifsort.DedupeTrash && d.Trash == "" {
+		return action, fmt.Errorf("action %s needs --trash", action)
+	}
+
+	return action, nil
+}
+
+// dedupeManifests forgets the duplicates that are gone from the manifests of
+// roots. Hard links have the same contents so they stay.
+func dedupeManifests(d *exifsort.Deduper, roots []string,
+	action exifsort.DedupeAction) error {
+	if action != exifsort.DedupeDelete && action != exifsort.DedupeTrash {
+		return nil
+	}
+
+	for _, root := range roots {
+		if !exifsort.ManifestExists(root) {
+			continue
+		}
+
+		m, err := exifsort.LoadManifest(root)
+		if err != nil {
+			return err
+		}
+
+		for path := range d.Done {
+			m.Remove(path)
+		}
+
+		err = m.Save()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// dedupeCatalogs forgets the duplicates that are gone from the catalogs of
+// roots.
+func dedupeCatalogs(d *exifsort.Deduper, roots []string,
+	action exifsort.DedupeAction) error {
+	if action != exifsort.DedupeDelete && action != exifsort.DedupeTrash {
+		return nil
+	}
+
+	done := doneDuplicates(d)
+
+	for _, root := range roots {
+		if !exifsort.CatalogExists(root) {
+			continue
+		}
+
+		c, err := exifsort.OpenCatalog(root)
+		if err != nil {
+			return err
+		}
+
+		err = closeCatalog(c, c.Forget(done))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func numDuplicates(d *exifsort.Deduper) int {
+	total := 0
+
+	for _, group := range d.Groups {
+		total += len(group.Paths) - 1
+	}
+
+	return total
+}
+
+func countAllFiles(roots []string) int {
+	total := 0
+
+	for _, root := range roots {
+		total += countFiles(root)
+	}
+
+	return total
+}
+
+func dedupeExecute(cmd *cobra.Command, roots []string) int {
+	opts := getGlobalOptions(cmd)
+
+	d := exifsort.NewDeduper(roots)
+	d.Extensions = opts.exts
+	d.Rules = opts.rules
+
+	action, err := dedupeParse(cmd, d)
+	if err != nil {
+		return printInvalid(opts, "dedupe", err)
+	}
+
+	observer, finish := stageObserver(opts, "Finding",
+		func() int { return countAllFiles(roots) })
+	err = d.Find(observer)
+
+	finish()
+
+	r := &report{Command: "dedupe"}
+
+	if err != nil {
+		printError(opts, r, err, func() { fmt.Printf("%s\n", err.Error()) })
+		return exitInvalid
+	}
+
+	observer, finish = stageObserver(opts, "Deduping",
+		func() int { return numDuplicates(d) })
+	err = d.Apply(action, observer)
+
+	finish()
+
+	if err == nil {
+		err = dedupeManifests(d, roots, action)
+	}
+
+	if err == nil {
+		err = dedupeCatalogs(d, roots, action)
+	}
+
+	r.Dedupe = newDedupeReport(d, action)
+
+	if err != nil {
+		printError(opts, r, err, func() { fmt.Printf("%s\n", err.Error()) })
+		return exitFatal
+	}
+
+	emitReport(opts, r, func() { dedupeSummary(d, action) })
+
+	return perFileStatus(len(d.Errors))
+}
+
+func newDedupeCmd() *cobra.Command {
+	dedupeCmd := &cobra.Command{
+		Use:   "dedupe",
+		Short: "Find media with the same contents in one or more directories",
+		Long:  dedupeLongHelp(),
+		Args:  cobra.MinimumNArgs(1),
+		RunE: runStatus(func(cmd *cobra.Command, args []string) int {
+			return dedupeExecute(cmd, args)
+		}),
+	}
+
+	dedupeCmd.Flags().String("keep", exifsort.KeepOldest.String(),
+		"file of a group to keep: \"oldest\", \"shortest\" or \"preferred\".")
+	dedupeCmd.Flags().String("prefer", "",
+		"directory whose files are kept with --keep preferred.")
+	dedupeCmd.Flags().String("action", exifsort.DedupeReport.String(),
+		"what to do with duplicates: \"report\", \"delete\", \"hardlink\" or \"trash\".")
+	dedupeCmd.Flags().String("trash", "",
+		"directory to move duplicates to with --action trash.")
+
+	return dedupeCmd
+}
@@ -50,22 +50,31 @@ func newEvalCmd() *cobra.Command {
 	files
 	file list (expanded by shell) that will have their exifDate reported`,
 		Args: cobra.MinimumNArgs(numEvalArgs),
-		Run: func(cmd *cobra.Command, args []string) {
+		RunE: runStatus(func(cmd *cobra.Command, args []string) int {
+			opts := getGlobalOptions(cmd)
+			numErrors := 0
 			for _, path := range args {
 				err := fileReadable(path)
 				if err != nil {
 					fmt.Printf("%s, %q\n", path, err)
+					numErrors++
 					continue
 				}
 				s := exifsort.NewScanner()
 				timeStr, err := s.ScanFile(path)
 				if err != nil {
 					fmt.Printf("%s, %s\n", path, err)
+					numErrors++
 					continue
 				}
+				if opts.strict {
+					numErrors += len(s.ExifErrors)
+				}
 				fmt.Printf("%s, %s\n", path, timeStr)
 			}
-		},
+
+			return perFileStatus(numErrors)
+		}),
 	}
 
 	return evalCmd
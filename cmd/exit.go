@@ -0,0 +1,147 @@
+/*
+Copyright © 2020 Michael Rubin <mhr@neverthere.org>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	exifsort "github.com/matchstick/exifsort/lib"
+	"github.com/spf13/cobra"
+)
+
+// Exit codes of the exifsort process.
+const (
+	// exitSuccess : every file was handled without error.
+	exitSuccess = 0
+	// exitFatal : the command was aborted before it finished.
+	exitFatal = 1
+	// exitInvalid : the arguments or input are not valid, nothing was done.
+	exitInvalid = 2
+	// exitPartial : the command finished but some files had errors.
+	exitPartial = 3
+)
+
+// exitStatus is returned by commands to tell Execute which code to exit with.
+// The command has already reported why.
+type exitStatus int
+
+func (e exitStatus) Error() string {
+	return fmt.Sprintf("exit status %d", int(e))
+}
+
+type statusFunc func(cmd *cobra.Command, args []string) int
+
+// runStatus adapts a command that returns an exit code to cobra.
+func runStatus(run statusFunc) func(cmd *cobra.Command, args []string) error {
+	return func(cmd *cobra.Command, args []string) error {
+		// The arguments parsed so usage is no help from here.
+		cmd.SilenceUsage = true
+
+		code := run(cmd, args)
+		if code == exitSuccess {
+			return nil
+		}
+
+		return exitStatus(code)
+	}
+}
+
+// exitCode returns the code Execute should exit with for an error returned
+// by cobra.
+func exitCode(err error) int {
+	var status exitStatus
+
+	switch {
+	case err == nil:
+		return exitSuccess
+	case errors.As(err, &status):
+		return int(status)
+	default:
+		// cobra only fails on its own when the command line is wrong.
+		return exitInvalid
+	}
+}
+
+func perFileStatus(numErrors int) int {
+	if numErrors != 0 {
+		return exitPartial
+	}
+
+	return exitSuccess
+}
+
+func scanStatus(opts globalOptions, s *exifsort.Scanner) int {
+	numErrors := len(s.ScanErrors)
+
+	if opts.strict {
+		numErrors += len(s.ExifErrors)
+	}
+
+	return perFileStatus(numErrors)
+}
+
+func sortStatus(opts globalOptions, scanner *exifsort.Scanner,
+	sorter *exifsort.Sorter) int {
+	numErrors := len(scanner.ScanErrors) + len(sorter.TransferErrors)
+
+	if opts.strict {
+		numErrors += len(scanner.ExifErrors) + len(sorter.IndexErrors)
+	}
+
+	return perFileStatus(numErrors)
+}
+
+func mergeStatus(err error, m *exifsort.Merger) int {
+	var dirErr *exifsort.InvalidDirError
+
+	switch {
+	case errors.As(err, &dirErr):
+		return exitInvalid
+	case err != nil:
+		return exitFatal
+	default:
+		return perFileStatus(len(m.Errors))
+	}
+}
+
+// Misplaced media counts as an error unless it was moved.
+func verifyStatus(opts globalOptions, v *exifsort.Verifier) int {
+	numErrors := len(v.Unreadable) + len(v.Misplaced) - len(v.Moved)
+
+	if opts.strict {
+		numErrors += len(v.Strays) + len(v.Undated)
+	}
+
+	return perFileStatus(numErrors)
+}
+
+// Missing files, and unexpected ones when strict, are errors unless the
+// manifest was updated with them.
+func fsckStatus(opts globalOptions, check exifsort.ManifestCheck, updated bool) int {
+	numErrors := len(check.Modified) + len(check.Errors)
+
+	if !updated {
+		numErrors += len(check.Missing)
+
+		if opts.strict {
+			numErrors += len(check.Unexpected)
+		}
+	}
+
+	return perFileStatus(numErrors)
+}
@@ -26,7 +26,8 @@ import (
 func filterLongHelp() string {
 	return `Transfer the contents that match a regex in one sorted directory to another sorted directory.
 
-	exifsort filter <src> <dir> <regexp>
+	exifsort filter <action> <src> <dir> <regexp>
+	exifsort filter [--action <action>] <src> <dir> <regexp>
 
 	src
 	directory or json file to receive media to sort
@@ -50,24 +51,39 @@ func newFilterActionCmd(action exifsort.Action) *cobra.Command {
 		// Very long help message so we moved it to a func.
 		Long: mergeLongHelp(),
 		Args: cobra.MinimumNArgs(numMethodCmdArgs),
-		Run: func(cmd *cobra.Command, args []string) {
+		RunE: runStatus(func(cmd *cobra.Command, args []string) int {
 			src := args[0]
 			dst := args[1]
 			filter := args[2]
 
-			mergeExecute(src, dst, action, filter)
-		},
+			return mergeExecute(cmd, src, dst, action, filter)
+		}),
 	}
 }
 
 func newFilterCmd() *cobra.Command {
+	const numFilterCmdArgs = 3
+
 	// scanCmd represents the scan command.
 	rootCmd := &cobra.Command{
 		Use:   "filter",
 		Short: "Transfer the contents that match regex between sorted directories",
 		Long:  filterLongHelp(),
+		Args:  cobra.ExactArgs(numFilterCmdArgs),
+		RunE: runStatus(func(cmd *cobra.Command, args []string) int {
+			return mergeDefault(cmd, args[0], args[1], args[2])
+		}),
 	}
 
+	setMergeActionFlag(rootCmd)
+	setDateRangeFlags(rootCmd.PersistentFlags())
+	setCameraFlag(rootCmd.PersistentFlags())
+	setNearFlag(rootCmd.PersistentFlags())
+	setManifestFlag(rootCmd.PersistentFlags())
+	setCatalogFlag(rootCmd.PersistentFlags())
+	setVerifyFlag(rootCmd.PersistentFlags())
+	setPolicyFlags(rootCmd.PersistentFlags())
+
 	for _, action := range exifsort.Actions() {
 		actionCmd := newFilterActionCmd(action)
 		rootCmd.AddCommand(actionCmd)
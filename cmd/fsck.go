@@ -0,0 +1,151 @@
+/*
+Copyright © 2020 Michael Rubin <mhr@neverthere.org>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"fmt"
+
+	exifsort "github.com/matchstick/exifsort/lib"
+	"github.com/spf13/cobra"
+)
+
+func fsckLongHelp() string {
+	return `Check no file of a sorted directory changed since it was sorted.
+
+	exifsort fsck <dir> [--update]
+
+	dir
+	directory sorted or merged into with --manifest
+
+	Every file is hashed again and compared with the size and SHA-256 in the
+	manifest of dir, .exifsort.sha256. Files that changed are modified, files
+	that are gone are missing and files the manifest does not know are
+	unexpected. --update adds the unexpected files to the manifest and
+	forgets the missing ones, modified files are left for you to look at.
+	`
+}
+
+func fsckSummary(check exifsort.ManifestCheck, updated bool) {
+	fmt.Printf("## Verified: %d\n", check.Verified)
+
+	lists := []struct {
+		name  string
+		paths []string
+	}{
+		{"Modified", check.Modified},
+		{"Missing", check.Missing},
+		{"Unexpected", check.Unexpected},
+	}
+
+	for _, list := range lists {
+		fmt.Printf("## %s: %d\n", list.name, len(list.paths))
+
+		for _, path := range sortedPaths(list.paths) {
+			fmt.Printf("##\t%s\n", path)
+		}
+	}
+
+	if len(check.Errors) != 0 {
+		fmt.Println("## Errors were:")
+
+		for path, err := range check.Errors {
+			fmt.Printf("##\t%s: (%s)\n", path, err)
+		}
+	}
+
+	if updated {
+		fmt.Println("## Updated manifest")
+	}
+}
+
+// fsckUpdate adds the unexpected files to the manifest and forgets the
+// missing ones.
+func fsckUpdate(m *exifsort.Manifest, check exifsort.ManifestCheck) error {
+	for _, path := range check.Unexpected {
+		err := m.Add(path)
+		if err != nil {
+			check.Errors[path] = err.Error()
+		}
+	}
+
+	for _, path := range check.Missing {
+		m.Remove(path)
+	}
+
+	return m.Save()
+}
+
+func fsckExecute(cmd *cobra.Command, root string) int {
+	opts := getGlobalOptions(cmd)
+	update, _ := cmd.Flags().GetBool("update")
+
+	r := &report{Command: "fsck"}
+
+	var err error
+	if !exifsort.ManifestExists(root) && !update {
+		err = fmt.Errorf("%s has no manifest", root)
+	}
+
+	var m *exifsort.Manifest
+	if err == nil {
+		m, err = exifsort.LoadManifest(root)
+	}
+
+	var check exifsort.ManifestCheck
+	if err == nil {
+		check, err = m.Check(opts.rules)
+	}
+
+	if err != nil {
+		printError(opts, r, err, func() { fmt.Printf("%s\n", err.Error()) })
+		return exitInvalid
+	}
+
+	if update {
+		err = fsckUpdate(m, check)
+	}
+
+	r.Fsck = newFsckReport(check, update)
+
+	if err != nil {
+		printError(opts, r, err, func() { fmt.Printf("%s\n", err.Error()) })
+		return exitFatal
+	}
+
+	emitReport(opts, r, func() { fsckSummary(check, update) })
+
+	return fsckStatus(opts, check, update)
+}
+
+func newFsckCmd() *cobra.Command {
+	const numFsckCmdArgs = 1
+
+	fsckCmd := &cobra.Command{
+		Use:   "fsck",
+		Short: "Check the files of a sorted directory against its manifest",
+		Long:  fsckLongHelp(),
+		Args:  cobra.ExactArgs(numFsckCmdArgs),
+		RunE: runStatus(func(cmd *cobra.Command, args []string) int {
+			return fsckExecute(cmd, args[0])
+		}),
+	}
+
+	fsckCmd.Flags().Bool("update", false,
+		"add unexpected files to the manifest and forget missing ones.")
+
+	return fsckCmd
+}
@@ -0,0 +1,163 @@
+/*
+Copyright © 2020 Michael Rubin <mhr@neverthere.org>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	exifsort "github.com/matchstick/exifsort/lib"
+	"github.com/spf13/pflag"
+)
+
+// Filter flags take "<value>" for every media category or
+// "<category>=<value>" for one, such as --min-size 1k --min-size exif=20k.
+
+func setFilterFlags(flags *pflag.FlagSet) {
+	flags.StringSlice("min-size", nil,
+		"filter out files smaller than [<category>=]<size>, such as 1k or exif=20k. Use 1 to filter out empty files.")
+	flags.StringSlice("max-size", nil,
+		"filter out files larger than [<category>=]<size>, such as movie-metadata=4G.")
+	flags.StringSlice("min-dims", nil,
+		"filter out images smaller than [<category>=]<width>x<height>, such as 640x480. Movies are not measured.")
+}
+
+// parseSize reads a number of bytes with an optional k, M, G or T suffix.
+func parseSize(str string) (int64, error) {
+	orig := str
+	str = strings.TrimSpace(str)
+	mult := int64(1)
+
+	if str != "" {
+		exp := strings.IndexByte("kmgt", strings.ToLower(str)[len(str)-1])
+		if exp >= 0 {
+			for ii := 0; ii <= exp; ii++ {
+				mult *= bytesUnit
+			}
+
+			str = str[:len(str)-1]
+		}
+	}
+
+	num, err := strconv.ParseInt(str, 10, 64)
+	if err != nil || num < 0 {
+		return 0, fmt.Errorf("invalid size %s", orig)
+	}
+
+	return num * mult, nil
+}
+
+func parseDims(str string) (int, int, error) {
+	const numDims = 2
+
+	dims := strings.Split(strings.ToLower(str), "x")
+	if len(dims) != numDims {
+		return 0, 0, fmt.Errorf("invalid dimensions %s, expected <width>x<height>", str)
+	}
+
+	width, err := strconv.Atoi(dims[0])
+	if err != nil || width < 0 {
+		return 0, 0, fmt.Errorf("invalid width %s", dims[0])
+	}
+
+	height, err := strconv.Atoi(dims[1])
+	if err != nil || height < 0 {
+		return 0, 0, fmt.Errorf("invalid height %s", dims[1])
+	}
+
+	return width, height, nil
+}
+
+// filterCategories returns the categories a spec applies to and its value.
+func filterCategories(spec string) ([]exifsort.Category, string, error) {
+	const numParts = 2
+
+	parts := strings.SplitN(spec, "=", numParts)
+	if len(parts) == 1 {
+		// Sidecars are small by nature so they are left alone.
+		return []exifsort.Category{exifsort.CategoryExif,
+			exifsort.CategoryMovie, exifsort.CategoryModTime}, parts[0], nil
+	}
+
+	category, err := exifsort.CategoryParse(parts[0])
+	if err != nil {
+		return nil, "", err
+	}
+
+	return []exifsort.Category{category}, parts[1], nil
+}
+
+type limitSetter func(limits *exifsort.Limits, value string) error
+
+func applyFilterFlag(flags *pflag.FlagSet, name string, filters exifsort.Filters,
+	set limitSetter) error {
+	specs, _ := flags.GetStringSlice(name)
+
+	for _, spec := range specs {
+		categories, value, err := filterCategories(spec)
+		if err != nil {
+			return fmt.Errorf("--%s: %s", name, err.Error())
+		}
+
+		for _, category := range categories {
+			limits := filters[category]
+
+			err = set(&limits, value)
+			if err != nil {
+				return fmt.Errorf("--%s: %s", name, err.Error())
+			}
+
+			filters[category] = limits
+		}
+	}
+
+	return nil
+}
+
+// getFilters returns the filters of the filter flags, nil if there are none.
+func getFilters(flags *pflag.FlagSet) (exifsort.Filters, error) {
+	filters := make(exifsort.Filters)
+
+	setters := map[string]limitSetter{
+		"min-size": func(limits *exifsort.Limits, value string) (err error) {
+			limits.MinSize, err = parseSize(value)
+			return err
+		},
+		"max-size": func(limits *exifsort.Limits, value string) (err error) {
+			limits.MaxSize, err = parseSize(value)
+			return err
+		},
+		"min-dims": func(limits *exifsort.Limits, value string) (err error) {
+			limits.MinWidth, limits.MinHeight, err = parseDims(value)
+			return err
+		},
+	}
+
+	for name, set := range setters {
+		err := applyFilterFlag(flags, name, filters, set)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(filters) == 0 {
+		return nil, nil
+	}
+
+	return filters, nil
+}
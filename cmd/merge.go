@@ -18,7 +18,6 @@ package cmd
 
 import (
 	"fmt"
-	"os"
 
 	exifsort "github.com/matchstick/exifsort/lib"
 	"github.com/spf13/cobra"
@@ -27,6 +26,21 @@ import (
 func mergeSummary(m *exifsort.Merger) {
 	fmt.Printf("## Merged files: %d\n", len(m.Merged))
 
+	policySummary(policies{m.Collision, m.Duplicate, m.DuplicateDir, m.Naming},
+		len(m.Collisions), len(m.Replaced), 0)
+
+	if !m.Range.IsZero() {
+		fmt.Printf("## Out of range: %d\n", m.OutOfRange)
+	}
+
+	if len(m.Cameras) != 0 {
+		fmt.Printf("## Other cameras: %d\n", m.OtherCameras)
+	}
+
+	if !m.Near.IsZero() {
+		fmt.Printf("## Far away: %d\n", m.FarAway)
+	}
+
 	if len(m.Removed) != 0 {
 		fmt.Printf("## Duplicates Removed %d:\n", len(m.Removed))
 
@@ -44,28 +58,208 @@ func mergeSummary(m *exifsort.Merger) {
 	}
 }
 
-func mergeExecute(src string, dst string, action exifsort.Action, matchStr string) {
+// mergeManifests returns the manifests of src and dst to keep up to date. The
+// one of src only matters when its files are moved, dst has one when we are
+// asked to keep it.
+func mergeManifests(cmd *cobra.Command, src string, dst string,
+	action exifsort.Action) (*exifsort.Manifest, *exifsort.Manifest, error) {
+	var srcManifest *exifsort.Manifest
+
+	var err error
+
+	if action == exifsort.ActionMove && exifsort.ManifestExists(src) {
+		srcManifest, err = exifsort.LoadManifest(src)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	dstManifest, err := getManifest(cmd.Flags(), dst)
+
+	return srcManifest, dstManifest, err
+}
+
+// mergeSaveManifests forgets the files that left src and saves the manifests.
+func mergeSaveManifests(m *exifsort.Merger, srcManifest *exifsort.Manifest) error {
+	if srcManifest != nil {
+		for _, srcPath := range m.Merged {
+			srcManifest.Remove(srcPath)
+		}
+
+		for _, srcPath := range m.Removed {
+			srcManifest.Remove(srcPath)
+		}
+
+		err := srcManifest.Save()
+		if err != nil {
+			return err
+		}
+	}
+
+	if m.Manifest != nil {
+		return m.Manifest.Save()
+	}
+
+	return nil
+}
+
+// mergeForget removes the files that left src from the catalog of src, if
+// it has one.
+func mergeForget(m *exifsort.Merger, src string, action exifsort.Action) error {
+	if action != exifsort.ActionMove || !exifsort.CatalogExists(src) {
+		return nil
+	}
+
+	catalog, err := exifsort.OpenCatalog(src)
+	if err != nil {
+		return err
+	}
+
+	srcPaths := append([]string{}, m.Removed...)
+	for _, srcPath := range m.Merged {
+		srcPaths = append(srcPaths, srcPath)
+	}
+
+	for _, srcPath := range srcPaths {
+		err = catalog.Remove(srcPath)
+		if err != nil {
+			break
+		}
+	}
+
+	return closeCatalog(catalog, err)
+}
+
+// mergeExecute runs the merge and returns the exit code.
+func mergeExecute(cmd *cobra.Command, src string, dst string,
+	action exifsort.Action, matchStr string) int {
+	opts := getGlobalOptions(cmd)
+
+	rng, err := getDateRange(cmd.Flags())
+	if err != nil {
+		return printInvalid(opts, "merge", err)
+	}
+
+	near, err := getNear(cmd.Flags())
+	if err != nil {
+		return printInvalid(opts, "merge", err)
+	}
+
+	p, err := getPolicies(cmd.Flags())
+	if err != nil {
+		return printInvalid(opts, "merge", err)
+	}
+
+	srcManifest, dstManifest, err := mergeManifests(cmd, src, dst, action)
+	if err != nil {
+		return printInvalid(opts, "merge", err)
+	}
+
 	merger := exifsort.NewMerger(src, dst, action, matchStr)
+	merger.Extensions = opts.exts
+	merger.Rules = opts.rules
+	merger.Range = rng
+	merger.Cameras, _ = cmd.Flags().GetStringSlice("camera")
+	merger.Near = near
+	merger.Manifest = dstManifest
+	merger.Verify, _ = cmd.Flags().GetBool("verify")
+	merger.Collision = p.collision
+	merger.Duplicate = p.duplicate
+	merger.DuplicateDir = p.duplicateDir
+	merger.Naming = p.naming
+
+	keepCatalog, _ := cmd.Flags().GetBool("catalog")
+
+	merger.Catalog, err = openCatalog(keepCatalog, dst)
+	if err != nil {
+		return printInvalid(opts, "merge", err)
+	}
+
+	observer, finish := stageObserver(opts, "Merging",
+		func() int { return countFiles(src) })
+	err = merger.Merge(observer)
+
+	finish()
+
+	// What was merged before an error is in the manifests too.
+	saveErr := mergeSaveManifests(merger, srcManifest)
+	if err == nil {
+		err = saveErr
+	}
+
+	err = closeCatalog(merger.Catalog, err)
 
-	err := merger.Merge(os.Stdout)
+	forgetErr := mergeForget(merger, src, action)
+	if err == nil {
+		err = forgetErr
+	}
+
+	r := &report{Command: "merge"}
+	r.Merge = newMergeReport(merger, src, dst, action, matchStr)
+
+	if err != nil {
+		printError(opts, r, err, func() {
+			fmt.Printf("Merge Error: %s\n", err.Error())
+		})
+
+		return mergeStatus(err, merger)
+	}
+
+	emitReport(opts, r, func() { mergeSummary(merger) })
+
+	return mergeStatus(nil, merger)
+}
+
+// mergeDefault merges with the action from flags or config.
+func mergeDefault(cmd *cobra.Command, src string, dst string, filter string) int {
+	actionStr, _ := cmd.Flags().GetString("action")
+
+	action, err := exifsort.ActionParse(actionStr)
 	if err != nil {
-		fmt.Printf("Merge Error: %s\n", err.Error())
-		return
+		return printInvalid(getGlobalOptions(cmd), "merge", err)
 	}
 
-	mergeSummary(merger)
+	return mergeExecute(cmd, src, dst, action, filter)
+}
+
+func setMergeActionFlag(cmd *cobra.Command) {
+	cmd.Flags().String("action", exifsort.ActionCopy.String(),
+		"action when not given as an argument.")
 }
 
 func mergeLongHelp() string {
 	return `Merge one sorted directory to another sorted directory.
 
-	exifsort merge <src> <dir> 
+	exifsort merge <action> <src> <dir> [--after <date>] [--before <date>] [--on <date>]
+	exifsort merge [--action <action>] <src> <dir>
+
+	Dates are YYYY, YYYY-MM or YYYY-MM-DD. Only files in sorted directories
+	entirely in the dates are merged.
 
 	src
 	directory or json file to receive media to sort
 
 	dst
 	directory to create to transfer media
+
+	Use --manifest to keep the size and SHA-256 of every file merged into dst
+	in .exifsort.sha256 so fsck can tell if any changed later. It is always
+	kept when dst has one, and files moved out of src leave its manifest.
+
+	Use --catalog to keep a database of the files merged into dst in
+	.exifsort.db. It is always kept when dst has one, and files it has
+	anywhere in dst are duplicates.
+
+	Use --verify to read every file back from dst and compare its SHA-256
+	with src before it counts as merged. A file that does not match is an
+	error and its source is kept.
+
+	--collision decides what happens to files whose name is taken in dst:
+	"rename" them <name>_N.<ext>, "skip" them, keep the "newer" of the two or
+	"fail". --duplicates decides what happens to files with the same name and
+	contents in dst: "delete" or "keep" them, "move" them to --duplicates-dir,
+	or "auto", which deletes them when moving and keeps them when copying.
+	--naming hash renames files <name>_<hash>.<ext> instead of <name>_N.<ext>.
 `
 }
 
@@ -80,25 +274,40 @@ func newMergeActionCmd(action exifsort.Action) *cobra.Command {
 		// Very long help message so we moved it to a func.
 		Long: mergeLongHelp(),
 		Args: cobra.MinimumNArgs(numMethodCmdArgs),
-		Run: func(cmd *cobra.Command, args []string) {
+		RunE: runStatus(func(cmd *cobra.Command, args []string) int {
 			src := args[0]
 			dst := args[1]
 
-			mergeExecute(src, dst, action, "")
-		},
+			return mergeExecute(cmd, src, dst, action, "")
+		}),
 	}
 
 	return actionCmd
 }
 
 func newMergeCmd() *cobra.Command {
+	const numMergeCmdArgs = 2
+
 	// scanCmd represents the scan command.
 	rootCmd := &cobra.Command{
 		Use:   "merge",
 		Short: "Merge one sorted directory to another sorted directory",
 		Long:  mergeLongHelp(),
+		Args:  cobra.ExactArgs(numMergeCmdArgs),
+		RunE: runStatus(func(cmd *cobra.Command, args []string) int {
+			return mergeDefault(cmd, args[0], args[1], "")
+		}),
 	}
 
+	setMergeActionFlag(rootCmd)
+	setDateRangeFlags(rootCmd.PersistentFlags())
+	setCameraFlag(rootCmd.PersistentFlags())
+	setNearFlag(rootCmd.PersistentFlags())
+	setManifestFlag(rootCmd.PersistentFlags())
+	setCatalogFlag(rootCmd.PersistentFlags())
+	setVerifyFlag(rootCmd.PersistentFlags())
+	setPolicyFlags(rootCmd.PersistentFlags())
+
 	for _, action := range exifsort.Actions() {
 		actionCmd := newMergeActionCmd(action)
 		rootCmd.AddCommand(actionCmd)
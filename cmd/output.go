@@ -0,0 +1,641 @@
+/*
+Copyright © 2020 Michael Rubin <mhr@neverthere.org>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+
+	exifsort "github.com/matchstick/exifsort/lib"
+)
+
+// The formats a command can write its results in.
+const (
+	outputText   = "text"
+	outputJSON   = "json"
+	outputNDJSON = "ndjson"
+)
+
+func outputFormats() []string {
+	return []string{outputText, outputJSON, outputNDJSON}
+}
+
+func outputValid(format string) error {
+	for _, valid := range outputFormats() {
+		if format == valid {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("invalid output %s", format)
+}
+
+// The schema below is what we promise to scripts that read our output. Paths
+// are always sorted and lists are never null so the output is stable between
+// runs.
+
+type pathError struct {
+	Path  string `json:"path"`
+	Error string `json:"error"`
+}
+
+type mismatch struct {
+	Path    string `json:"path"`
+	Content string `json:"content"`
+}
+
+type filteredFile struct {
+	Path   string `json:"path"`
+	Reason string `json:"reason"`
+}
+
+type transfer struct {
+	Src string `json:"src"`
+	Dst string `json:"dst"`
+}
+
+type scanReport struct {
+	Input          string         `json:"input"`
+	Total          int            `json:"total"`
+	Skipped        int            `json:"skipped"`
+	SkippedTypes   map[string]int `json:"skipped_extensions"`
+	Filtered       []filteredFile `json:"filtered"`
+	Data           int            `json:"data"`
+	Extensions     map[string]int `json:"extensions"`
+	ExifErrorTypes map[string]int `json:"exif_error_extensions"`
+	ExifErrors     []pathError    `json:"exif_errors"`
+	ScanErrors     []pathError    `json:"scan_errors"`
+	Mismatches     []mismatch     `json:"mismatches"`
+	Devices        map[string]int `json:"devices"`
+	GPS            int            `json:"gps"`
+}
+
+type nearImage struct {
+	Path   string `json:"path"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+}
+
+type nearGroup struct {
+	Keep   nearImage   `json:"keep"`
+	Others []nearImage `json:"others"`
+}
+
+type nearReport struct {
+	Hashed int         `json:"hashed"`
+	Groups []nearGroup `json:"groups"`
+}
+
+type sortReport struct {
+	Action         string      `json:"action"`
+	Method         string      `json:"method"`
+	Dst            string      `json:"dst"`
+	IndexErrors    []pathError `json:"index_errors"`
+	TransferErrors []pathError `json:"transfer_errors"`
+	Duplicates     []string    `json:"duplicates"`
+	Collision      string      `json:"collision"`
+	Duplicate      string      `json:"duplicate"`
+	Naming         string      `json:"naming"`
+	Collisions     []transfer  `json:"collisions"`
+	Replaced       []string    `json:"replaced"`
+	OutOfRange     int         `json:"out_of_range"`
+	OtherCameras   int         `json:"other_cameras"`
+	FarAway        int         `json:"far_away"`
+}
+
+type resortReport struct {
+	Src         string `json:"src"`
+	FromMethod  string `json:"from_method"`
+	InPlace     bool   `json:"in_place"`
+	RemovedDirs int    `json:"removed_dirs"`
+}
+
+type verifyReport struct {
+	Method     string      `json:"method"`
+	Verified   int         `json:"verified"`
+	Misplaced  []transfer  `json:"misplaced"`
+	Moved      []transfer  `json:"moved"`
+	Undated    []string    `json:"undated"`
+	Strays     []string    `json:"strays"`
+	Unreadable []pathError `json:"unreadable"`
+	Errors     []pathError `json:"errors"`
+}
+
+type fsckReport struct {
+	Verified   int         `json:"verified"`
+	Modified   []string    `json:"modified"`
+	Missing    []string    `json:"missing"`
+	Unexpected []string    `json:"unexpected"`
+	Errors     []pathError `json:"errors"`
+	Updated    bool        `json:"updated"`
+}
+
+type dedupeGroup struct {
+	Size       int64    `json:"size"`
+	SHA256     string   `json:"sha256"`
+	Keep       string   `json:"keep"`
+	Duplicates []string `json:"duplicates"`
+}
+
+type dedupeReport struct {
+	Action string        `json:"action"`
+	Groups []dedupeGroup `json:"groups"`
+	Wasted int64         `json:"wasted_bytes"`
+	Done   []string      `json:"done"`
+	Errors []pathError   `json:"errors"`
+}
+
+type mergeReport struct {
+	Action       string      `json:"action"`
+	Src          string      `json:"src"`
+	Dst          string      `json:"dst"`
+	Filter       string      `json:"filter"`
+	Merged       []transfer  `json:"merged"`
+	Removed      []string    `json:"removed"`
+	Collision    string      `json:"collision"`
+	Duplicate    string      `json:"duplicate"`
+	Naming       string      `json:"naming"`
+	Collisions   []transfer  `json:"collisions"`
+	Replaced     []string    `json:"replaced"`
+	Errors       []pathError `json:"errors"`
+	OutOfRange   int         `json:"out_of_range"`
+	OtherCameras int         `json:"other_cameras"`
+	FarAway      int         `json:"far_away"`
+}
+
+type catalogReport struct {
+	Root        string      `json:"root"`
+	Added       []string    `json:"added,omitempty"`
+	Removed     []string    `json:"removed,omitempty"`
+	Unchanged   int         `json:"unchanged"`
+	Entries     int         `json:"entries"`
+	Archived    []transfer  `json:"archived,omitempty"`
+	NotArchived []string    `json:"not_archived,omitempty"`
+	Errors      []pathError `json:"errors"`
+}
+
+type queryMatch struct {
+	Path   string `json:"path"`
+	Time   string `json:"time,omitempty"`
+	Source string `json:"source"`
+	Camera string `json:"camera"`
+	Error  string `json:"error,omitempty"`
+}
+
+type queryReport struct {
+	Input   string       `json:"input"`
+	Count   int          `json:"count"`
+	Matches []queryMatch `json:"matches"`
+}
+
+type statsSpike struct {
+	Day     string `json:"day"`
+	Count   int    `json:"count"`
+	ModTime int    `json:"modtime"`
+	Reason  string `json:"reason"`
+}
+
+type statsReport struct {
+	Input      string         `json:"input"`
+	Files      int            `json:"files"`
+	Bytes      int64          `json:"bytes"`
+	Years      map[string]int `json:"years"`
+	Months     map[string]int `json:"months"`
+	Cameras    map[string]int `json:"cameras"`
+	Extensions map[string]int `json:"extensions"`
+	Sources    map[string]int `json:"sources"`
+	ExifErrors int            `json:"exif_errors"`
+	ScanErrors int            `json:"scan_errors"`
+	Spikes     []statsSpike   `json:"suspicious_days"`
+	Unreadable []pathError    `json:"unreadable"`
+}
+
+type report struct {
+	Command string         `json:"command"`
+	Error   string         `json:"error,omitempty"`
+	Scan    *scanReport    `json:"scan,omitempty"`
+	Near    *nearReport    `json:"near_duplicates,omitempty"`
+	Sort    *sortReport    `json:"sort,omitempty"`
+	Resort  *resortReport  `json:"resort,omitempty"`
+	Verify  *verifyReport  `json:"verify,omitempty"`
+	Fsck    *fsckReport    `json:"fsck,omitempty"`
+	Catalog *catalogReport `json:"catalog,omitempty"`
+	Dedupe  *dedupeReport  `json:"dedupe,omitempty"`
+	Merge   *mergeReport   `json:"merge,omitempty"`
+	Query   *queryReport   `json:"query,omitempty"`
+	Stats   *statsReport   `json:"stats,omitempty"`
+}
+
+func pathErrors(errs map[string]string) []pathError {
+	list := make([]pathError, 0, len(errs))
+
+	for path, err := range errs {
+		list = append(list, pathError{path, err})
+	}
+
+	sort.Slice(list, func(i, j int) bool { return list[i].Path < list[j].Path })
+
+	return list
+}
+
+func filteredFiles(filtered map[string]string) []filteredFile {
+	list := make([]filteredFile, 0, len(filtered))
+
+	for path, reason := range filtered {
+		list = append(list, filteredFile{path, reason})
+	}
+
+	sort.Slice(list, func(i, j int) bool { return list[i].Path < list[j].Path })
+
+	return list
+}
+
+func mismatches(s *exifsort.Scanner) []mismatch {
+	list := make([]mismatch, 0, len(s.Mismatches))
+
+	for path, content := range s.Mismatches {
+		list = append(list, mismatch{path, content})
+	}
+
+	sort.Slice(list, func(i, j int) bool { return list[i].Path < list[j].Path })
+
+	return list
+}
+
+func sortedPaths(paths []string) []string {
+	list := make([]string, len(paths))
+	copy(list, paths)
+	sort.Strings(list)
+
+	return list
+}
+
+func nonNilCounts(counts map[string]int) map[string]int {
+	if counts == nil {
+		return make(map[string]int)
+	}
+
+	return counts
+}
+
+// deviceCounts returns how many files in Data each device took.
+func deviceCounts(s *exifsort.Scanner) map[string]int {
+	counts := make(map[string]int)
+
+	for path := range s.Data {
+		counts[s.Info[path].Device()]++
+	}
+
+	return counts
+}
+
+// numGPS returns how many files in Data have coordinates.
+func numGPS(s *exifsort.Scanner) int {
+	num := 0
+
+	for path := range s.Data {
+		if s.Info[path].GPS != nil {
+			num++
+		}
+	}
+
+	return num
+}
+
+func newScanReport(s *exifsort.Scanner) *scanReport {
+	input := "dir"
+	if s.Input == exifsort.ScannerInputJSON {
+		input = "json"
+	}
+
+	return &scanReport{
+		Input:          input,
+		Total:          s.NumTotal(),
+		Skipped:        s.SkippedCount,
+		SkippedTypes:   nonNilCounts(s.NumSkippedTypes),
+		Filtered:       filteredFiles(s.Filtered),
+		Data:           len(s.Data),
+		Extensions:     nonNilCounts(s.NumDataTypes),
+		ExifErrorTypes: nonNilCounts(s.NumExifErrorTypes),
+		ExifErrors:     pathErrors(s.ExifErrors),
+		ScanErrors:     pathErrors(s.ScanErrors),
+		Mismatches:     mismatches(s),
+		Devices:        deviceCounts(s),
+		GPS:            numGPS(s),
+	}
+}
+
+func newNearReport(s *exifsort.Scanner, near [][]string) *nearReport {
+	groups := make([]nearGroup, 0, len(near))
+
+	for _, paths := range near {
+		images := make([]nearImage, 0, len(paths))
+
+		for _, path := range paths {
+			hash := s.Hashes[path]
+			images = append(images, nearImage{path, hash.Width, hash.Height})
+		}
+
+		groups = append(groups, nearGroup{images[0], images[1:]})
+	}
+
+	return &nearReport{Hashed: len(s.Hashes), Groups: groups}
+}
+
+func newSortReport(s *sortCmd, sorter *exifsort.Sorter) *sortReport {
+	return &sortReport{
+		Action:         s.action.String(),
+		Method:         s.method.String(),
+		Dst:            s.dst,
+		IndexErrors:    pathErrors(sorter.IndexErrors),
+		TransferErrors: pathErrors(sorter.TransferErrors),
+		Duplicates:     sortedPaths(sorter.Duplicates),
+		Collision:      s.policies.collision.String(),
+		Duplicate:      s.policies.duplicate.String(),
+		Naming:         s.policies.naming.String(),
+		Collisions:     transfers(sorter.Collisions),
+		Replaced:       sortedPaths(sorter.Replaced),
+		OutOfRange:     sorter.OutOfRange,
+		OtherCameras:   sorter.OtherCameras,
+		FarAway:        sorter.FarAway,
+	}
+}
+
+func newResortReport(r *resortCmd) *resortReport {
+	return &resortReport{
+		Src:         r.src,
+		FromMethod:  r.fromMethod.String(),
+		InPlace:     r.inPlace,
+		RemovedDirs: r.removed,
+	}
+}
+
+// transfers lists the src to dst map of paths sorted by src.
+func transfers(paths map[string]string) []transfer {
+	list := make([]transfer, 0, len(paths))
+
+	for src, dst := range paths {
+		list = append(list, transfer{src, dst})
+	}
+
+	sort.Slice(list, func(i, j int) bool { return list[i].Src < list[j].Src })
+
+	return list
+}
+
+func newVerifyReport(v *exifsort.Verifier) *verifyReport {
+	return &verifyReport{
+		Method:     v.Method.String(),
+		Verified:   v.Verified,
+		Misplaced:  transfers(v.Misplaced),
+		Moved:      transfers(v.Moved),
+		Undated:    sortedPaths(v.Undated),
+		Strays:     sortedPaths(v.Strays),
+		Unreadable: pathErrors(v.Unreadable),
+		Errors:     pathErrors(v.Errors),
+	}
+}
+
+func newCatalogUpdateReport(root string, update exifsort.CatalogUpdate,
+	entries int) *catalogReport {
+	return &catalogReport{
+		Root:      root,
+		Added:     sortedPaths(update.Added),
+		Removed:   sortedPaths(update.Removed),
+		Unchanged: update.Unchanged,
+		Entries:   entries,
+		Errors:    pathErrors(update.Errors),
+	}
+}
+
+func newCatalogHasReport(root string, lookup exifsort.CatalogLookup) *catalogReport {
+	return &catalogReport{
+		Root:        root,
+		Archived:    transfers(lookup.Archived),
+		NotArchived: sortedPaths(lookup.NotArchived),
+		Errors:      pathErrors(lookup.Errors),
+	}
+}
+
+func newQueryReport(input string, matches []exifsort.QueryMatch) *queryReport {
+	list := make([]queryMatch, 0, len(matches))
+
+	for _, match := range matches {
+		list = append(list, queryMatch{
+			Path:   match.Path,
+			Time:   queryTime(match),
+			Source: match.Source.String(),
+			Camera: match.Info.Device(),
+			Error:  match.Error,
+		})
+	}
+
+	return &queryReport{Input: input, Count: len(list), Matches: list}
+}
+
+func newStatsReport(input string, stats exifsort.Stats) *statsReport {
+	spikes := make([]statsSpike, 0, len(stats.Spikes))
+
+	for _, spike := range stats.Spikes {
+		spikes = append(spikes, statsSpike{spike.Day, spike.Count, spike.ModTime,
+			spike.Reason.String()})
+	}
+
+	return &statsReport{
+		Input:      input,
+		Files:      stats.Files,
+		Bytes:      stats.Bytes,
+		Years:      stats.Years,
+		Months:     stats.Months,
+		Cameras:    stats.Cameras,
+		Extensions: stats.Extensions,
+		Sources:    stats.Sources,
+		ExifErrors: stats.ExifErrors,
+		ScanErrors: stats.ScanErrors,
+		Spikes:     spikes,
+		Unreadable: pathErrors(stats.Unreadable),
+	}
+}
+
+func newFsckReport(check exifsort.ManifestCheck, updated bool) *fsckReport {
+	return &fsckReport{
+		Verified:   check.Verified,
+		Modified:   sortedPaths(check.Modified),
+		Missing:    sortedPaths(check.Missing),
+		Unexpected: sortedPaths(check.Unexpected),
+		Errors:     pathErrors(check.Errors),
+		Updated:    updated,
+	}
+}
+
+// doneDuplicates lists the duplicates dedupe got rid of.
+func doneDuplicates(d *exifsort.Deduper) []string {
+	done := make([]string, 0, len(d.Done))
+
+	for path := range d.Done {
+		done = append(done, path)
+	}
+
+	return sortedPaths(done)
+}
+
+func newDedupeReport(d *exifsort.Deduper, action exifsort.DedupeAction) *dedupeReport {
+	groups := make([]dedupeGroup, 0, len(d.Groups))
+
+	for _, group := range d.Groups {
+		groups = append(groups, dedupeGroup{
+			Size:       group.Size,
+			SHA256:     group.SHA256,
+			Keep:       group.Paths[0],
+			Duplicates: group.Paths[1:],
+		})
+	}
+
+	return &dedupeReport{
+		Action: action.String(),
+		Groups: groups,
+		Wasted: d.Wasted(),
+		Done:   doneDuplicates(d),
+		Errors: pathErrors(d.Errors),
+	}
+}
+
+func newMergeReport(m *exifsort.Merger, src string, dst string,
+	action exifsort.Action, filter string) *mergeReport {
+	merged := make([]transfer, 0, len(m.Merged))
+
+	for dstPath, srcPath := range m.Merged {
+		merged = append(merged, transfer{srcPath, dstPath})
+	}
+
+	sort.Slice(merged, func(i, j int) bool { return merged[i].Src < merged[j].Src })
+
+	return &mergeReport{
+		Action:       action.String(),
+		Src:          src,
+		Dst:          dst,
+		Filter:       filter,
+		Merged:       merged,
+		Removed:      sortedPaths(m.Removed),
+		Collision:    m.Collision.String(),
+		Duplicate:    m.Duplicate.String(),
+		Naming:       m.Naming.String(),
+		Collisions:   transfers(m.Collisions),
+		Replaced:     sortedPaths(m.Replaced),
+		Errors:       pathErrors(m.Errors),
+		OutOfRange:   m.OutOfRange,
+		OtherCameras: m.OtherCameras,
+		FarAway:      m.FarAway,
+	}
+}
+
+// emitReport writes the report as JSON when asked to, otherwise it calls text
+// to print the summary the way we always have.
+func emitReport(opts globalOptions, r *report, text func()) {
+	if opts.output == outputText {
+		text()
+		return
+	}
+
+	if opts.output == outputNDJSON {
+		_ = json.NewEncoder(os.Stdout).Encode(event{Event: "summary", Report: r})
+		return
+	}
+
+	content, err := json.MarshalIndent(r, "", "\t")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "json error %s\n", err.Error())
+		return
+	}
+
+	fmt.Println(string(content))
+}
+
+// event is one line of NDJSON output.
+type event struct {
+	Event  string     `json:"event"`
+	Path   string     `json:"path,omitempty"`
+	Src    string     `json:"src,omitempty"`
+	Dst    string     `json:"dst,omitempty"`
+	Time   *time.Time `json:"time,omitempty"`
+	Error  string     `json:"error,omitempty"`
+	Report *report    `json:"report,omitempty"`
+}
+
+// ndjsonObserver writes every event as a line of JSON.
+type ndjsonObserver struct {
+	enc *json.Encoder
+}
+
+func newNDJSONObserver(w io.Writer) *ndjsonObserver {
+	return &ndjsonObserver{enc: json.NewEncoder(w)}
+}
+
+func (n *ndjsonObserver) emit(e event) {
+	_ = n.enc.Encode(e)
+}
+
+func (n *ndjsonObserver) FileScanned(path string, time time.Time) {
+	n.emit(event{Event: "scanned", Path: path, Time: &time})
+}
+
+func (n *ndjsonObserver) ExifError(path string, err error) {
+	n.emit(event{Event: "exif_error", Path: path, Error: err.Error()})
+}
+
+func (n *ndjsonObserver) Skipped(path string) {
+	n.emit(event{Event: "skipped", Path: path})
+}
+
+func (n *ndjsonObserver) Transferred(src string, dst string) {
+	n.emit(event{Event: "transferred", Src: src, Dst: dst})
+}
+
+func (n *ndjsonObserver) DuplicateRemoved(path string) {
+	n.emit(event{Event: "duplicate_removed", Path: path})
+}
+
+func (n *ndjsonObserver) Merged(src string, dst string) {
+	n.emit(event{Event: "merged", Src: src, Dst: dst})
+}
+
+func (n *ndjsonObserver) Error(path string, err error) {
+	n.emit(event{Event: "error", Path: path, Error: err.Error()})
+}
+
+// printError reports an error that ends a command. For text output we print
+// it as we always have, otherwise it becomes part of the report.
+func printError(opts globalOptions, r *report, err error, text func()) {
+	if opts.output == outputText {
+		text()
+		return
+	}
+
+	r.Error = err.Error()
+	emitReport(opts, r, nil)
+}
+
+// printInvalid prints err, found in the arguments of command before it ran,
+// with printError and returns exitInvalid.
+func printInvalid(opts globalOptions, command string, err error) int {
+	printError(opts, &report{Command: command}, err, func() { fmt.Printf("%s\n", err.Error()) })
+	return exitInvalid
+}
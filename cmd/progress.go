@@ -0,0 +1,267 @@
+/*
+Copyright © 2020 Michael Rubin <mhr@neverthere.org>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	exifsort "github.com/matchstick/exifsort/lib"
+)
+
+const (
+	progressBarWidth   = 30
+	progressTTYRefresh = 100 * time.Millisecond
+	progressLogRefresh = 10 * time.Second
+	progressPercent    = 100
+	bytesUnit          = 1024
+)
+
+// progress is an Observer that displays how far along a stage is instead of
+// logging every file. On a terminal it redraws a single line, otherwise it
+// prints a summary line periodically.
+type progress struct {
+	exifsort.NopObserver
+	out     io.Writer
+	tty     bool
+	label   string
+	total   int
+	done    int
+	errors  int
+	bytes   int64
+	start   time.Time
+	printed time.Time
+}
+
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// countFiles walks the directory to find how many files a stage will see. We
+// ignore errors as this is only used as an estimate.
+func countFiles(root string) int {
+	var count int
+
+	_ = filepath.Walk(root,
+		func(path string, info os.FileInfo, err error) error {
+			if err == nil && !info.IsDir() {
+				count++
+			}
+
+			return nil
+		})
+
+	return count
+}
+
+func newProgress(out *os.File, label string, total int) *progress {
+	now := time.Now()
+
+	return &progress{
+		out:     out,
+		tty:     isTerminal(out),
+		label:   label,
+		total:   total,
+		start:   now,
+		printed: now,
+	}
+}
+
+func formatBytes(num int64) string {
+	if num < bytesUnit {
+		return fmt.Sprintf("%d B", num)
+	}
+
+	div, exp := int64(bytesUnit), 0
+	for n := num / bytesUnit; n >= bytesUnit; n /= bytesUnit {
+		div *= bytesUnit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f %cB", float64(num)/float64(div), "KMGTPE"[exp])
+}
+
+func (p *progress) rate() float64 {
+	elapsed := time.Since(p.start).Seconds()
+	if elapsed <= 0 {
+		return 0
+	}
+
+	return float64(p.done) / elapsed
+}
+
+func (p *progress) eta() string {
+	rate := p.rate()
+	if rate <= 0 || p.done >= p.total {
+		return "--"
+	}
+
+	remaining := float64(p.total-p.done) / rate
+
+	return time.Duration(remaining * float64(time.Second)).Round(time.Second).String()
+}
+
+func (p *progress) percent() int {
+	if p.total <= 0 {
+		return 0
+	}
+
+	percent := p.done * progressPercent / p.total
+	if percent > progressPercent {
+		percent = progressPercent
+	}
+
+	return percent
+}
+
+func (p *progress) line() string {
+	str := fmt.Sprintf("%s: %d/%d files (%d%%), %.1f files/s",
+		p.label, p.done, p.total, p.percent(), p.rate())
+
+	if p.bytes != 0 {
+		str += ", " + formatBytes(p.bytes)
+	}
+
+	if p.errors != 0 {
+		str += fmt.Sprintf(", %d errors", p.errors)
+	}
+
+	return str + ", ETA " + p.eta()
+}
+
+func (p *progress) bar() string {
+	filled := p.percent() * progressBarWidth / progressPercent
+
+	return "[" + strings.Repeat("=", filled) +
+		strings.Repeat(" ", progressBarWidth-filled) + "] "
+}
+
+func (p *progress) draw(force bool) {
+	refresh := progressLogRefresh
+	if p.tty {
+		refresh = progressTTYRefresh
+	}
+
+	if !force && time.Since(p.printed) < refresh {
+		return
+	}
+
+	p.printed = time.Now()
+
+	if p.tty {
+		// Clear the line and redraw it in place.
+		fmt.Fprintf(p.out, "\r\033[K%s%s", p.bar(), p.line())
+		return
+	}
+
+	fmt.Fprintln(p.out, p.line())
+}
+
+func (p *progress) step() {
+	p.done++
+	p.draw(false)
+}
+
+func (p *progress) addBytes(path string) {
+	info, err := os.Stat(path)
+	if err == nil {
+		p.bytes += info.Size()
+	}
+}
+
+// Finish prints the final state of the stage.
+func (p *progress) Finish() {
+	p.draw(true)
+
+	if p.tty {
+		fmt.Fprintln(p.out)
+	}
+}
+
+func (p *progress) FileScanned(path string, time time.Time) { p.step() }
+
+func (p *progress) Skipped(path string) { p.step() }
+
+func (p *progress) DuplicateRemoved(path string) { p.step() }
+
+func (p *progress) Transferred(src string, dst string) {
+	p.addBytes(dst)
+	p.step()
+}
+
+func (p *progress) Merged(src string, dst string) {
+	p.addBytes(dst)
+	p.step()
+}
+
+// Errors are still worth reading, so we print them above the progress line.
+func (p *progress) Error(path string, err error) {
+	p.errors++
+
+	if p.tty {
+		fmt.Fprint(p.out, "\r\033[K")
+	}
+
+	fmt.Fprintf(p.out, "Error: %s: (%s)\n", path, err.Error())
+	p.step()
+}
+
+type finishFunc func()
+
+// stageObserver returns the observer a stage of a command reports to and a
+// func to call when the stage is done. total is only called when progress is
+// displayed since counting files can take a while.
+func stageObserver(opts globalOptions, label string,
+	total func() int) (exifsort.Observer, finishFunc) {
+	var observer exifsort.Observer
+
+	switch opts.output {
+	case outputNDJSON:
+		observer = newNDJSONObserver(os.Stdout)
+	case outputJSON:
+		observer = exifsort.NopObserver{}
+	default:
+		observer = exifsort.NewLogObserver(os.Stdout)
+	}
+
+	if !opts.progress {
+		return observer, func() {}
+	}
+
+	// Keep stdout clean for scripts reading JSON.
+	out := os.Stdout
+	if opts.output != outputText {
+		out = os.Stderr
+	}
+
+	p := newProgress(out, label, total())
+
+	if opts.output == outputNDJSON {
+		return exifsort.MultiObserver{observer, p}, p.Finish
+	}
+
+	return p, p.Finish
+}
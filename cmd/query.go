@@ -0,0 +1,206 @@
+/*
+Copyright © 2020 Michael Rubin <mhr@neverthere.org>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"time"
+
+	exifsort "github.com/matchstick/exifsort/lib"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// The formats query prints its matches in.
+const (
+	queryList  = "list"
+	queryCount = "count"
+	queryCSV   = "csv"
+)
+
+func queryFormats() []string {
+	return []string{queryList, queryCount, queryCSV}
+}
+
+func queryLongHelp() string {
+	return `Find the media of a scan that matches filters.
+
+	exifsort query [flags] <src>
+
+	src
+	directory, such as a sorted one, or json file from scan to query
+
+	With no filters every file is matched. --after, --before and --on match
+	media from dates, --camera media from cameras, --extension files with an
+	extension such as mov and --source media whose time came from "exif",
+	"movie" or "modtime". --errors matches "none" for media scanned without
+	errors, "exif" for media whose metadata could not be read and "scan" for
+	files that could not be scanned at all. It is "any" by default.
+
+	--format list prints the paths in time order, count prints how many
+	there are and csv prints path, time, source, camera and error for each.
+
+	How many videos from 2015?
+	exifsort query --format count --on 2015 --extension mov,mp4 scan.json
+
+	Which files came from the iPhone in July?
+	exifsort query --camera iphone --on 2020-07 scan.json
+	`
+}
+
+func queryFormatValid(format string) error {
+	for _, valid := range queryFormats() {
+		if format == valid {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("invalid format %s", format)
+}
+
+func getQuery(flags *pflag.FlagSet) (exifsort.Query, error) {
+	var (
+		q   exifsort.Query
+		err error
+	)
+
+	q.Range, err = getDateRange(flags)
+	if err != nil {
+		return q, err
+	}
+
+	q.Cameras, _ = flags.GetStringSlice("camera")
+	q.Extensions, _ = flags.GetStringSlice("extension")
+
+	sources, _ := flags.GetStringSlice("source")
+	for _, str := range sources {
+		source, err := exifsort.TimeSourceParse(str)
+		if err != nil {
+			return q, err
+		}
+
+		q.Sources = append(q.Sources, source)
+	}
+
+	errorsStr, _ := flags.GetString("errors")
+	q.Errors, err = exifsort.ErrorStateParse(errorsStr)
+
+	return q, err
+}
+
+func queryTime(match exifsort.QueryMatch) string {
+	if match.Time.IsZero() {
+		return ""
+	}
+
+	return match.Time.Format(time.RFC3339)
+}
+
+func queryPrintCSV(matches []exifsort.QueryMatch) {
+	w := csv.NewWriter(os.Stdout)
+	_ = w.Write([]string{"path", "time", "source", "camera", "error"})
+
+	for _, match := range matches {
+		_ = w.Write([]string{match.Path, queryTime(match), match.Source.String(),
+			match.Info.Device(), match.Error})
+	}
+
+	w.Flush()
+}
+
+func queryPrint(format string, matches []exifsort.QueryMatch) {
+	switch format {
+	case queryCount:
+		fmt.Println(len(matches))
+	case queryCSV:
+		queryPrintCSV(matches)
+	default:
+		for _, match := range matches {
+			fmt.Println(match.Path)
+		}
+	}
+}
+
+func queryExecute(cmd *cobra.Command, src string) int {
+	opts := getGlobalOptions(cmd)
+	flags := cmd.Flags()
+	jobs, _ := flags.GetInt("jobs")
+	format, _ := flags.GetString("format")
+	r := &report{Command: "query"}
+
+	err := queryFormatValid(format)
+
+	var q exifsort.Query
+	if err == nil {
+		q, err = getQuery(flags)
+	}
+
+	if err != nil {
+		printError(opts, r, err, func() { fmt.Printf("%s\n", err.Error()) })
+		return exitInvalid
+	}
+
+	scanner, err := loadScan(opts, src, jobs)
+
+	var matches []exifsort.QueryMatch
+	if err == nil {
+		matches, err = q.Run(&scanner)
+	}
+
+	if err != nil {
+		printError(opts, r, err, func() {
+			fmt.Printf("\"%s\" error (%s)\n", src, err.Error())
+		})
+
+		return exitInvalid
+	}
+
+	r.Query = newQueryReport(src, matches)
+
+	emitReport(opts, r, func() { queryPrint(format, matches) })
+
+	return exitSuccess
+}
+
+func newQueryCmd() *cobra.Command {
+	const numQueryCmdArgs = 1
+
+	queryCmd := &cobra.Command{
+		Use:   "query",
+		Short: "Lists, counts or exports the media of a scan matching filters",
+		Long:  queryLongHelp(),
+		Args:  cobra.ExactArgs(numQueryCmdArgs),
+		RunE: runStatus(func(cmd *cobra.Command, args []string) int {
+			return queryExecute(cmd, args[0])
+		}),
+	}
+
+	flags := queryCmd.Flags()
+	setJobsFlag(flags)
+	setDateRangeFlags(flags)
+	setCameraFlag(flags)
+	flags.StringSlice("extension", nil, "only files with this extension, such as mov.")
+	flags.StringSlice("source", nil,
+		"only media whose time came from \"exif\", \"movie\" or \"modtime\".")
+	flags.String("errors", exifsort.ErrorStateAny.String(),
+		"only media scanned with these errors: \"any\", \"none\", \"exif\" or \"scan\".")
+	flags.String("format", queryList, "print the matches as a \"list\", \"count\" or \"csv\".")
+
+	return queryCmd
+}
@@ -0,0 +1,202 @@
+/*
+Copyright © 2020 Michael Rubin <mhr@neverthere.org>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"fmt"
+
+	exifsort "github.com/matchstick/exifsort/lib"
+	"github.com/spf13/cobra"
+)
+
+type resortCmd struct {
+	sortCmd
+	rescan     bool
+	inPlace    bool
+	fromMethod exifsort.Method
+	removed    int
+}
+
+func resortLongHelp() string {
+	return `Sort a sorted directory again by another method.
+
+	exifsort resort <dir> <method> [<dst>]
+
+	dir
+	directory sorted by sort or merged into, by any method
+
+	method
+	method to sort by. Valid values are 'year', 'month', 'day', 'event',
+	'week', 'quarter' or 'yearmonth'.
+
+	dst
+	directory to create to transfer media to. Without one dir is sorted in
+	place, the media is moved and the directories left empty are removed.
+
+	The time of a file is taken from the directory it is in when that is all
+	the new method needs, so month to year only reads directory names. Files
+	are read when the new method needs a finer time, such as month to day,
+	or for every file with --rescan. Sorting by event, --layout device or
+	location, --camera and --near read every file.
+
+	The manifest of a directory sorted in place is kept up to date.
+	`
+}
+
+// needRescan reports if every file must be read for its time or exif data.
+func (r *resortCmd) needRescan() bool {
+	return r.rescan || r.layout != exifsort.LayoutDate ||
+		len(r.cameras) != 0 || !r.near.IsZero()
+}
+
+func (r *resortCmd) resortSummary(scanner *exifsort.Scanner,
+	sorter *exifsort.Sorter) {
+	r.sortSummary(scanner, sorter)
+	fmt.Printf("## Resorted from %s to %s\n", r.fromMethod, r.method)
+
+	if r.inPlace {
+		fmt.Printf("## Removed empty directories: %d\n", r.removed)
+	}
+}
+
+// Returns the exit code.
+func (r *resortCmd) resortExecute() int {
+	scanner := exifsort.NewScanner()
+	scanner.Jobs = r.jobs
+	scanner.Extensions = r.opts.exts
+	scanner.Sniff = r.sniff
+	scanner.Rules = r.opts.rules
+	scanner.Filters = r.filters
+
+	observer, finish := stageObserver(r.opts, "Scanning",
+		func() int { return countFiles(r.src) })
+	fromMethod, err := scanner.ScanSorted(r.src, r.method, r.needRescan(), observer)
+
+	finish()
+
+	rep := &report{Command: "resort"}
+
+	if err == nil && r.inPlace && fromMethod == r.method &&
+		r.layout == exifsort.LayoutDate {
+		err = fmt.Errorf("%s is already sorted by %s", r.src, r.method)
+	}
+
+	if err != nil {
+		printError(r.opts, rep, err, func() { fmt.Printf("%s\n", err.Error()) })
+		return exitInvalid
+	}
+
+	r.fromMethod = fromMethod
+	rep.Scan = newScanReport(&scanner)
+
+	sorter, status := r.sortTransfer(&scanner, rep)
+	if sorter == nil {
+		return status
+	}
+
+	if r.inPlace {
+		// Only the directories of the media scanned can have been emptied.
+		scanned := make([]string, 0, len(scanner.Data))
+		for path := range scanner.Data {
+			scanned = append(scanned, path)
+		}
+
+		r.removed, err = exifsort.RemoveEmptyDirs(r.src, scanned)
+		if err != nil {
+			printError(r.opts, rep, err, func() { fmt.Printf("%s\n", err.Error()) })
+			return exitFatal
+		}
+	}
+
+	rep.Resort = newResortReport(r)
+
+	emitReport(r.opts, rep, func() { r.resortSummary(&scanner, sorter) })
+
+	return sortStatus(r.opts, &scanner, sorter)
+}
+
+func (r *resortCmd) run(cmd *cobra.Command, args []string) int {
+	const numDstArgs = 3
+
+	var err error
+
+	r.src = args[0]
+	r.dst = args[0]
+	r.inPlace = len(args) < numDstArgs
+	r.jobs, _ = cmd.Flags().GetInt("jobs")
+	r.sniff, _ = cmd.Flags().GetBool("sniff")
+	r.rescan, _ = cmd.Flags().GetBool("rescan")
+	r.opts = getGlobalOptions(cmd)
+
+	r.method, err = exifsort.MethodParse(args[1])
+	if err != nil {
+		return printInvalid(r.opts, "resort", err)
+	}
+
+	actionStr, _ := cmd.Flags().GetString("action")
+
+	r.action, err = exifsort.ActionParse(actionStr)
+	if err != nil {
+		return printInvalid(r.opts, "resort", err)
+	}
+
+	if !r.inPlace {
+		r.dst = args[2]
+	}
+
+	err = r.selectionParse(cmd.Flags())
+	if err != nil {
+		return printInvalid(r.opts, "resort", err)
+	}
+
+	if r.inPlace {
+		// Copying in place would leave every file twice.
+		r.action = exifsort.ActionMove
+	} else {
+		err = outputCreate(r.dst)
+		if err != nil {
+			return printInvalid(r.opts, "resort", err)
+		}
+	}
+
+	return r.resortExecute()
+}
+
+func newResortCmd() *cobra.Command {
+	const (
+		minResortArgs = 2
+		maxResortArgs = 3
+	)
+
+	var r resortCmd
+
+	rootCmd := &cobra.Command{
+		Use:   "resort",
+		Short: "Sort a sorted directory again by another method",
+		Long:  resortLongHelp(),
+		Args:  cobra.RangeArgs(minResortArgs, maxResortArgs),
+		RunE:  runStatus(r.run),
+	}
+
+	rootCmd.Flags().String("action", exifsort.ActionCopy.String(),
+		"how to transfer files to dst, without dst files are always moved.")
+	rootCmd.Flags().Bool("rescan", false,
+		"read the time of every file instead of taking it from its directory.")
+	setSortFlags(rootCmd.Flags())
+
+	return rootCmd
+}
@@ -17,12 +17,11 @@ limitations under the License.
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"os"
 
-	"github.com/mitchellh/go-homedir"
 	"github.com/spf13/cobra"
-	"github.com/spf13/viper"
 )
 
 func newVersionCmd() *cobra.Command {
@@ -42,7 +41,7 @@ func newVersionCmd() *cobra.Command {
 
 func newRootCmd() *cobra.Command {
 	// rootCmd represents the base command when called without any subcommands.
-	return &cobra.Command{
+	rootCmd := &cobra.Command{
 		Use:   "exifsort",
 		Short: "Sorting media by date using the exif information",
 		Long: `exifsort sorts media in nested directories 
@@ -52,58 +51,47 @@ The pipeline of commands are:
 exifsort scan <src>
 exifsort sort <action> <method> <src> <dst>
 exifsort merge <src> dst>
+exifsort resort <dir> <method> [<dst>]
 
 Check out github.com/matchstick/exifsort for more details.
 . `,
+		PersistentPreRunE: preRun,
 	}
-}
 
-const exitErr = 1
+	setGlobalFlags(rootCmd)
+
+	return rootCmd
+}
 
 // Execute is the single function to set up the complete set of nested cobra
 // based commands that provide CLI functionality to the exifsort library.
 func Execute() {
-	cobra.OnInitialize(initConfig)
-
 	rootCmd := newRootCmd()
 
 	rootCmd.AddCommand(newEvalCmd())
+	rootCmd.AddCommand(newDedupeCmd())
 	rootCmd.AddCommand(newFilterCmd())
+	rootCmd.AddCommand(newFsckCmd())
+	rootCmd.AddCommand(newCatalogCmd())
 	rootCmd.AddCommand(newMergeCmd())
+	rootCmd.AddCommand(newQueryCmd())
+	rootCmd.AddCommand(newResortCmd())
 	rootCmd.AddCommand(newScanCmd())
 	rootCmd.AddCommand(newSortCmd())
+	rootCmd.AddCommand(newStatsCmd())
+	rootCmd.AddCommand(newVerifyCmd())
 	rootCmd.AddCommand(newVersionCmd())
 
-	if err := rootCmd.Execute(); err != nil {
-		fmt.Println(err)
-		os.Exit(exitErr)
-	}
-}
-
-// initConfig reads in config file and ENV variables if set.
-func initConfig() {
-	var cfgFile string
-	if cfgFile != "" {
-		// Use config file from the flag.
-		viper.SetConfigFile(cfgFile)
-	} else {
-		// Find home directory.
-		home, err := homedir.Dir()
-		if err != nil {
-			fmt.Println(err)
-			os.Exit(exitErr)
-		}
-
-		// Search config in home directory with name ".exifsort"
-		// (without extension).
-		viper.AddConfigPath(home)
-		viper.SetConfigName(".exifsort")
-	}
+	// We print errors ourselves so commands that already reported their
+	// problem don't have it printed twice.
+	rootCmd.SilenceErrors = true
 
-	viper.AutomaticEnv() // read in environment variables that match
+	err := rootCmd.Execute()
 
-	// If a config file is found, read it in.
-	if err := viper.ReadInConfig(); err == nil {
-		fmt.Println("Using config file:", viper.ConfigFileUsed())
+	var status exitStatus
+	if err != nil && !errors.As(err, &status) {
+		fmt.Println(err)
 	}
+
+	os.Exit(exitCode(err))
 }
@@ -19,8 +19,6 @@ package cmd
 import (
 	"fmt"
 
-	"os"
-
 	exifsort "github.com/matchstick/exifsort/lib"
 	"github.com/spf13/cobra"
 )
@@ -33,12 +31,33 @@ func scanSummary(s *exifsort.Scanner) {
 
 	fmt.Printf("## Scanned Total: %d\n", s.NumTotal())
 	fmt.Printf("## Scanned Skipped: %d\n", s.SkippedCount)
+
+	for extension, num := range s.NumSkippedTypes {
+		fmt.Printf("##\t [%s]: %d\n", extension, num)
+	}
+
+	fmt.Printf("## Scanned Filtered: %d\n", len(s.Filtered))
 	fmt.Printf("## Scanned Data: %d\n", len(s.Data))
 
 	for extension, num := range s.NumDataTypes {
 		fmt.Printf("##\t [%s]: %d\n", extension, num)
 	}
 
+	fmt.Printf("## Scanned with GPS: %d\n", numGPS(s))
+	fmt.Println("## Scanned Devices:")
+
+	for device, num := range deviceCounts(s) {
+		fmt.Printf("##\t [%s]: %d\n", device, num)
+	}
+
+	if len(s.Mismatches) != 0 {
+		fmt.Println("## Extensions not matching contents:")
+
+		for path, extension := range s.Mismatches {
+			fmt.Printf("##\t%s: (%s)\n", path, extension)
+		}
+	}
+
 	if len(s.ScanErrors) != 0 {
 		fmt.Println("## Scanned Errors were:")
 
@@ -48,16 +67,105 @@ func scanSummary(s *exifsort.Scanner) {
 	}
 }
 
-func scanSave(s *exifsort.Scanner, json string) {
+func nearSummary(s *exifsort.Scanner, near [][]string) {
+	fmt.Printf("## Scanned Hashed: %d\n", len(s.Hashes))
+	fmt.Printf("## Near Duplicate groups: %d\n", len(near))
+
+	for _, group := range near {
+		for i, path := range group {
+			hash := s.Hashes[path]
+
+			keep := ""
+			if i == 0 {
+				keep = " keep"
+			}
+
+			fmt.Printf("##\t%s: %dx%d%s\n", path, hash.Width, hash.Height, keep)
+		}
+	}
+}
+
+func scanSave(s *exifsort.Scanner, json string) error {
 	if json == "" {
-		return
+		return nil
 	}
 
 	err := s.Save(json)
 	if err != nil {
-		fmt.Printf("json file %s Error:%s\n", json, err.Error())
-		return
+		return fmt.Errorf("json file %s Error:%s", json, err.Error())
 	}
+
+	return nil
+}
+
+// Returns the exit code.
+func scanExecute(cmd *cobra.Command, dirPath string) int {
+	json, _ := cmd.Flags().GetString("json")
+	jobs, _ := cmd.Flags().GetInt("jobs")
+	sniff, _ := cmd.Flags().GetBool("sniff")
+	phash, _ := cmd.Flags().GetBool("phash")
+	threshold, _ := cmd.Flags().GetInt("threshold")
+	opts := getGlobalOptions(cmd)
+
+	filters, err := getFilters(cmd.Flags())
+	if err != nil {
+		printError(opts, &report{Command: "scan"}, err, func() {
+			fmt.Println(err.Error())
+		})
+
+		return exitInvalid
+	}
+
+	observer, finish := stageObserver(opts, "Scanning",
+		func() int { return countFiles(dirPath) })
+
+	scanner := exifsort.NewScanner()
+	scanner.Jobs = jobs
+	scanner.Extensions = opts.exts
+	scanner.Sniff = sniff
+	scanner.Rules = opts.rules
+	scanner.Filters = filters
+	scanner.PHash = phash
+	err = scanner.ScanDir(dirPath, observer)
+	finish()
+
+	r := &report{Command: "scan"}
+	if err != nil {
+		printError(opts, r, err, func() {
+			fmt.Printf("Scan error %s\n", err.Error())
+		})
+
+		return exitInvalid
+	}
+
+	r.Scan = newScanReport(&scanner)
+
+	var near [][]string
+	if phash {
+		near = exifsort.NearDuplicates(scanner.Hashes, threshold)
+		r.Near = newNearReport(&scanner, near)
+	}
+
+	saveErr := scanSave(&scanner, json)
+	if saveErr != nil {
+		r.Error = saveErr.Error()
+	}
+
+	emitReport(opts, r, func() {
+		scanSummary(&scanner)
+		if phash {
+			nearSummary(&scanner, near)
+		}
+		if saveErr != nil {
+			fmt.Println(saveErr.Error())
+		}
+	})
+
+	if saveErr != nil {
+		return exitFatal
+	}
+
+	return scanStatus(opts, &scanner)
 }
 
 func newScanCmd() *cobra.Command {
@@ -67,27 +175,21 @@ func newScanCmd() *cobra.Command {
 		Short: "Scan directory for Exif Dates",
 		Long: `Scan directory for Exif Date Info. 
 
-	exifsort scan <src> [--json <file>] 
+	exifsort scan <src> [--json <file>] [--jobs <num>] [--sniff] [--phash [--threshold <bits>]]
 
 	ARGUMENTS
 
 	src 
-	directory to scan for media date informaiton.`,
-		Args: cobra.MinimumNArgs(1),
-		Run: func(cmd *cobra.Command, args []string) {
-			dirPath := args[0]
-			json, _ := cmd.Flags().GetString("json")
-
-			scanner := exifsort.NewScanner()
-			err := scanner.ScanDir(dirPath, os.Stdout)
-			if err != nil {
-				fmt.Printf("Scan error %s\n", err.Error())
-				return
-			}
+	directory to scan for media date informaiton.
 
-			scanSummary(&scanner)
-			scanSave(&scanner, json)
-		},
+	--phash decodes JPEG and PNG images and reports the ones that look the
+	same, such as a photo and a smaller copy compressed again by a messaging
+	app. Images whose perceptual hashes differ by at most --threshold bits
+	are near duplicates. The one with the most pixels is suggested to keep.`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: runStatus(func(cmd *cobra.Command, args []string) int {
+			return scanExecute(cmd, args[0])
+		}),
 	}
 
 	var scanFlags = []cmdStringFlag{
@@ -95,6 +197,13 @@ func newScanCmd() *cobra.Command {
 	}
 
 	setStringFlags(scanCmd, scanFlags)
+	setJobsFlag(scanCmd.Flags())
+	setSniffFlag(scanCmd.Flags())
+	setFilterFlags(scanCmd.Flags())
+	scanCmd.Flags().Bool("phash", false,
+		"hash JPEG and PNG images to report near duplicates.")
+	scanCmd.Flags().Int("threshold", exifsort.NearThreshold,
+		"most bits the hashes of near duplicates differ by, out of 64.")
 
 	return scanCmd
 }
@@ -19,23 +19,55 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"time"
 
 	exifsort "github.com/matchstick/exifsort/lib"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 )
 
 type sortCmd struct {
-	src      string
-	dst      string
-	method   exifsort.Method
-	action   exifsort.Action
-	cobraCmd *cobra.Command
+	src       string
+	dst       string
+	method    exifsort.Method
+	action    exifsort.Action
+	jobs      int
+	sniff     bool
+	filters   exifsort.Filters
+	rng       exifsort.DateRange
+	cameras   exifsort.Cameras
+	near      exifsort.Near
+	layout    exifsort.Layout
+	gazetteer *exifsort.Gazetteer
+	eventGap  time.Duration
+	labels    exifsort.EventLabels
+	manifest  *exifsort.Manifest
+	catalog   bool
+	verify    bool
+	policies  policies
+	opts      globalOptions
+	cobraCmd  *cobra.Command
 }
 
 func (s *sortCmd) sortSummary(scanner *exifsort.Scanner,
 	sorter *exifsort.Sorter) {
 	scanSummary(scanner)
 
+	if !s.rng.IsZero() {
+		fmt.Printf("## Out of range: %d\n", sorter.OutOfRange)
+	}
+
+	if len(s.cameras) != 0 {
+		fmt.Printf("## Other cameras: %d\n", sorter.OtherCameras)
+	}
+
+	if !s.near.IsZero() {
+		fmt.Printf("## Far away: %d\n", sorter.FarAway)
+	}
+
+	policySummary(s.policies, len(sorter.Collisions), len(sorter.Replaced),
+		len(sorter.Duplicates))
+
 	if len(sorter.IndexErrors) != 0 {
 		fmt.Println("## Index Errors were:")
 
@@ -77,6 +109,7 @@ func (s *sortCmd) sortLongHelp() string {
 	return `Sort directory by Exif Date Info. 
 
 	exifsort sort <action> <method> <src> <dst>
+	exifsort sort [--action <action>] [--method <method>] <src> <dst>
 
 	sort command performs a number of steps:
 
@@ -89,53 +122,164 @@ func (s *sortCmd) sortLongHelp() string {
 
 	action
 	Choice of how to move files from src to dst.
-	Valid values are 'copy' or 'move'
+	Valid values are 'copy' or 'move'. When not given it is taken from
+	--action or the config file.
 
 	method
 	Choice of how to index the media in the new directory.
-	Valid values are 'year', 'month' or 'day'.
+	Valid values are 'year', 'month', 'day', 'event', 'week', 'quarter' or
+	'yearmonth'. When not given it is taken from --method or the config file.
 
 	src
 	directory or json file to receive media to sort
 
 	dst
 	directory to create to transfer media
+
+	Use --after, --before or --on with a date as YYYY, YYYY-MM or YYYY-MM-DD
+	to only sort media from those dates. Use --camera to only sort media from
+	some cameras and --near to only sort media taken close to a place.
+
+	Use --layout device to sort media into a folder per camera or --layout
+	location to add the place media was taken to the directory names, as in
+	2020/2020_04 Lisbon. Places come from a built-in list of the places of at
+	least 1000 people GeoNames knows or the GeoNames file given with
+	--gazetteer.
+
+	The event method starts a new folder whenever more than --event-gap passes
+	between two photos, as in 2020/2020_04_27-2020_04_29. Events that start on
+	the same day add the time they start, as in 2020/2020_04_27_0800.
+	--event-labels names events from a CSV file of lines like
+	"2020-04-27,2020-04-29,Lisbon".
+
+	Use --manifest to keep the size and SHA-256 of every file in dst in
+	.exifsort.sha256 so fsck can tell if any changed later.
+
+	Use --catalog to keep a database of the media in dst in .exifsort.db,
+	with its SHA-256, time, source, camera and GPS. It is always kept when
+	dst has one, and media it has anywhere in dst is a duplicate.
+
+	Use --verify to read every file back from dst and compare its SHA-256
+	with src before it counts as transferred. Files are then moved by copying
+	them and removing the source once they match, a file that does not
+	match is a transfer error and its source is kept.
+
+	--collision decides what happens to media whose name is taken: "rename"
+	it <name>_N.<ext>, "skip" it, keep the "newer" of the two or "fail".
+	--duplicates decides what happens to media with the same name and
+	contents as one transferred: "delete" or "keep" it, "move" it to
+	--duplicates-dir, or "auto", which deletes it when moving and keeps it
+	when copying.
+
+	Media is sorted in time order, then path order, so the same src always
+	gives the same names. --naming hash renames media whose name is taken
+	<name>_<hash>.<ext> instead of <name>_N.<ext>, hash the start of the
+	SHA-256 of its contents.
 	`
 }
 
-// Here we finally do the work.
-func (s *sortCmd) sortExecute() {
+// Here we finally do the work. Returns the exit code.
+func (s *sortCmd) sortExecute() int {
 	scanner := exifsort.NewScanner()
+	scanner.Jobs = s.jobs
+	scanner.Extensions = s.opts.exts
+	scanner.Sniff = s.sniff
+	scanner.Rules = s.opts.rules
+	scanner.Filters = s.filters
 
 	var err error
 	if s.isSrcDir() {
 		// Here we walk the directory and get stats
-		err = scanner.ScanDir(s.src, os.Stdout)
+		observer, finish := stageObserver(s.opts, "Scanning",
+			func() int { return countFiles(s.src) })
+		err = scanner.ScanDir(s.src, observer)
+
+		finish()
 	} else {
 		// Or we get stats from a json file
 		err = scanner.Load(s.src)
 	}
 
+	r := &report{Command: "sort"}
+
 	if err != nil {
-		fmt.Printf("\"%s\" error (%s)\n", s.src, err.Error())
-		return
+		printError(s.opts, r, err, func() {
+			fmt.Printf("\"%s\" error (%s)\n", s.src, err.Error())
+		})
+
+		return exitInvalid
+	}
+
+	r.Scan = newScanReport(&scanner)
+
+	sorter, status := s.sortTransfer(&scanner, r)
+	if sorter == nil {
+		return status
+	}
+
+	emitReport(s.opts, r, func() { s.sortSummary(&scanner, sorter) })
+
+	return sortStatus(s.opts, &scanner, sorter)
+}
+
+// sortTransfer sorts the media scanner found and transfers it to dst. It
+// returns the sorter, or nil and the exit code of the error it printed.
+func (s *sortCmd) sortTransfer(scanner *exifsort.Scanner,
+	r *report) (*exifsort.Sorter, int) {
+	// dst exists by now.
+	catalog, err := openCatalog(s.catalog, s.dst)
+	if err != nil {
+		printError(s.opts, r, err, func() { fmt.Printf("%s\n", err.Error()) })
+		return nil, exitInvalid
 	}
 
 	// Now we ke those stats and Sort them.
-	sorter, err := exifsort.NewSorter(scanner, s.method)
+	sorter, err := exifsort.NewSorter(*scanner, s.method,
+		exifsort.WithDateRange(s.rng),
+		exifsort.WithCameras(s.cameras),
+		exifsort.WithNear(s.near),
+		exifsort.WithLayout(s.layout),
+		exifsort.WithGazetteer(s.gazetteer),
+		exifsort.WithEventGap(s.eventGap),
+		exifsort.WithEventLabels(s.labels),
+		exifsort.WithManifest(s.manifest),
+		exifsort.WithVerify(s.verify),
+		exifsort.WithCollision(s.policies.collision),
+		exifsort.WithDuplicates(s.policies.duplicate, s.policies.duplicateDir),
+		exifsort.WithNaming(s.policies.naming),
+		exifsort.WithCatalog(catalog))
 	if err != nil {
-		fmt.Printf("%s\n", err.Error())
-		return
+		_ = closeCatalog(catalog, err)
+
+		printError(s.opts, r, err, func() { fmt.Printf("%s\n", err.Error()) })
+		return nil, exitInvalid
 	}
 
-	// Transfer the files to the dst
-	err = sorter.Transfer(s.dst, s.action, os.Stdout)
+	// Transfer the files to the dst. Every piece of data that is not an
+	// index error or left out by the selection will be transferred.
+	observer, finish := stageObserver(s.opts, "Transferring",
+		func() int {
+			return len(scanner.Data) - len(sorter.IndexErrors) - sorter.OutOfRange -
+				sorter.OtherCameras - sorter.FarAway
+		})
+	err = sorter.Transfer(s.dst, s.action, observer)
+
+	finish()
+
+	if err == nil && s.manifest != nil {
+		err = s.manifest.Save()
+	}
+
+	err = closeCatalog(catalog, err)
+
+	r.Sort = newSortReport(s, sorter)
+
 	if err != nil {
-		fmt.Printf("%s\n", err.Error())
-		return
+		printError(s.opts, r, err, func() { fmt.Printf("%s\n", err.Error()) })
+		return nil, exitFatal
 	}
 
-	s.sortSummary(&scanner, sorter)
+	return sorter, exitSuccess
 }
 
 func (s *sortCmd) newSortMethodCmd(action exifsort.Action,
@@ -151,25 +295,117 @@ func (s *sortCmd) newSortMethodCmd(action exifsort.Action,
 		// Very long help message so we moved it to a func.
 		Long: s.sortLongHelp(),
 		Args: cobra.MinimumNArgs(numMethodCmdArgs),
-		Run: func(cmd *cobra.Command, args []string) {
-			var err error
-			s.src = args[0]
-			s.dst = args[1]
-			s.method = method
-			s.action = action
-
-			// We create directory before executing.
-			// It would not be cool to spend a lot of time
-			// then fail due to perms or previous output
-			// directory.
-			err = outputCreate(s.dst)
-			if err != nil {
-				return
-			}
-
-			s.sortExecute()
-		},
+		RunE: runStatus(func(cmd *cobra.Command, args []string) int {
+			return s.run(cmd, args, action, method)
+		}),
+	}
+}
+
+func (s *sortCmd) run(cmd *cobra.Command, args []string,
+	action exifsort.Action, method exifsort.Method) int {
+	s.src = args[0]
+	s.dst = args[1]
+	s.method = method
+	s.action = action
+	s.jobs, _ = cmd.Flags().GetInt("jobs")
+	s.sniff, _ = cmd.Flags().GetBool("sniff")
+	s.opts = getGlobalOptions(cmd)
+
+	err := s.selectionParse(cmd.Flags())
+	if err != nil {
+		return printInvalid(s.opts, "sort", err)
+	}
+
+	// We create directory before executing.
+	// It would not be cool to spend a lot of time
+	// then fail due to perms or previous output
+	// directory.
+	err = outputCreate(s.dst)
+	if err != nil {
+		return printInvalid(s.opts, "sort", err)
+	}
+
+	return s.sortExecute()
+}
+
+// selectionParse reads the flags that choose what is sorted and where to.
+func (s *sortCmd) selectionParse(flags *pflag.FlagSet) error {
+	var err error
+
+	s.filters, err = getFilters(flags)
+	if err != nil {
+		return err
 	}
+
+	s.rng, err = getDateRange(flags)
+	if err != nil {
+		return err
+	}
+
+	s.cameras, _ = flags.GetStringSlice("camera")
+
+	s.near, err = getNear(flags)
+	if err != nil {
+		return err
+	}
+
+	layoutStr, _ := flags.GetString("layout")
+
+	s.layout, err = exifsort.LayoutParse(layoutStr)
+	if err != nil {
+		return err
+	}
+
+	// Only read a gazetteer when we need one, they can be big.
+	gazetteerPath, _ := flags.GetString("gazetteer")
+	if gazetteerPath != "" && s.layout == exifsort.LayoutLocation {
+		s.gazetteer, err = exifsort.LoadGazetteer(gazetteerPath)
+		if err != nil {
+			return err
+		}
+	}
+
+	s.eventGap, _ = flags.GetDuration("event-gap")
+	if s.eventGap <= 0 {
+		return fmt.Errorf("invalid event gap %s", s.eventGap)
+	}
+
+	labelsPath, _ := flags.GetString("event-labels")
+	if labelsPath != "" && s.method == exifsort.MethodEvent {
+		s.labels, err = exifsort.LoadEventLabels(labelsPath)
+		if err != nil {
+			return err
+		}
+	}
+
+	s.policies, err = getPolicies(flags)
+	if err != nil {
+		return err
+	}
+
+	s.verify, _ = flags.GetBool("verify")
+	s.catalog, _ = flags.GetBool("catalog")
+	s.manifest, err = getManifest(flags, s.dst)
+
+	return err
+}
+
+// runDefault sorts with the action and method from flags or config.
+func (s *sortCmd) runDefault(cmd *cobra.Command, args []string) int {
+	actionStr, _ := cmd.Flags().GetString("action")
+	methodStr, _ := cmd.Flags().GetString("method")
+
+	action, err := exifsort.ActionParse(actionStr)
+	if err != nil {
+		return printInvalid(getGlobalOptions(cmd), "sort", err)
+	}
+
+	method, err := exifsort.MethodParse(methodStr)
+	if err != nil {
+		return printInvalid(getGlobalOptions(cmd), "sort", err)
+	}
+
+	return s.run(cmd, args, action, method)
 }
 
 func (s *sortCmd) newSortActionCmd(action exifsort.Action) *cobra.Command {
@@ -190,14 +426,48 @@ func (s *sortCmd) newSortActionCmd(action exifsort.Action) *cobra.Command {
 	return actionCmd
 }
 
+// setSortFlags adds the flags read by sortCmd.run and selectionParse.
+func setSortFlags(flags *pflag.FlagSet) {
+	setJobsFlag(flags)
+	setSniffFlag(flags)
+	setFilterFlags(flags)
+	setDateRangeFlags(flags)
+	setCameraFlag(flags)
+	setNearFlag(flags)
+	flags.String("layout", exifsort.LayoutDate.String(),
+		"directories below the method ones: \"date\", \"device\" or \"location\".")
+	flags.String("gazetteer", "",
+		"GeoNames file, such as cities500.txt, naming places for --layout location.")
+	flags.Duration("event-gap", exifsort.EventGap,
+		"longest time between two photos of one event for the event method.")
+	flags.String("event-labels", "",
+		"CSV file of \"<first date>,<last date>,<label>\" naming events.")
+	setManifestFlag(flags)
+	setCatalogFlag(flags)
+	setVerifyFlag(flags)
+	setPolicyFlags(flags)
+}
+
 func newSortRootCmd(s *sortCmd) *cobra.Command {
+	const numSortCmdArgs = 2
+
 	rootCmd := &cobra.Command{
 		Use:   "sort",
 		Short: "Accepts an input directory and will sort media by time created",
 		// Very long help message so we moved it to a func.
 		Long: s.sortLongHelp(),
+		Args: cobra.ExactArgs(numSortCmdArgs),
+		RunE: runStatus(s.runDefault),
 	}
 
+	rootCmd.Flags().String("action", exifsort.ActionCopy.String(),
+		"action when not given as an argument.")
+	rootCmd.Flags().String("method", exifsort.MethodMonth.String(),
+		"method when not given as an argument.")
+
+	// Every action and method subcommand shares these.
+	setSortFlags(rootCmd.PersistentFlags())
+
 	for _, action := range exifsort.Actions() {
 		actionCmd := s.newSortActionCmd(action)
 		rootCmd.AddCommand(actionCmd)
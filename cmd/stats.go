@@ -0,0 +1,164 @@
+/*
+Copyright © 2020 Michael Rubin <mhr@neverthere.org>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	exifsort "github.com/matchstick/exifsort/lib"
+	"github.com/spf13/cobra"
+)
+
+// statsBarWidth is how many characters the longest histogram bar is.
+const statsBarWidth = 40
+
+func statsLongHelp() string {
+	return `Print the histograms of a library of media.
+
+	exifsort stats [flags] <src>
+
+	src
+	directory, such as a sorted one, or json file from scan to describe
+
+	stats counts the media by year, month, camera, extension and where its
+	time came from, and adds up its size. It also lists suspicious days whose
+	media likely have the wrong time:
+
+	epoch
+	media dated on a day clocks start from, such as 1970-01-01
+
+	modtime
+	a day where most media got its time from its modtime
+
+	volume
+	a day with many times the media of a usual day
+
+	It exits with 3 when the size of some media could not be read.
+	`
+}
+
+type statsRow struct {
+	name  string
+	count int
+}
+
+// statsRows returns the rows of counts, in name order when byName is set
+// and most first otherwise.
+func statsRows(counts map[string]int, byName bool) []statsRow {
+	rows := make([]statsRow, 0, len(counts))
+
+	for name, count := range counts {
+		rows = append(rows, statsRow{name, count})
+	}
+
+	sort.Slice(rows, func(i, j int) bool {
+		if !byName && rows[i].count != rows[j].count {
+			return rows[i].count > rows[j].count
+		}
+
+		return rows[i].name < rows[j].name
+	})
+
+	return rows
+}
+
+func statsHistogram(title string, counts map[string]int, byName bool) {
+	fmt.Printf("## %s:\n", title)
+
+	most := 0
+
+	for _, count := range counts {
+		if count > most {
+			most = count
+		}
+	}
+
+	for _, row := range statsRows(counts, byName) {
+		bar := strings.Repeat("#", (row.count*statsBarWidth+most-1)/most)
+		fmt.Printf("##\t%-24s %8d %s\n", row.name, row.count, bar)
+	}
+}
+
+func statsSummary(stats exifsort.Stats) {
+	fmt.Printf("## Files: %d\n", stats.Files)
+	fmt.Printf("## Bytes: %d (%s)\n", stats.Bytes, formatBytes(stats.Bytes))
+	fmt.Printf("## Exif Errors: %d\n", stats.ExifErrors)
+	fmt.Printf("## Scan Errors: %d\n", stats.ScanErrors)
+
+	statsHistogram("Years", stats.Years, true)
+	statsHistogram("Months", stats.Months, true)
+	statsHistogram("Cameras", stats.Cameras, false)
+	statsHistogram("Extensions", stats.Extensions, false)
+	statsHistogram("Sources", stats.Sources, false)
+
+	fmt.Printf("## Suspicious days: %d\n", len(stats.Spikes))
+
+	for _, spike := range stats.Spikes {
+		fmt.Printf("##\t%s: %d files, %d by modtime (%s)\n",
+			spike.Day, spike.Count, spike.ModTime, spike.Reason)
+	}
+
+	if len(stats.Unreadable) != 0 {
+		fmt.Println("## Unreadable were:")
+
+		for path, err := range stats.Unreadable {
+			fmt.Printf("##\t%s: (%s)\n", path, err)
+		}
+	}
+}
+
+func statsExecute(cmd *cobra.Command, src string) int {
+	opts := getGlobalOptions(cmd)
+	jobs, _ := cmd.Flags().GetInt("jobs")
+	r := &report{Command: "stats"}
+
+	scanner, err := loadScan(opts, src, jobs)
+	if err != nil {
+		printError(opts, r, err, func() {
+			fmt.Printf("\"%s\" error (%s)\n", src, err.Error())
+		})
+
+		return exitInvalid
+	}
+
+	stats := exifsort.NewStats(&scanner)
+	r.Stats = newStatsReport(src, stats)
+
+	emitReport(opts, r, func() { statsSummary(stats) })
+
+	return perFileStatus(len(stats.Unreadable))
+}
+
+func newStatsCmd() *cobra.Command {
+	const numStatsCmdArgs = 1
+
+	statsCmd := &cobra.Command{
+		Use:   "stats",
+		Short: "Prints histograms of a library by date, camera and type",
+		Long:  statsLongHelp(),
+		Args:  cobra.ExactArgs(numStatsCmdArgs),
+		RunE: runStatus(func(cmd *cobra.Command, args []string) int {
+			return statsExecute(cmd, args[0])
+		}),
+	}
+
+	setJobsFlag(statsCmd.Flags())
+
+	return statsCmd
+}
@@ -0,0 +1,166 @@
+/*
+Copyright © 2020 Michael Rubin <mhr@neverthere.org>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"fmt"
+
+	exifsort "github.com/matchstick/exifsort/lib"
+	"github.com/spf13/cobra"
+)
+
+func verifyLongHelp() string {
+	return `Check every file of a sorted directory is in the right place.
+
+	exifsort verify <dir> [--fix] [--jobs <num>]
+
+	dir
+	directory sorted by sort or merged into, by any method
+
+	Every file is read again and its time compared with the directory it is
+	in. Media in the wrong directory is misplaced, --fix moves it to where it
+	belongs. Files that can't be read and files that are not media are
+	reported too. Media without exif or movie time is only dated by its
+	modtime, which a copy changes, so it is reported as undated.
+
+	--fix keeps the manifest and catalog of the directory up to date when it
+	has them.
+	`
+}
+
+func verifySummary(v *exifsort.Verifier) {
+	fmt.Printf("## Sorted by: %s\n", v.Method)
+	fmt.Printf("## Verified: %d\n", v.Verified)
+	fmt.Printf("## Undated: %d\n", len(v.Undated))
+	fmt.Printf("## Misplaced: %d\n", len(v.Misplaced))
+
+	for path, newPath := range v.Misplaced {
+		fmt.Printf("##\t%s belongs in %s\n", path, newPath)
+	}
+
+	if len(v.Moved) != 0 {
+		fmt.Printf("## Moved: %d\n", len(v.Moved))
+	}
+
+	fmt.Printf("## Strays: %d\n", len(v.Strays))
+
+	for _, path := range sortedPaths(v.Strays) {
+		fmt.Printf("##\t%s\n", path)
+	}
+
+	fmt.Printf("## Unreadable: %d\n", len(v.Unreadable))
+
+	for path, err := range v.Unreadable {
+		fmt.Printf("##\t%s: (%s)\n", path, err)
+	}
+
+	if len(v.Errors) != 0 {
+		fmt.Println("## Fix Errors were:")
+
+		for path, err := range v.Errors {
+			fmt.Printf("##\t%s: (%s)\n", path, err)
+		}
+	}
+}
+
+// verifyFix moves the misplaced media and keeps the manifest and catalog of
+// the directory, if it has them, up to date.
+func verifyFix(opts globalOptions, v *exifsort.Verifier, root string) error {
+	var err error
+
+	if exifsort.ManifestExists(root) {
+		v.Manifest, err = exifsort.LoadManifest(root)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Only keep a catalog when root has one already.
+	v.Catalog, err = openCatalog(false, root)
+	if err != nil {
+		return err
+	}
+
+	observer, finish := stageObserver(opts, "Fixing",
+		func() int { return len(v.Misplaced) })
+	err = v.Fix(observer)
+
+	finish()
+
+	if err == nil && v.Manifest != nil {
+		err = v.Manifest.Save()
+	}
+
+	return closeCatalog(v.Catalog, err)
+}
+
+func verifyExecute(cmd *cobra.Command, root string) int {
+	opts := getGlobalOptions(cmd)
+	fix, _ := cmd.Flags().GetBool("fix")
+
+	v := exifsort.NewVerifier(root)
+	v.Jobs, _ = cmd.Flags().GetInt("jobs")
+	v.Extensions = opts.exts
+	v.Rules = opts.rules
+
+	observer, finish := stageObserver(opts, "Verifying",
+		func() int { return countFiles(root) })
+	err := v.Verify(observer)
+
+	finish()
+
+	r := &report{Command: "verify"}
+
+	if err != nil {
+		printError(opts, r, err, func() { fmt.Printf("%s\n", err.Error()) })
+		return exitInvalid
+	}
+
+	if fix {
+		err = verifyFix(opts, v, root)
+	}
+
+	r.Verify = newVerifyReport(v)
+
+	if err != nil {
+		printError(opts, r, err, func() { fmt.Printf("%s\n", err.Error()) })
+		return exitFatal
+	}
+
+	emitReport(opts, r, func() { verifySummary(v) })
+
+	return verifyStatus(opts, v)
+}
+
+func newVerifyCmd() *cobra.Command {
+	const numVerifyCmdArgs = 1
+
+	verifyCmd := &cobra.Command{
+		Use:   "verify",
+		Short: "Check the files of a sorted directory are in the right place",
+		Long:  verifyLongHelp(),
+		Args:  cobra.ExactArgs(numVerifyCmdArgs),
+		RunE: runStatus(func(cmd *cobra.Command, args []string) int {
+			return verifyExecute(cmd, args[0])
+		}),
+	}
+
+	verifyCmd.Flags().Bool("fix", false, "move misplaced media to where it belongs.")
+	setJobsFlag(verifyCmd.Flags())
+
+	return verifyCmd
+}
@@ -1,6 +1,6 @@
 module github.com/matchstick/exifsort
 
-go 1.14
+go 1.16
 
 require (
 	github.com/dsoprea/go-exif-knife v0.0.0-20200710185536-15e9cd8b868b
@@ -9,8 +9,11 @@ require (
 	github.com/google/go-cmp v0.4.0
 	github.com/hectane/go-acl v0.0.0-20190604041725-da78bae5fc95
 	github.com/mitchellh/go-homedir v1.1.0
+	github.com/spf13/cast v1.3.0
 	github.com/spf13/cobra v1.0.0
+	github.com/spf13/pflag v1.0.5
 	github.com/spf13/viper v1.7.0
 	github.com/udhos/equalfile v0.3.0
+	go.etcd.io/bbolt v1.3.5
 	golang.org/x/tools v0.0.0-20200717024301-6ddee64345a6 // indirect
 )
@@ -14,13 +14,21 @@ const (
 	MethodMonth
 	// MethodDay : dst -> year-> month -> day -> media
 	MethodDay
+	// MethodEvent : dst -> year -> first day-last day of event -> media
+	MethodEvent
+	// MethodWeek : dst -> ISO year -> ISO week -> media
+	MethodWeek
+	// MethodQuarter : dst -> year -> quarter -> media
+	MethodQuarter
+	// MethodYearMonth : dst -> year-month -> media
+	MethodYearMonth
 	// MethodNone : Error Value
 	MethodNone
 )
 
 // Returns name of method value (all lower case).
 func (m Method) String() string {
-	return [...]string{"year", "month", "day", "none"}[m]
+	return [...]string{"year", "month", "day", "event", "week", "quarter", "yearmonth", "none"}[m]
 }
 
 // Methods returns all method values used excluding MethodNone.
@@ -29,6 +37,10 @@ func Methods() []Method {
 		MethodYear,
 		MethodMonth,
 		MethodDay,
+		MethodEvent,
+		MethodWeek,
+		MethodQuarter,
+		MethodYearMonth,
 	}
 }
 
@@ -0,0 +1,170 @@
+package exifsort
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/dsoprea/go-exif/v2"
+)
+
+// DeviceUnknown is the device folder of media without a camera make or model.
+const DeviceUnknown = "Unknown Device"
+
+// MediaInfo is what exif data tells us about the device that took a photo and
+// where.
+type MediaInfo struct {
+	Make      string       `json:",omitempty"`
+	Model     string       `json:",omitempty"`
+	LensModel string       `json:",omitempty"`
+	GPS       *Coordinates `json:",omitempty"`
+}
+
+// IsZero reports if nothing is known about the device or location.
+func (i MediaInfo) IsZero() bool {
+	return i == MediaInfo{}
+}
+
+// Device returns the make and model such as "Canon EOS R6". Models that
+// start with their make don't repeat it. It returns DeviceUnknown if neither
+// is known.
+func (i MediaInfo) Device() string {
+	var device string
+
+	switch {
+	case i.Make == "":
+		device = i.Model
+	case i.Model == "":
+		device = i.Make
+	case strings.HasPrefix(strings.ToLower(i.Model), strings.ToLower(i.Make)):
+		device = i.Model
+	default:
+		device = i.Make + " " + i.Model
+	}
+
+	if device == "" {
+		return DeviceUnknown
+	}
+
+	return dirName(device)
+}
+
+// dirName makes str safe to use as a directory name.
+func dirName(str string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r == filepath.Separator {
+			return '_'
+		}
+
+		return r
+	}, str)
+}
+
+func exifTagString(ifd *exif.Ifd, name string) string {
+	results, err := ifd.FindTagWithName(name)
+	if err != nil || len(results) == 0 {
+		return ""
+	}
+
+	value, err := results[0].Value()
+	if err != nil {
+		return ""
+	}
+
+	str, ok := value.(string)
+	if !ok {
+		return ""
+	}
+
+	return strings.TrimSpace(strings.TrimRight(str, "\x00"))
+}
+
+func exifInfoFromRootIfd(rootIfd *exif.Ifd) MediaInfo {
+	info := MediaInfo{
+		Make:  exifTagString(rootIfd, "Make"),
+		Model: exifTagString(rootIfd, "Model"),
+	}
+
+	exifIfd, err := exif.FindIfdFromRootIfd(rootIfd, "IFD/Exif")
+	if err == nil {
+		info.LensModel = exifTagString(exifIfd, "LensModel")
+	}
+
+	info.GPS = exifGPS(rootIfd)
+
+	return info
+}
+
+// ExifInfoGet returns the camera Make, Model, LensModel and GPS coordinates
+// in the exif data of the file at path.
+func ExifInfoGet(path string) (MediaInfo, error) {
+	rootIfd, err := exifRootIfd(path)
+	if err != nil {
+		return MediaInfo{}, err
+	}
+
+	return exifInfoFromRootIfd(rootIfd), nil
+}
+
+// Cameras select media by device. Media matches when its Device contains any
+// of the names, ignoring case. "unknown" matches media without a device. No
+// names match all media.
+type Cameras []string
+
+// Match reports if info is from one of the cameras.
+func (c Cameras) Match(info MediaInfo) bool {
+	if len(c) == 0 {
+		return true
+	}
+
+	device := strings.ToLower(info.Device())
+
+	for _, name := range c {
+		if strings.Contains(device, strings.ToLower(name)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Layout decides the directories media is sorted into below the ones of its
+// method.
+type Layout int
+
+const (
+	// LayoutDate : dst -> method directories -> media
+	LayoutDate Layout = iota
+	// LayoutDevice : dst -> method directories -> device -> media
+	LayoutDevice
+	// LayoutLocation : dst -> method directories + " " + place -> media
+	LayoutLocation
+	// LayoutNone : Error Value
+	LayoutNone
+)
+
+// Returns name of layout value (all lower case).
+func (l Layout) String() string {
+	return [...]string{"date", "device", "location", "none"}[l]
+}
+
+// Layouts returns all layout values used excluding LayoutNone.
+func Layouts() []Layout {
+	return []Layout{
+		LayoutDate,
+		LayoutDevice,
+		LayoutLocation,
+	}
+}
+
+// LayoutParse returns Layout from string (must be lower case). Returns
+// LayoutNone if invalid.
+func LayoutParse(str string) (Layout, error) {
+	for _, val := range Layouts() {
+		if str == val.String() {
+			return val, nil
+		}
+	}
+
+	return LayoutNone, fmt.Errorf("invalid layout %s", str)
+}
@@ -0,0 +1,83 @@
+package exifsort
+
+import (
+	"testing"
+)
+
+func TestMediaInfoDevice(t *testing.T) {
+	expected := map[MediaInfo]string{
+		{Make: "Apple", Model: "iPhone 11 Pro"}: "Apple iPhone 11 Pro",
+		{Make: "Canon", Model: "Canon EOS R6"}:  "Canon EOS R6",
+		{Make: "CANON", Model: "Canon EOS R6"}:  "Canon EOS R6",
+		{Make: "NIKON CORPORATION"}:             "NIKON CORPORATION",
+		{Model: "DMC-GX85"}:                     "DMC-GX85",
+		{Make: "Acme", Model: "Cam 1/2"}:        "Acme Cam 1_2",
+		{LensModel: "EF 50mm"}:                  DeviceUnknown,
+		{}:                                      DeviceUnknown,
+	}
+
+	for info, device := range expected {
+		if info.Device() != device {
+			t.Errorf("%v: expected %s got %s\n", info, device, info.Device())
+		}
+	}
+}
+
+func TestCamerasMatch(t *testing.T) {
+	canon := MediaInfo{Make: "Canon", Model: "Canon EOS R6"}
+	phone := MediaInfo{Make: "Apple", Model: "iPhone 11 Pro"}
+
+	var all Cameras
+	if !all.Match(canon) || !all.Match(MediaInfo{}) {
+		t.Errorf("Expected no cameras to match everything\n")
+	}
+
+	cameras := Cameras{"eos", "unknown"}
+
+	if !cameras.Match(canon) {
+		t.Errorf("Expected %v to match\n", canon)
+	}
+
+	if !cameras.Match(MediaInfo{}) {
+		t.Errorf("Expected unknown to match media without a device\n")
+	}
+
+	if cameras.Match(phone) {
+		t.Errorf("Expected %v not to match\n", phone)
+	}
+}
+
+func TestExifInfoGet(t *testing.T) {
+	info, err := ExifInfoGet(exifPath)
+	if err != nil {
+		t.Fatalf("Unexpected error %s\n", err.Error())
+	}
+
+	if info.Make != "Apple" || info.Model != "iPhone 11 Pro" {
+		t.Errorf("Unexpected info %v\n", info)
+	}
+
+	info, err = ExifInfoGet(noExifPath)
+	if err == nil && !info.IsZero() {
+		t.Errorf("Expected no device for %s got %v\n", noExifPath, info)
+	}
+
+	_, err = ExifInfoGet(noRootExifPath)
+	if err == nil {
+		t.Errorf("Expected error for %s\n", noRootExifPath)
+	}
+}
+
+func TestLayoutParse(t *testing.T) {
+	for _, layout := range Layouts() {
+		parsed, err := LayoutParse(layout.String())
+		if err != nil || parsed != layout {
+			t.Errorf("Expected %s got %s\n", layout, parsed)
+		}
+	}
+
+	_, err := LayoutParse("none")
+	if err == nil {
+		t.Errorf("Expected error for none\n")
+	}
+}
@@ -0,0 +1,510 @@
+package exifsort
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// CatalogName is the name of the catalog database at the root of a sorted
+// directory.
+const CatalogName = ".exifsort.db"
+
+// catalogTimeout is how long we wait for another exifsort to close the
+// catalog before giving up.
+const catalogTimeout = 5 * time.Second
+
+// The buckets of the catalog. catalogHashes has a "<sha256>\x00<path>" key
+// for every entry so we can look media up by its contents.
+const (
+	catalogMedia  = "media"
+	catalogHashes = "sha256"
+)
+
+// CatalogEntry is what the catalog knows about one media file.
+type CatalogEntry struct {
+	// Path is relative to the root with "/" separators.
+	Path   string `json:"-"`
+	Size   int64
+	SHA256 string
+	// Time is the time the media was sorted by, zero if only the directory
+	// it was resorted from told it.
+	Time time.Time
+	// ModTime tells Update if the file changed since it was added.
+	ModTime time.Time
+	// Source is the path the media was transferred from, if it was.
+	Source string `json:",omitempty"`
+	MediaInfo
+}
+
+// CatalogUpdate is what Catalog.Update did.
+type CatalogUpdate struct {
+	// Added are the files that were not in the catalog or changed.
+	Added []string
+	// Removed are the files in the catalog that are gone.
+	Removed []string
+	// Unchanged is the number of files the catalog had right.
+	Unchanged int
+	// Errors holds the files that could not be read.
+	Errors map[string]string
+}
+
+// CatalogLookup is what Catalog.Lookup found.
+type CatalogLookup struct {
+	// Archived maps the media the directory has to the file it has.
+	Archived map[string]string
+	// NotArchived is the media the directory does not have.
+	NotArchived []string
+	// Errors holds the files that could not be read.
+	Errors map[string]string
+}
+
+// Catalog is a database at the root of a sorted directory of the media in it.
+// Sorter, Merger and Verifier keep it up to date so questions like "is this
+// already sorted?" are lookups instead of walks. It is saved in CatalogName
+// and must be closed.
+type Catalog struct {
+	db   *bolt.DB
+	root string
+}
+
+func hashKey(sum string, key string) []byte {
+	return []byte(sum + "\x00" + key)
+}
+
+// catalogGet returns the entry of key in tx.
+func catalogGet(tx *bolt.Tx, key string) (CatalogEntry, bool, error) {
+	entry := CatalogEntry{Path: key}
+
+	value := tx.Bucket([]byte(catalogMedia)).Get([]byte(key))
+	if value == nil {
+		return entry, false, nil
+	}
+
+	err := json.Unmarshal(value, &entry)
+
+	return entry, err == nil, err
+}
+
+// catalogRemove deletes the entry of key from tx.
+func catalogRemove(tx *bolt.Tx, key string) error {
+	entry, found, err := catalogGet(tx, key)
+	if err != nil || !found {
+		return err
+	}
+
+	err = tx.Bucket([]byte(catalogHashes)).Delete(hashKey(entry.SHA256, key))
+	if err != nil {
+		return err
+	}
+
+	return tx.Bucket([]byte(catalogMedia)).Delete([]byte(key))
+}
+
+// catalogPut stores entry in tx, replacing what was at its path.
+func catalogPut(tx *bolt.Tx, entry CatalogEntry) error {
+	err := catalogRemove(tx, entry.Path)
+	if err != nil {
+		return err
+	}
+
+	value, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Bucket([]byte(catalogMedia)).Put([]byte(entry.Path), value)
+	if err != nil {
+		return err
+	}
+
+	return tx.Bucket([]byte(catalogHashes)).Put(hashKey(entry.SHA256, entry.Path), nil)
+}
+
+// Add stores media path of the directory, transferred from source, sorted by
+// t and taken by info.
+func (c *Catalog) Add(path string, source string, t time.Time, info MediaInfo) error {
+	key, err := rootKey(c.root, path)
+	if err != nil {
+		return err
+	}
+
+	stat, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	sum, err := hashFile(path)
+	if err != nil {
+		return err
+	}
+
+	entry := CatalogEntry{key, sum.Size, sum.SHA256, t, stat.ModTime(), source, info}
+
+	return c.db.Update(func(tx *bolt.Tx) error { return catalogPut(tx, entry) })
+}
+
+// addScanned stores media path with the time and device a scan finds.
+func (c *Catalog) addScanned(path string, source string, exts Extensions) error {
+	scanner := NewScanner()
+
+	result := scanner.scanPath(path, exts)
+	if result.err != nil {
+		return result.err
+	}
+
+	return c.Add(path, source, result.time, result.info)
+}
+
+// Moved updates the entry of media moved from oldPath to newPath within the
+// directory. Media the catalog did not have is scanned and added.
+func (c *Catalog) Moved(oldPath string, newPath string) error {
+	oldKey, err := rootKey(c.root, oldPath)
+	if err != nil {
+		return err
+	}
+
+	newKey, err := rootKey(c.root, newPath)
+	if err != nil {
+		return err
+	}
+
+	stat, err := os.Stat(newPath)
+	if err != nil {
+		return err
+	}
+
+	var found bool
+
+	err = c.db.Update(func(tx *bolt.Tx) error {
+		var entry CatalogEntry
+
+		entry, found, err = catalogGet(tx, oldKey)
+		if err != nil || !found {
+			return err
+		}
+
+		err = catalogRemove(tx, oldKey)
+		if err != nil {
+			return err
+		}
+
+		entry.Path = newKey
+		entry.ModTime = stat.ModTime()
+
+		return catalogPut(tx, entry)
+	})
+
+	if err == nil && !found {
+		err = c.addScanned(newPath, "", NewExtensions())
+	}
+
+	return err
+}
+
+// Remove forgets path.
+func (c *Catalog) Remove(path string) error {
+	key, err := rootKey(c.root, path)
+	if err != nil {
+		return err
+	}
+
+	return c.db.Update(func(tx *bolt.Tx) error { return catalogRemove(tx, key) })
+}
+
+// Forget removes the media of paths that is in the directory, other paths are
+// ignored.
+func (c *Catalog) Forget(paths []string) error {
+	return c.db.Update(func(tx *bolt.Tx) error {
+		for _, path := range paths {
+			key, err := rootKey(c.root, path)
+			if err != nil {
+				// Not in the directory.
+				continue
+			}
+
+			err = catalogRemove(tx, key)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// Get returns the entry of path.
+func (c *Catalog) Get(path string) (CatalogEntry, bool, error) {
+	key, err := rootKey(c.root, path)
+	if err != nil {
+		return CatalogEntry{}, false, err
+	}
+
+	var (
+		entry CatalogEntry
+		found bool
+	)
+
+	err = c.db.View(func(tx *bolt.Tx) error {
+		entry, found, err = catalogGet(tx, key)
+		return err
+	})
+
+	return entry, found, err
+}
+
+// withSum returns the paths of the media in the directory with the SHA-256
+// sum.
+func (c *Catalog) withSum(sum string) []string {
+	var paths []string
+
+	prefix := hashKey(sum, "")
+
+	_ = c.db.View(func(tx *bolt.Tx) error {
+		cursor := tx.Bucket([]byte(catalogHashes)).Cursor()
+
+		for key, _ := cursor.Seek(prefix); bytes.HasPrefix(key, prefix); key, _ = cursor.Next() {
+			rel := filepath.FromSlash(string(key[len(prefix):]))
+			paths = append(paths, filepath.Join(c.root, rel))
+		}
+
+		return nil
+	})
+
+	return paths
+}
+
+// Find returns the path of the media in the directory with the SHA-256 sum.
+func (c *Catalog) Find(sum string) (string, bool) {
+	paths := c.withSum(sum)
+	if len(paths) == 0 {
+		return "", false
+	}
+
+	return paths[0], true
+}
+
+// Archived returns the media in the directory with the same contents as
+// path, other than path itself.
+func (c *Catalog) Archived(path string) (string, bool, error) {
+	sum, err := hashFile(path)
+	if err != nil {
+		return "", false, err
+	}
+
+	pathInfo, err := os.Stat(path)
+	if err != nil {
+		return "", false, err
+	}
+
+	for _, archived := range c.withSum(sum.SHA256) {
+		// The catalog may not know the file is gone.
+		archivedInfo, err := os.Stat(archived)
+		if err == nil && !os.SameFile(pathInfo, archivedInfo) {
+			return archived, true, nil
+		}
+	}
+
+	return "", false, nil
+}
+
+// Lookup looks up the media of paths, files or directories, in the catalog.
+func (c *Catalog) Lookup(paths []string, exts Extensions, rules *Rules) (CatalogLookup, error) {
+	lookup := CatalogLookup{
+		Archived: make(map[string]string),
+		Errors:   make(map[string]string),
+	}
+	exts = extensionsOrDefault(exts)
+
+	for _, root := range paths {
+		err := rulesOrDefault(rules).Walk(root,
+			func(path string, info os.FileInfo, err error) error {
+				if err == nil && (info.IsDir() || exts.Category(path) == CategorySkip) {
+					return nil
+				}
+
+				var (
+					archived string
+					found    bool
+				)
+
+				if err == nil {
+					archived, found, err = c.Archived(path)
+				}
+
+				switch {
+				case err != nil:
+					lookup.Errors[path] = err.Error()
+				case found:
+					lookup.Archived[path] = archived
+				default:
+					lookup.NotArchived = append(lookup.NotArchived, path)
+				}
+
+				return nil
+			})
+		if err != nil {
+			return lookup, err
+		}
+	}
+
+	return lookup, nil
+}
+
+// Entries returns every entry sorted by path.
+func (c *Catalog) Entries() ([]CatalogEntry, error) {
+	var entries []CatalogEntry
+
+	err := c.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(catalogMedia)).ForEach(func(key []byte, value []byte) error {
+			entry := CatalogEntry{Path: string(key)}
+
+			err := json.Unmarshal(value, &entry)
+			if err != nil {
+				return err
+			}
+
+			entries = append(entries, entry)
+
+			return nil
+		})
+	})
+
+	return entries, err
+}
+
+// Method returns the method the media in the catalog is sorted by, as
+// mergeCheck would find walking the directory.
+func (c *Catalog) Method() (Method, error) {
+	entries, err := c.Entries()
+	if err != nil {
+		return MethodNone, err
+	}
+
+	method := MethodNone
+	layout := LayoutDate
+
+	for _, entry := range entries {
+		path := filepath.Join(c.root, filepath.FromSlash(entry.Path))
+
+		method, layout, err = mergeAddMethod(c.root, path, method, layout)
+		if err != nil {
+			return MethodNone, err
+		}
+	}
+
+	return method, nil
+}
+
+// Update walks the directory and adds the media that is not in the catalog
+// or changed since it was added, and forgets the media that is gone.
+func (c *Catalog) Update(exts Extensions, rules *Rules, observer Observer) (CatalogUpdate, error) {
+	update := CatalogUpdate{Errors: make(map[string]string)}
+	seen := make(map[string]bool)
+	exts = extensionsOrDefault(exts)
+
+	err := rulesOrDefault(rules).Walk(c.root,
+		func(path string, info os.FileInfo, err error) error {
+			switch {
+			case err != nil:
+				update.Errors[path] = err.Error()
+				return nil
+			case info.IsDir():
+				return nil
+			case exts.Category(path) == CategorySkip:
+				observer.Skipped(path)
+				return nil
+			}
+
+			entry, found, err := c.Get(path)
+			if err != nil {
+				return err
+			}
+
+			seen[entry.Path] = true
+
+			if found && entry.Size == info.Size() && entry.ModTime.Equal(info.ModTime()) {
+				update.Unchanged++
+				return nil
+			}
+
+			err = c.addScanned(path, entry.Source, exts)
+			if err != nil {
+				update.Errors[path] = err.Error()
+				observer.Error(path, err)
+
+				return nil
+			}
+
+			update.Added = append(update.Added, path)
+			observer.FileScanned(path, info.ModTime())
+
+			return nil
+		})
+	if err != nil {
+		return update, err
+	}
+
+	entries, err := c.Entries()
+	if err != nil {
+		return update, err
+	}
+
+	for _, entry := range entries {
+		if seen[entry.Path] {
+			continue
+		}
+
+		path := filepath.Join(c.root, filepath.FromSlash(entry.Path))
+
+		err = c.Remove(path)
+		if err != nil {
+			return update, err
+		}
+
+		update.Removed = append(update.Removed, path)
+	}
+
+	return update, nil
+}
+
+// Close closes the catalog. Every change is on disk when it is made.
+func (c *Catalog) Close() error {
+	return c.db.Close()
+}
+
+// CatalogExists reports if root has a catalog.
+func CatalogExists(root string) bool {
+	return exists(filepath.Join(root, CatalogName))
+}
+
+// OpenCatalog opens the catalog of the sorted directory root, creating it if
+// root has none.
+func OpenCatalog(root string) (*Catalog, error) {
+	db, err := bolt.Open(filepath.Join(root, CatalogName), 0644,
+		&bolt.Options{Timeout: catalogTimeout})
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range []string{catalogMedia, catalogHashes} {
+			_, err := tx.CreateBucketIfNotExists([]byte(name))
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &Catalog{db, root}, nil
+}
@@ -0,0 +1,223 @@
+package exifsort
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// catalogSort sorts src into dst keeping the catalog of dst.
+func catalogSort(t *testing.T, src string, dst string) *Sorter {
+	c, err := OpenCatalog(dst)
+	if err != nil {
+		t.Fatalf("Unexpected error %s\n", err.Error())
+	}
+	defer c.Close()
+
+	scanner := NewScanner()
+	_ = scanner.ScanDir(src, NopObserver{})
+
+	sorter, err := NewSorter(scanner, MethodYear, WithCatalog(c))
+	if err != nil {
+		t.Fatalf("Unexpected error %s\n", err.Error())
+	}
+
+	err = sorter.Transfer(dst, ActionCopy, NopObserver{})
+	if err != nil {
+		t.Fatalf("Unexpected error %s\n", err.Error())
+	}
+
+	return sorter
+}
+
+func TestCatalog(t *testing.T) {
+	t.Parallel()
+
+	src, older, newer := testCollisionRoot(t)
+	defer os.RemoveAll(src)
+
+	dst, _ := ioutil.TempDir("", "catalog_")
+	defer os.RemoveAll(dst)
+
+	catalogSort(t, src, dst)
+
+	if !CatalogExists(dst) {
+		t.Fatalf("Expected a catalog in %s\n", dst)
+	}
+
+	// Sorted again everything is already there.
+	sorter := catalogSort(t, src, dst)
+	if len(sorter.Duplicates) != 2 {
+		t.Errorf("Expected 2 duplicates got %v\n", sorter.Duplicates)
+	}
+
+	c, err := OpenCatalog(dst)
+	if err != nil {
+		t.Fatalf("Unexpected error %s\n", err.Error())
+	}
+	defer c.Close()
+
+	entries, err := c.Entries()
+	if err != nil || len(entries) != 2 {
+		t.Fatalf("Expected 2 entries got %v err %v\n", entries, err)
+	}
+
+	for _, entry := range entries {
+		if entry.Source != older && entry.Source != newer {
+			t.Errorf("Expected source %s or %s got %s\n", older, newer, entry.Source)
+		}
+
+		if entry.Time.IsZero() || entry.Make == "" {
+			t.Errorf("Expected a time and camera got %v\n", entry)
+		}
+	}
+
+	archived, found, err := c.Archived(older)
+	if err != nil || !found || filepath.Dir(archived) != filepath.Join(dst, "2020") {
+		t.Errorf("Expected %s archived got %s %v err %v\n", older, archived, found, err)
+	}
+
+	_, found, _ = c.Archived(noExifPath)
+	if found {
+		t.Errorf("Expected %s not archived\n", noExifPath)
+	}
+
+	lookup, err := c.Lookup([]string{src, noExifPath}, nil, nil)
+	if err != nil || len(lookup.Archived) != 2 || len(lookup.NotArchived) != 1 {
+		t.Errorf("Expected 2 archived and 1 not got %v err %v\n", lookup, err)
+	}
+}
+
+func TestCatalogUpdate(t *testing.T) {
+	t.Parallel()
+
+	src, _, _ := testCollisionRoot(t)
+	defer os.RemoveAll(src)
+
+	dst, _ := ioutil.TempDir("", "catalog_update_")
+	defer os.RemoveAll(dst)
+
+	catalogSort(t, src, dst)
+
+	// One goes missing and one is added by hand.
+	_ = os.Remove(filepath.Join(dst, "2020", "x.jpg"))
+	added := filepath.Join(dst, "2020", "added.jpg")
+	_ = copyFile(noExifPath, added)
+
+	c, err := OpenCatalog(dst)
+	if err != nil {
+		t.Fatalf("Unexpected error %s\n", err.Error())
+	}
+	defer c.Close()
+
+	update, err := c.Update(nil, nil, NopObserver{})
+	if err != nil {
+		t.Fatalf("Unexpected error %s\n", err.Error())
+	}
+
+	if len(update.Added) != 1 || update.Added[0] != added ||
+		len(update.Removed) != 1 || update.Unchanged != 1 {
+		t.Errorf("Expected 1 added, removed and unchanged got %v\n", update)
+	}
+
+	moved := filepath.Join(dst, "moved.jpg")
+	_ = os.Rename(added, moved)
+
+	err = c.Moved(added, moved)
+	if err != nil {
+		t.Fatalf("Unexpected error %s\n", err.Error())
+	}
+
+	_, found, _ := c.Get(added)
+	entry, movedFound, _ := c.Get(moved)
+
+	if found || !movedFound || entry.Path != "moved.jpg" {
+		t.Errorf("Expected %s moved to %s got %v\n", added, moved, entry)
+	}
+}
+
+func TestCatalogMethod(t *testing.T) {
+	t.Parallel()
+
+	src, _, _ := testCollisionRoot(t)
+	defer os.RemoveAll(src)
+
+	sorted, _ := ioutil.TempDir("", "catalog_sorted_")
+	defer os.RemoveAll(sorted)
+
+	dst, _ := ioutil.TempDir("", "catalog_method_")
+	defer os.RemoveAll(dst)
+
+	catalogSort(t, src, sorted)
+	catalogSort(t, src, dst)
+
+	// A file the catalog does not know would stop a walk of dst.
+	stray := filepath.Join(dst, "misc", "stray.jpg")
+	_ = os.MkdirAll(filepath.Dir(stray), 0755)
+	_ = copyFile(noExifPath, stray)
+
+	c, err := OpenCatalog(dst)
+	if err != nil {
+		t.Fatalf("Unexpected error %s\n", err.Error())
+	}
+	defer c.Close()
+
+	method, err := c.Method()
+	if err != nil || method != MethodYear {
+		t.Fatalf("Expected year got %s err %v\n", method, err)
+	}
+
+	merger := NewMerger(sorted, dst, ActionCopy, "")
+	merger.Catalog = c
+
+	err = merger.Merge(NopObserver{})
+	if err != nil {
+		t.Errorf("Unexpected error %s\n", err.Error())
+	}
+
+	// Once it knows the file it is not sorted any more.
+	err = c.Add(stray, "", testDate(2020, 4, 27), MediaInfo{})
+	if err != nil {
+		t.Fatalf("Unexpected error %s\n", err.Error())
+	}
+
+	_, err = c.Method()
+	if err == nil {
+		t.Errorf("Expected error for %s\n", stray)
+	}
+}
+
+func TestCatalogForget(t *testing.T) {
+	t.Parallel()
+
+	src, _, _ := testCollisionRoot(t)
+	defer os.RemoveAll(src)
+
+	dst, _ := ioutil.TempDir("", "catalog_forget_")
+	defer os.RemoveAll(dst)
+
+	catalogSort(t, src, dst)
+
+	c, err := OpenCatalog(dst)
+	if err != nil {
+		t.Fatalf("Unexpected error %s\n", err.Error())
+	}
+	defer c.Close()
+
+	entries, _ := c.Entries()
+	gone := filepath.Join(dst, filepath.FromSlash(entries[0].Path))
+
+	// Paths outside dst are not in the catalog to forget.
+	err = c.Forget([]string{gone, noExifPath})
+	if err != nil {
+		t.Fatalf("Unexpected error %s\n", err.Error())
+	}
+
+	_, found, _ := c.Get(gone)
+	left, _ := c.Entries()
+
+	if found || len(left) != len(entries)-1 {
+		t.Errorf("Expected %s forgotten got %v\n", gone, left)
+	}
+}
@@ -0,0 +1,129 @@
+package exifsort
+
+import (
+	"fmt"
+	"time"
+)
+
+// DateRange selects media by time. Times at or after After and before Before
+// are in the range. A zero After or Before leaves that end open.
+type DateRange struct {
+	After  time.Time
+	Before time.Time
+}
+
+// IsZero reports if r selects every time.
+func (r DateRange) IsZero() bool {
+	return r.After.IsZero() && r.Before.IsZero()
+}
+
+// Contains reports if t is in r.
+func (r DateRange) Contains(t time.Time) bool {
+	if !r.After.IsZero() && t.Before(r.After) {
+		return false
+	}
+
+	if !r.Before.IsZero() && !t.Before(r.Before) {
+		return false
+	}
+
+	return true
+}
+
+// ContainsPeriod reports if every time from start up to end is in r.
+func (r DateRange) ContainsPeriod(start time.Time, end time.Time) bool {
+	if !r.After.IsZero() && start.Before(r.After) {
+		return false
+	}
+
+	if !r.Before.IsZero() && end.After(r.Before) {
+		return false
+	}
+
+	return true
+}
+
+func (r DateRange) String() string {
+	const layout = "2006-01-02 15:04:05"
+
+	after, before := "", ""
+	if !r.After.IsZero() {
+		after = r.After.Format(layout)
+	}
+
+	if !r.Before.IsZero() {
+		before = r.Before.Format(layout)
+	}
+
+	return fmt.Sprintf("[%s, %s)", after, before)
+}
+
+// ParseDate parses a year, month or day such as "2020", "2020-04" or
+// "2020-04-27" in local time. It returns the start of the period and the start
+// of the one after it.
+func ParseDate(str string) (time.Time, time.Time, error) {
+	periods := []struct {
+		layout string
+		years  int
+		months int
+		days   int
+	}{
+		{"2006-01-02", 0, 0, 1},
+		{"2006-01", 0, 1, 0},
+		{"2006", 1, 0, 0},
+	}
+
+	for _, p := range periods {
+		start, err := time.ParseInLocation(p.layout, str, time.Local)
+		if err == nil {
+			return start, start.AddDate(p.years, p.months, p.days), nil
+		}
+	}
+
+	return time.Time{}, time.Time{},
+		fmt.Errorf("invalid date %s, expected YYYY, YYYY-MM or YYYY-MM-DD", str)
+}
+
+// ParseDateRange builds a DateRange from dates ParseDate accepts. after
+// includes its date, before excludes its date and on is every time in its
+// date. Empty strings are not used. on can not be combined with the others.
+func ParseDateRange(after string, before string, on string) (DateRange, error) {
+	var r DateRange
+
+	if on != "" {
+		if after != "" || before != "" {
+			return r, fmt.Errorf("on can not be used with after or before")
+		}
+
+		start, end, err := ParseDate(on)
+		if err != nil {
+			return r, err
+		}
+
+		return DateRange{start, end}, nil
+	}
+
+	if after != "" {
+		start, _, err := ParseDate(after)
+		if err != nil {
+			return r, err
+		}
+
+		r.After = start
+	}
+
+	if before != "" {
+		start, _, err := ParseDate(before)
+		if err != nil {
+			return r, err
+		}
+
+		r.Before = start
+	}
+
+	if !r.After.IsZero() && !r.Before.IsZero() && !r.After.Before(r.Before) {
+		return r, fmt.Errorf("after %s is not before %s", after, before)
+	}
+
+	return r, nil
+}
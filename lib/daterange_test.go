@@ -0,0 +1,114 @@
+package exifsort
+
+import (
+	"testing"
+	"time"
+)
+
+func testDate(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.Local)
+}
+
+func TestParseDate(t *testing.T) {
+	expected := map[string][2]time.Time{
+		"2020":       {testDate(2020, 1, 1), testDate(2021, 1, 1)},
+		"2020-04":    {testDate(2020, 4, 1), testDate(2020, 5, 1)},
+		"2020-12":    {testDate(2020, 12, 1), testDate(2021, 1, 1)},
+		"2020-02-29": {testDate(2020, 2, 29), testDate(2020, 3, 1)},
+	}
+
+	for str, period := range expected {
+		start, end, err := ParseDate(str)
+		if err != nil {
+			t.Fatalf("Unexpected error %s\n", err.Error())
+		}
+
+		if !start.Equal(period[0]) || !end.Equal(period[1]) {
+			t.Errorf("%s: expected %v got %s %s\n", str, period, start, end)
+		}
+	}
+
+	for _, bad := range []string{"", "20", "2020-13", "2020/04", "2019-02-29", "yesterday"} {
+		_, _, err := ParseDate(bad)
+		if err == nil {
+			t.Errorf("Expected error for %q\n", bad)
+		}
+	}
+}
+
+func TestParseDateRange(t *testing.T) {
+	r, err := ParseDateRange("2020-04", "2020-05-15", "")
+	if err != nil {
+		t.Fatalf("Unexpected error %s\n", err.Error())
+	}
+
+	if !r.After.Equal(testDate(2020, 4, 1)) || !r.Before.Equal(testDate(2020, 5, 15)) {
+		t.Errorf("Unexpected range %s\n", r)
+	}
+
+	r, err = ParseDateRange("", "", "2020-04")
+	if err != nil {
+		t.Fatalf("Unexpected error %s\n", err.Error())
+	}
+
+	if !r.After.Equal(testDate(2020, 4, 1)) || !r.Before.Equal(testDate(2020, 5, 1)) {
+		t.Errorf("Unexpected range %s\n", r)
+	}
+
+	r, err = ParseDateRange("", "", "")
+	if err != nil || !r.IsZero() {
+		t.Errorf("Expected zero range got %s (%v)\n", r, err)
+	}
+
+	bad := [][3]string{
+		{"2020", "", "2020-04"},
+		{"2021", "2020", ""},
+		{"2020", "2020", ""},
+		{"bogus", "", ""},
+		{"", "bogus", ""},
+		{"", "", "bogus"},
+	}
+
+	for _, args := range bad {
+		_, err = ParseDateRange(args[0], args[1], args[2])
+		if err == nil {
+			t.Errorf("Expected error for %v\n", args)
+		}
+	}
+}
+
+func TestDateRangeContains(t *testing.T) {
+	april := DateRange{testDate(2020, 4, 1), testDate(2020, 5, 1)}
+
+	contains := map[time.Time]bool{
+		testDate(2020, 3, 31):                  false,
+		testDate(2020, 4, 1):                   true,
+		testDate(2020, 4, 30).Add(time.Hour):   true,
+		testDate(2020, 5, 1):                   false,
+		testDate(2020, 5, 1).Add(-time.Second): true,
+	}
+
+	for tm, want := range contains {
+		if april.Contains(tm) != want {
+			t.Errorf("Expected %t for %s in %s\n", want, tm, april)
+		}
+	}
+
+	if !april.ContainsPeriod(testDate(2020, 4, 10), testDate(2020, 4, 11)) {
+		t.Errorf("Expected April to contain the 10th\n")
+	}
+
+	if april.ContainsPeriod(testDate(2020, 1, 1), testDate(2021, 1, 1)) {
+		t.Errorf("Expected April to not contain 2020\n")
+	}
+
+	var all DateRange
+	if !all.Contains(time.Time{}) || !all.ContainsPeriod(testDate(1, 1, 1), testDate(9999, 1, 1)) {
+		t.Errorf("Expected zero range to contain everything\n")
+	}
+
+	after := DateRange{After: testDate(2020, 4, 1)}
+	if after.Contains(testDate(2019, 1, 1)) || !after.Contains(testDate(2030, 1, 1)) {
+		t.Errorf("Expected open range to contain everything after\n")
+	}
+}
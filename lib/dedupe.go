@@ -0,0 +1,364 @@
+package exifsort
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Keep decides which file of a group of duplicates is kept.
+type Keep int
+
+const (
+	// KeepOldest : the file with the oldest modtime
+	KeepOldest Keep = iota
+	// KeepShortest : the file with the shortest path
+	KeepShortest
+	// KeepPreferred : the oldest file inside the preferred directory
+	KeepPreferred
+	// KeepNone : Error Value
+	KeepNone
+)
+
+// Returns name of keep value (all lower case).
+func (k Keep) String() string {
+	return [...]string{"oldest", "shortest", "preferred", "none"}[k]
+}
+
+// Keeps returns all keep values used excluding KeepNone.
+func Keeps() []Keep {
+	return []Keep{
+		KeepOldest,
+		KeepShortest,
+		KeepPreferred,
+	}
+}
+
+// KeepParse returns Keep from string (must be lower case). Returns KeepNone
+// if invalid.
+func KeepParse(str string) (Keep, error) {
+	for _, val := range Keeps() {
+		if str == val.String() {
+			return val, nil
+		}
+	}
+
+	return KeepNone, fmt.Errorf("invalid keep %s", str)
+}
+
+// DedupeAction decides what is done with the duplicates that are not kept.
+type DedupeAction int
+
+const (
+	// DedupeReport : duplicates are only reported
+	DedupeReport DedupeAction = iota
+	// DedupeDelete : duplicates are removed
+	DedupeDelete
+	// DedupeHardlink : duplicates are replaced by a hard link to the kept file
+	DedupeHardlink
+	// DedupeTrash : duplicates are moved to the trash directory
+	DedupeTrash
+	// DedupeNone : Error Value
+	DedupeNone
+)
+
+// Returns name of dedupe action value (all lower case).
+func (a DedupeAction) String() string {
+	return [...]string{"report", "delete", "hardlink", "trash", "none"}[a]
+}
+
+// DedupeActions returns all dedupe action values used excluding DedupeNone.
+func DedupeActions() []DedupeAction {
+	return []DedupeAction{
+		DedupeReport,
+		DedupeDelete,
+		DedupeHardlink,
+		DedupeTrash,
+	}
+}
+
+// DedupeActionParse returns DedupeAction from string (must be lower case).
+// Returns DedupeNone if invalid.
+func DedupeActionParse(str string) (DedupeAction, error) {
+	for _, val := range DedupeActions() {
+		if str == val.String() {
+			return val, nil
+		}
+	}
+
+	return DedupeNone, fmt.Errorf("invalid dedupe action %s", str)
+}
+
+// DedupeGroup is a set of files with the same contents.
+type DedupeGroup struct {
+	Size   int64
+	SHA256 string
+	// Paths has the file to keep first.
+	Paths []string
+}
+
+// Deduper is your API to find files with the same contents in one or more
+// directories, whatever their names or directories, and get rid of the extra
+// copies.
+type Deduper struct {
+	// Extensions decides which files are media, only media is compared.
+	// Nil means NewExtensions().
+	Extensions Extensions
+	// Rules leave files and directories out. Nil means NewRules().
+	Rules *Rules
+	// Keep decides which file of a group is kept.
+	Keep Keep
+	// Preferred is the directory whose files are kept by KeepPreferred.
+	Preferred string
+	// Trash is the directory DedupeTrash moves duplicates to. It is left
+	// out when it is in one of the directories.
+	Trash string
+	// Groups are the files found with the same contents.
+	Groups []DedupeGroup
+	// Done maps the duplicates Apply got rid of to the file kept.
+	Done map[string]string
+	// Errors holds the files that could not be read or acted on.
+	Errors map[string]string
+	roots  []string
+}
+
+type dedupeFile struct {
+	path string
+	info os.FileInfo
+}
+
+// inDir reports if path is dir or below it. Either may be relative to the
+// working directory.
+func inDir(dir string, path string) bool {
+	dir, errDir := filepath.Abs(dir)
+	path, errPath := filepath.Abs(path)
+
+	if errDir != nil || errPath != nil {
+		return false
+	}
+
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return false
+	}
+
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// less reports if file a is kept before file b.
+func (d *Deduper) less(a dedupeFile, b dedupeFile) bool {
+	if d.Keep == KeepPreferred {
+		aIn, bIn := inDir(d.Preferred, a.path), inDir(d.Preferred, b.path)
+		if aIn != bIn {
+			return aIn
+		}
+	}
+
+	if d.Keep == KeepShortest && len(a.path) != len(b.path) {
+		return len(a.path) < len(b.path)
+	}
+
+	aTime, bTime := a.info.ModTime(), b.info.ModTime()
+	if !aTime.Equal(bTime) {
+		return aTime.Before(bTime)
+	}
+
+	return a.path < b.path
+}
+
+// walk adds the media of root to bySize. Its modtime is all we need of its
+// time.
+func (d *Deduper) walk(root string, bySize map[int64][]dedupeFile,
+	observer Observer) error {
+	exts := extensionsOrDefault(d.Extensions)
+
+	return rulesOrDefault(d.Rules).Walk(root,
+		func(path string, info os.FileInfo, err error) error {
+			switch {
+			case err != nil:
+				d.Errors[path] = err.Error()
+			case info.IsDir() && d.Trash != "" && inDir(d.Trash, path):
+				return filepath.SkipDir
+			case info.IsDir():
+			case !info.Mode().IsRegular() || info.Size() == 0 ||
+				exts.Category(path) == CategorySkip:
+				observer.Skipped(path)
+			default:
+				bySize[info.Size()] = append(bySize[info.Size()], dedupeFile{path, info})
+				observer.FileScanned(path, info.ModTime())
+			}
+
+			return nil
+		})
+}
+
+// group hashes files of one size and adds the ones with the same contents to
+// Groups. Files that are the same file as one before, a hard link or a
+// directory given twice, are not duplicates.
+func (d *Deduper) group(size int64, files []dedupeFile) {
+	bySum := make(map[string][]dedupeFile)
+
+NextFile:
+	for i, file := range files {
+		for _, prev := range files[:i] {
+			if os.SameFile(prev.info, file.info) {
+				continue NextFile
+			}
+		}
+
+		entry, err := hashFile(file.path)
+		if err != nil {
+			d.Errors[file.path] = err.Error()
+			continue
+		}
+
+		bySum[entry.SHA256] = append(bySum[entry.SHA256], file)
+	}
+
+	for sum, same := range bySum {
+		if len(same) < 2 {
+			continue
+		}
+
+		sort.Slice(same, func(i, j int) bool { return d.less(same[i], same[j]) })
+
+		paths := make([]string, 0, len(same))
+		for _, file := range same {
+			paths = append(paths, file.path)
+		}
+
+		d.Groups = append(d.Groups, DedupeGroup{size, sum, paths})
+	}
+}
+
+// Find walks the directories and stores the media with the same contents in
+// Groups, comparing sizes first and then SHA-256 so only files that may be
+// duplicates are read.
+func (d *Deduper) Find(observer Observer) error {
+	bySize := make(map[int64][]dedupeFile)
+
+	for _, root := range d.roots {
+		info, err := os.Stat(root)
+		if err != nil {
+			return err
+		}
+
+		if !info.IsDir() {
+			return fmt.Errorf("%s is not a directory", root)
+		}
+
+		err = d.walk(root, bySize, observer)
+		if err != nil {
+			return err
+		}
+	}
+
+	for size, files := range bySize {
+		if len(files) > 1 {
+			d.group(size, files)
+		}
+	}
+
+	sort.Slice(d.Groups, func(i, j int) bool {
+		return d.Groups[i].Paths[0] < d.Groups[j].Paths[0]
+	})
+
+	return nil
+}
+
+// Wasted returns the bytes taken by the duplicates that are not kept.
+func (d *Deduper) Wasted() int64 {
+	var wasted int64
+
+	for _, group := range d.Groups {
+		wasted += group.Size * int64(len(group.Paths)-1)
+	}
+
+	return wasted
+}
+
+// rootOf returns the directory path was found in.
+func (d *Deduper) rootOf(path string) string {
+	for _, root := range d.roots {
+		if inDir(root, path) {
+			return root
+		}
+	}
+
+	return filepath.Dir(path)
+}
+
+// trash moves path to the same place below Trash as it is below its root.
+func (d *Deduper) trash(path string) error {
+	rel, err := filepath.Rel(d.rootOf(path), path)
+	if err != nil {
+		return err
+	}
+
+	return moveInto(path, filepath.Dir(filepath.Join(d.Trash, rel)))
+}
+
+func (d *Deduper) apply(action DedupeAction, keep string, dup string) error {
+	switch action {
+	case DedupeDelete:
+		return os.Remove(dup)
+	case DedupeHardlink:
+		return linkFile(keep, dup)
+	case DedupeTrash:
+		return d.trash(dup)
+	default:
+		return fmt.Errorf("invalid dedupe action %s", action)
+	}
+}
+
+// Apply gets rid of every file of Groups but the first by action and stores
+// them in Done. DedupeReport does nothing.
+func (d *Deduper) Apply(action DedupeAction, observer Observer) error {
+	switch {
+	case action == DedupeReport:
+		return nil
+	case action < DedupeReport || action >= DedupeNone:
+		return fmt.Errorf("invalid dedupe action %s", action)
+	case action == DedupeTrash && d.Trash == "":
+		return errors.New("no trash directory")
+	}
+
+	for _, group := range d.Groups {
+		keep := group.Paths[0]
+
+		for _, dup := range group.Paths[1:] {
+			err := d.apply(action, keep, dup)
+			if err != nil {
+				d.Errors[dup] = err.Error()
+				observer.Error(dup, err)
+
+				continue
+			}
+
+			d.Done[dup] = keep
+			observer.DuplicateRemoved(dup)
+		}
+	}
+
+	return nil
+}
+
+// Reset clears data so Deduper can look for duplicates in roots.
+func (d *Deduper) Reset(roots []string) {
+	d.roots = roots
+	d.Groups = nil
+	d.Done = make(map[string]string)
+	d.Errors = make(map[string]string)
+}
+
+// NewDeduper returns a Deduper of the directories roots.
+func NewDeduper(roots []string) *Deduper {
+	var d Deduper
+
+	d.Reset(roots)
+
+	return &d
+}
@@ -0,0 +1,184 @@
+package exifsort
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// dedupeRoot builds a directory with three copies of exifPath, each older
+// than the one before, and one other file. It returns the root and copies.
+func dedupeRoot(t *testing.T) (string, []string) {
+	root, err := ioutil.TempDir("", "dedupe_")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	copies := []string{
+		filepath.Join(root, "a", "long", "name", "x.jpg"),
+		filepath.Join(root, "b", "y.jpg"),
+		filepath.Join(root, "c", "zz.jpg"),
+	}
+
+	now := time.Now()
+
+	for i, path := range copies {
+		_ = os.MkdirAll(filepath.Dir(path), 0755)
+
+		err = copyFile(exifPath, path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		when := now.Add(-time.Duration(i) * time.Hour)
+		_ = os.Chtimes(path, when, when)
+	}
+
+	err = copyFile(noExifPath, filepath.Join(root, "a", "other.jpg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return root, copies
+}
+
+func TestDedupeFind(t *testing.T) {
+	t.Parallel()
+
+	root, copies := dedupeRoot(t)
+	defer os.RemoveAll(root)
+
+	expected := map[Keep]string{
+		KeepOldest:    copies[2],
+		KeepShortest:  copies[1],
+		KeepPreferred: copies[0],
+	}
+
+	for keep, kept := range expected {
+		d := NewDeduper([]string{root})
+		d.Keep = keep
+		d.Preferred = filepath.Join(root, "a")
+
+		err := d.Find(NopObserver{})
+		if err != nil {
+			t.Fatalf("Unexpected error %s\n", err.Error())
+		}
+
+		if len(d.Groups) != 1 || len(d.Groups[0].Paths) != len(copies) {
+			t.Fatalf("Expected one group of %d got %v\n", len(copies), d.Groups)
+		}
+
+		if d.Groups[0].Paths[0] != kept {
+			t.Errorf("%s: expected to keep %s got %s\n", keep, kept, d.Groups[0].Paths[0])
+		}
+
+		if d.Wasted() != 2*d.Groups[0].Size {
+			t.Errorf("Expected %d wasted got %d\n", 2*d.Groups[0].Size, d.Wasted())
+		}
+	}
+}
+
+func TestDedupePreferRelative(t *testing.T) {
+	t.Parallel()
+
+	root, copies := dedupeRoot(t)
+	defer os.RemoveAll(root)
+
+	wd, _ := os.Getwd()
+
+	relRoot, err := filepath.Rel(wd, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The root and the preferred directory given one relative and one not.
+	expected := map[string]string{
+		relRoot: filepath.Join(root, "a"),
+		root:    filepath.Join(relRoot, "a"),
+	}
+
+	for dedupeRoot, preferred := range expected {
+		d := NewDeduper([]string{dedupeRoot})
+		d.Keep = KeepPreferred
+		d.Preferred = preferred
+
+		_ = d.Find(NopObserver{})
+
+		if len(d.Groups) != 1 || filepath.Base(d.Groups[0].Paths[0]) != filepath.Base(copies[0]) {
+			t.Errorf("%s in %s: expected to keep %s got %v\n", preferred, dedupeRoot,
+				copies[0], d.Groups)
+		}
+	}
+}
+
+func TestDedupeApply(t *testing.T) {
+	t.Parallel()
+
+	for _, action := range []DedupeAction{DedupeDelete, DedupeHardlink, DedupeTrash} {
+		root, copies := dedupeRoot(t)
+		defer os.RemoveAll(root)
+
+		trash := filepath.Join(root, "trash")
+
+		d := NewDeduper([]string{root})
+		d.Trash = trash
+		_ = d.Find(NopObserver{})
+
+		err := d.Apply(action, NopObserver{})
+		if err != nil || len(d.Done) != 2 || len(d.Errors) != 0 {
+			t.Fatalf("%s: expected 2 done got %d err %v %v\n", action, len(d.Done), err, d.Errors)
+		}
+
+		// Nothing is a duplicate after.
+		d.Reset([]string{root})
+		_ = d.Find(NopObserver{})
+
+		if len(d.Groups) != 0 {
+			t.Errorf("%s: expected no duplicates left got %v\n", action, d.Groups)
+		}
+
+		switch action {
+		case DedupeDelete:
+			if exists(copies[0]) || !exists(copies[2]) {
+				t.Errorf("Deleted the wrong files\n")
+			}
+		case DedupeHardlink:
+			info, _ := os.Stat(copies[0])
+			kept, _ := os.Stat(copies[2])
+
+			if !os.SameFile(info, kept) {
+				t.Errorf("%s is not a link to %s\n", copies[0], copies[2])
+			}
+		case DedupeTrash:
+			if !exists(filepath.Join(trash, "b", "y.jpg")) || exists(copies[1]) {
+				t.Errorf("%s is not in the trash\n", copies[1])
+			}
+		}
+	}
+}
+
+func TestDedupeBad(t *testing.T) {
+	d := NewDeduper([]string{filepath.Join("no", "such", "dir")})
+
+	err := d.Find(NopObserver{})
+	if err == nil {
+		t.Errorf("Expected error for a missing dir\n")
+	}
+
+	err = d.Apply(DedupeTrash, NopObserver{})
+	if err == nil {
+		t.Errorf("Expected error trashing without a trash dir\n")
+	}
+
+	_, err = KeepParse("newest")
+	if err == nil {
+		t.Errorf("Expected error parsing newest\n")
+	}
+
+	_, err = DedupeActionParse("shred")
+	if err == nil {
+		t.Errorf("Expected error parsing shred\n")
+	}
+}
@@ -0,0 +1,251 @@
+package exifsort
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// EventGap is the default longest time between two photos of one event.
+const EventGap = 6 * time.Hour
+
+// EventLabel names the events that start in Range.
+type EventLabel struct {
+	Range DateRange
+	Label string
+}
+
+// EventLabels name events. The first label whose range holds the start of an
+// event names it.
+type EventLabels []EventLabel
+
+// Label returns the name of the event starting at start or "".
+func (l EventLabels) Label(start time.Time) string {
+	for _, label := range l {
+		if label.Range.Contains(start) {
+			return label.Label
+		}
+	}
+
+	return ""
+}
+
+// ReadEventLabels reads labels as CSV lines of "<first date>,<last date>,
+// <label>" such as "2020-04-27,2020-04-29,Lisbon". Dates are what ParseDate
+// accepts and both are part of the range. Lines starting with # are comments.
+func ReadEventLabels(r io.Reader) (EventLabels, error) {
+	const numLabelFields = 3
+
+	reader := csv.NewReader(r)
+	reader.Comment = '#'
+	reader.FieldsPerRecord = numLabelFields
+	reader.TrimLeadingSpace = true
+
+	var labels EventLabels
+
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return labels, nil
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		first, _, err := ParseDate(record[0])
+		if err != nil {
+			return nil, err
+		}
+
+		_, last, err := ParseDate(record[1])
+		if err != nil {
+			return nil, err
+		}
+
+		if !first.Before(last) {
+			return nil, fmt.Errorf("%s is after %s", record[0], record[1])
+		}
+
+		label := dirName(strings.TrimSpace(record[2]))
+		if label == "" {
+			return nil, fmt.Errorf("no label for %s to %s", record[0], record[1])
+		}
+
+		labels = append(labels, EventLabel{DateRange{first, last}, label})
+	}
+}
+
+// LoadEventLabels reads the labels CSV file at path.
+func LoadEventLabels(path string) (EventLabels, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	labels, err := ReadEventLabels(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return labels, nil
+}
+
+// event is media taken with no gap longer than the index gap between them.
+type event struct {
+	start time.Time
+	end   time.Time
+	n     node
+}
+
+// eventIndex will sort the paths into events. Put is cheapest and makes the
+// fewest events when called in time order, which is how Sorter calls it.
+type eventIndex struct {
+	gap    time.Duration
+	labels EventLabels
+	events []*event
+}
+
+// find returns the event that t is part of, or nil.
+func (e *eventIndex) find(t time.Time) *event {
+	// Most likely the last one as we Put in time order.
+	for ii := len(e.events) - 1; ii >= 0; ii-- {
+		ev := e.events[ii]
+		if !t.Before(ev.start.Add(-e.gap)) && !t.After(ev.end.Add(e.gap)) {
+			return ev
+		}
+	}
+
+	return nil
+}
+
+// name returns the directory of ev, such as 2020/2020_04_27-2020_04_29, with
+// its start in layout.
+func (e *eventIndex) name(ev *event, layout string) string {
+	const dayLayout = "2006_01_02"
+
+	name := ev.start.Format(layout)
+
+	last := ev.end.Format(dayLayout)
+	if last != ev.start.Format(dayLayout) {
+		name += "-" + last
+	}
+
+	label := e.labels.Label(ev.start)
+	if label != "" {
+		name += " " + label
+	}
+
+	return filepath.Join(fmt.Sprintf("%04d", ev.start.Year()), name)
+}
+
+// names returns the directory of every event. Events that would share one,
+// such as two on the same day, are told apart by the time they start, as in
+// 2020/2020_04_27_0800.
+func (e *eventIndex) names() map[*event]string {
+	layouts := []string{"2006_01_02", "2006_01_02_1504", "2006_01_02_150405"}
+	names := make(map[*event]string)
+
+	for _, ev := range e.events {
+		names[ev] = e.name(ev, layouts[0])
+	}
+
+	for _, layout := range layouts[1:] {
+		shared := make(map[string]int)
+
+		for _, ev := range e.events {
+			shared[names[ev]]++
+		}
+
+		for _, ev := range e.events {
+			if shared[names[ev]] > 1 {
+				names[ev] = e.name(ev, layout)
+			}
+		}
+	}
+
+	return names
+}
+
+func (e *eventIndex) Put(path string, time time.Time) error {
+	ev := e.find(time)
+	if ev == nil {
+		ev = &event{start: time, end: time}
+		ev.n.init(len(e.events))
+		e.events = append(e.events, ev)
+	}
+
+	err := ev.n.mediaAdd(path)
+	if err != nil {
+		return err
+	}
+
+	if time.Before(ev.start) {
+		ev.start = time
+	}
+
+	if time.After(ev.end) {
+		ev.end = time
+	}
+
+	return nil
+}
+
+func (e *eventIndex) PathStr(time time.Time, base string) string {
+	ev := e.find(time)
+	if ev == nil {
+		// Alone it would be an event of its own.
+		return filepath.Join(e.name(&event{start: time, end: time}, "2006_01_02"), base)
+	}
+
+	return filepath.Join(e.names()[ev], base)
+}
+
+func (e *eventIndex) Get(path string) (string, bool) {
+	soughtBase := filepath.Base(path)
+	names := e.names()
+
+	for _, ev := range e.events {
+		if _, present := ev.n.media[soughtBase]; present {
+			return filepath.Join(names[ev], soughtBase), true
+		}
+	}
+
+	return "", false
+}
+
+func (e *eventIndex) GetAll() mediaMap {
+	var retMap = make(mediaMap)
+
+	names := e.names()
+
+	for _, ev := range e.events {
+		dir := names[ev]
+		for base, oldPath := range ev.n.media {
+			retMap[filepath.Join(dir, base)] = oldPath
+		}
+	}
+
+	return retMap
+}
+
+func (e eventIndex) String() string {
+	var retStr string
+
+	media := e.GetAll()
+
+	var n node
+
+	keys := n.sortMediaKeys(media)
+	for _, newPath := range keys {
+		oldPath := media[newPath]
+		retStr += fmt.Sprintf("%s => %s\n", oldPath, newPath)
+	}
+
+	return retStr
+}
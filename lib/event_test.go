@@ -0,0 +1,193 @@
+package exifsort
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testEventTime(day int, hour int) time.Time {
+	return time.Date(2020, time.April, day, hour, 0, 0, 0, time.Local)
+}
+
+func TestEventIndexPut(t *testing.T) {
+	idx, err := newIndex(MethodEvent)
+	if err != nil {
+		t.Fatalf("Unexpected error %s\n", err.Error())
+	}
+
+	// No gap over six hours until the morning of the 29th.
+	times := []time.Time{
+		testEventTime(27, 10),
+		testEventTime(27, 14),
+		testEventTime(27, 19),
+		testEventTime(28, 0),
+		testEventTime(28, 5),
+		testEventTime(29, 6),
+	}
+
+	expected := make(map[string]string)
+
+	for ii, time := range times {
+		path := fmt.Sprintf("a/%d.jpg", ii)
+
+		err := idx.Put(path, time)
+		if err != nil {
+			t.Fatalf("Unexpected error %s\n", err.Error())
+		}
+
+		dir := "2020_04_27-2020_04_28"
+		if time.Day() == 29 {
+			dir = "2020_04_29"
+		}
+
+		expected[path] = filepath.Join("2020", dir, filepath.Base(path))
+	}
+
+	for path, newPath := range expected {
+		got, present := idx.Get(path)
+		if !present || got != newPath {
+			t.Errorf("%s: expected %s got %s\n", path, newPath, got)
+		}
+	}
+
+	if len(idx.GetAll()) != len(expected) {
+		t.Errorf("Expected %d media got %d\n", len(expected), len(idx.GetAll()))
+	}
+
+	got := idx.PathStr(testEventTime(30, 23), "x.jpg")
+	if got != filepath.Join("2020", "2020_04_30", "x.jpg") {
+		t.Errorf("Unexpected PathStr %s\n", got)
+	}
+}
+
+func TestEventIndexGap(t *testing.T) {
+	idx := &eventIndex{gap: time.Hour}
+
+	_ = idx.Put("a/1.jpg", testEventTime(27, 10))
+	_ = idx.Put("a/2.jpg", testEventTime(27, 12))
+
+	if len(idx.events) != 2 {
+		t.Errorf("Expected 2 events got %d\n", len(idx.events))
+	}
+
+	// Within the gap of both, it joins the last one.
+	_ = idx.Put("a/3.jpg", testEventTime(27, 11))
+
+	got, _ := idx.Get("a/3.jpg")
+	if len(idx.events) != 2 || got != filepath.Join("2020", "2020_04_27_1100", "3.jpg") {
+		t.Errorf("Unexpected events %d and path %s\n", len(idx.events), got)
+	}
+}
+
+func TestEventIndexSameDay(t *testing.T) {
+	idx := &eventIndex{gap: EventGap}
+
+	// Two events of the same day with media of the same name.
+	_ = idx.Put("/a/IMG_1.jpg", testEventTime(27, 8))
+	_ = idx.Put("/b/IMG_1.jpg", testEventTime(27, 20))
+	_ = idx.Put("/c/IMG_2.jpg", testEventTime(29, 8))
+
+	expected := mediaMap{
+		filepath.Join("2020", "2020_04_27_0800", "IMG_1.jpg"): "/a/IMG_1.jpg",
+		filepath.Join("2020", "2020_04_27_2000", "IMG_1.jpg"): "/b/IMG_1.jpg",
+		filepath.Join("2020", "2020_04_29", "IMG_2.jpg"):      "/c/IMG_2.jpg",
+	}
+
+	all := idx.GetAll()
+	if len(all) != len(expected) {
+		t.Fatalf("Expected %v got %v\n", expected, all)
+	}
+
+	for newPath, oldPath := range expected {
+		if all[newPath] != oldPath {
+			t.Errorf("%s: expected %s got %s\n", newPath, oldPath, all[newPath])
+		}
+	}
+
+	// Starting in the same minute they are told apart by the second.
+	idx = &eventIndex{gap: time.Second}
+	_ = idx.Put("/a/IMG_1.jpg", testEventTime(27, 8))
+	_ = idx.Put("/b/IMG_1.jpg", testEventTime(27, 8).Add(30*time.Second))
+
+	if len(idx.GetAll()) != 2 {
+		t.Errorf("Expected 2 media got %v\n", idx.GetAll())
+	}
+}
+
+func TestReadEventLabels(t *testing.T) {
+	content := "# first,last,label\n" +
+		"2020-04-27,2020-04-29,Lisbon\n" +
+		"2020-05, 2020-05, May/June\n"
+
+	labels, err := ReadEventLabels(strings.NewReader(content))
+	if err != nil {
+		t.Fatalf("Unexpected error %s\n", err.Error())
+	}
+
+	expected := map[time.Time]string{
+		testEventTime(26, 23): "",
+		testEventTime(27, 0):  "Lisbon",
+		testEventTime(29, 23): "Lisbon",
+		testEventTime(30, 0):  "",
+		testDate(2020, 5, 31): "May_June",
+		testDate(2020, 6, 1):  "",
+	}
+
+	for start, label := range expected {
+		if labels.Label(start) != label {
+			t.Errorf("%s: expected %q got %q\n", start, label, labels.Label(start))
+		}
+	}
+
+	badContent := []string{
+		"2020-04-27,2020-04-29\n",
+		"2020-04-29,2020-04-27,Backwards\n",
+		"2020-04-32,2020-04-29,Bad Date\n",
+		"2020-04-27,2020-04-29,\n",
+	}
+
+	for _, content := range badContent {
+		_, err := ReadEventLabels(strings.NewReader(content))
+		if err == nil {
+			t.Errorf("Expected error for %q\n", content)
+		}
+	}
+}
+
+func TestLoadEventLabels(t *testing.T) {
+	tmpPath, err := ioutil.TempDir("", "LoadEventLabels")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpPath)
+
+	path := filepath.Join(tmpPath, "labels.csv")
+
+	err = ioutil.WriteFile(path, []byte("2020-04-27,2020-04-29,Lisbon\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	labels, err := LoadEventLabels(path)
+	if err != nil {
+		t.Fatalf("Unexpected error %s\n", err.Error())
+	}
+
+	idx := &eventIndex{gap: EventGap, labels: labels}
+	_ = idx.Put("a/1.jpg", testEventTime(27, 10))
+
+	got, _ := idx.Get("a/1.jpg")
+	if got != filepath.Join("2020", "2020_04_27 Lisbon", "1.jpg") {
+		t.Errorf("Unexpected labeled path %s\n", got)
+	}
+
+	_, err = LoadEventLabels(filepath.Join(tmpPath, "missing.csv"))
+	if err == nil {
+		t.Errorf("Expected error for a missing file\n")
+	}
+}
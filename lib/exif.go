@@ -3,6 +3,7 @@ package exifsort
 import (
 	"errors"
 	"fmt"
+	"io/ioutil"
 	"strconv"
 	"strings"
 	"time"
@@ -141,22 +142,11 @@ func queryTag(exifIfd *exif.Ifd) (value string, err error) {
 	return "", fmt.Errorf("cannot find tags: %s", strings.Join(tags, " or "))
 }
 
-// ExifTimeGet accepts a filepath, returns either 'IFD/EXIF/DateTimeOriginal'
-// value or 'IFD/EXIF/DateTimeDigitized' contained in its metadata.
-func ExifTimeGet(filepath string) (time.Time, error) {
+func exifTimeFromRootIfd(rootIfd *exif.Ifd) (time.Time, error) {
 	var time time.Time
-	// Get the Exif Data and Ifd root
-	mc, err := exifknife.GetExif(filepath)
-	if err != nil {
-		return time, err
-	}
-	// If the root is not there there is no exif data
-	if mc.RootIfd == nil {
-		return time, errors.New("root ifd not found")
-	}
 
 	// See if the EXIF info path is there.
-	exifIfd, err := exif.FindIfdFromRootIfd(mc.RootIfd, "IFD/Exif")
+	exifIfd, err := exif.FindIfdFromRootIfd(rootIfd, "IFD/Exif")
 	if err != nil {
 		return time, errors.New("media IFD/Exif not found")
 	}
@@ -174,3 +164,116 @@ func ExifTimeGet(filepath string) (time.Time, error) {
 
 	return time, nil
 }
+
+// exifRootIfd returns the root IFD of the exif data of the file at path.
+func exifRootIfd(path string) (*exif.Ifd, error) {
+	// Get the Exif Data and Ifd root
+	mc, err := exifknife.GetExif(path)
+	if err != nil {
+		return nil, err
+	}
+	// If the root is not there there is no exif data
+	if mc.RootIfd == nil {
+		return nil, errors.New("root ifd not found")
+	}
+
+	return mc.RootIfd, nil
+}
+
+// ExifTimeGet accepts a filepath, returns either 'IFD/EXIF/DateTimeOriginal'
+// value or 'IFD/EXIF/DateTimeDigitized' contained in its metadata.
+func ExifTimeGet(filepath string) (time.Time, error) {
+	var time time.Time
+
+	rootIfd, err := exifRootIfd(filepath)
+	if err != nil {
+		return time, err
+	}
+
+	return exifTimeFromRootIfd(rootIfd)
+}
+
+func exifTagInt(ifd *exif.Ifd, name string) int {
+	results, err := ifd.FindTagWithName(name)
+	if err != nil || len(results) == 0 {
+		return 0
+	}
+
+	value, err := results[0].Value()
+	if err != nil {
+		return 0
+	}
+
+	switch v := value.(type) {
+	case []uint16:
+		if len(v) != 0 {
+			return int(v[0])
+		}
+	case []uint32:
+		if len(v) != 0 {
+			return int(v[0])
+		}
+	}
+
+	return 0
+}
+
+// exifDimensions returns the largest width and height in the exif data. Raw
+// files keep a thumbnail in their first IFD so we look at every IFD.
+func exifDimensions(ifd *exif.Ifd) (int, int) {
+	if ifd == nil {
+		return 0, 0
+	}
+
+	width := exifTagInt(ifd, "PixelXDimension")
+	if w := exifTagInt(ifd, "ImageWidth"); w > width {
+		width = w
+	}
+
+	height := exifTagInt(ifd, "PixelYDimension")
+	if h := exifTagInt(ifd, "ImageLength"); h > height {
+		height = h
+	}
+
+	next := append([]*exif.Ifd{ifd.NextIfd}, ifd.Children...)
+	for _, child := range next {
+		w, h := exifDimensions(child)
+		if w > width {
+			width = w
+		}
+
+		if h > height {
+			height = h
+		}
+	}
+
+	return width, height
+}
+
+// exifRootIfdSearch is exifRootIfd for files whose extension can't be trusted
+// to tell their format. It looks for exif data anywhere in the file.
+func exifRootIfdSearch(path string) (*exif.Ifd, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	rawExif, err := exif.SearchAndExtractExif(data)
+	if err != nil {
+		return nil, err
+	}
+
+	im := exif.NewIfdMappingWithStandard()
+	ti := exif.NewTagIndex()
+
+	_, index, err := exif.Collect(im, ti, rawExif)
+	if err != nil {
+		return nil, err
+	}
+
+	if index.RootIfd == nil {
+		return nil, errors.New("root ifd not found")
+	}
+
+	return index.RootIfd, nil
+}
@@ -1,51 +1,83 @@
 package exifsort
 
 import (
+	"fmt"
 	"path/filepath"
+	"sort"
 	"strings"
 )
 
 // ExtensionsPhoto returns set of supported Extensions that we process.
 //
-// Set includes: bmp, cr2, dng, gif, jpeg, jpg, nef, png, psd, raf, raw, tif,
-// tiff.
+// Set includes: arw, bmp, cr2, dng, gif, heic, heif, jpeg, jpg, nef, orf, png,
+// psd, raf, raw, rw2, tif, tiff, webp.
 func ExtensionsPhoto() []string {
 	// We are going to do this check a lot so let's use a map.
 	return []string{
+		".arw",
 		".bmp",
 		".cr2",
 		".dng",
 		".gif",
+		".heic",
+		".heif",
 		".jpeg",
 		".jpg",
 		".nef",
+		".orf",
 		".png",
 		".psd",
 		".raf",
 		".raw",
+		".rw2",
 		".tif",
 		".tiff",
+		".webp",
 	}
 }
 
-// ExtensionsMovie returns the set of extensions for files that take a long time
-// to extract exif data so we only check modTime for these extensions. Set
-// includes: 3g2, 3gp, avi, m4v, mov, mp4, mpg, wmv.
+// ExtensionsMovie returns the set of extensions for movie files. The time of
+// ExtensionsQuickTime is read from their metadata, the rest only have modTime.
+// Set includes: 3g2, 3gp, avi, m2ts, m4v, mov, mp4, mpg, mts, wmv.
 func ExtensionsMovie() []string {
 	return []string{
 		".3g2",
 		".3gp",
 		".avi",
+		".m2ts",
 		".m4v",
 		".mpg",
 		".mov",
 		".mp4",
+		".mts",
 		".wmv",
 	}
 }
 
+// ExtensionsQuickTime returns the subset of ExtensionsMovie that hold their
+// creation time in QuickTime metadata. Set includes: 3g2, 3gp, m4v, mov, mp4.
+func ExtensionsQuickTime() []string {
+	return []string{
+		".3g2",
+		".3gp",
+		".m4v",
+		".mov",
+		".mp4",
+	}
+}
+
+// ExtensionsSidecar returns the set of extensions for files that hold extra
+// data about a photo or movie next to them. Set includes: aae, xmp.
+func ExtensionsSidecar() []string {
+	return []string{
+		".aae",
+		".xmp",
+	}
+}
+
 // SynologySkip returns the set of files or directories that contain strings we
-// find on Synology file servers and ignore. The check is case insensitive.
+// find on Synology file servers and ignore. They make up the synology preset of
+// Rules and the check is case insensitive.
 //
 // Set includes: @eadir, @syno, synofile_thumb
 func SynologySkip() []string {
@@ -56,41 +88,181 @@ func SynologySkip() []string {
 	}
 }
 
-type fileCategory int
+// Category specifies how a file is handled based on its extension.
+type Category int
 
 const (
-	categorySkip fileCategory = iota
-	categoryExif
-	categoryModTime
+	// CategorySkip : Not media, the file is skipped
+	CategorySkip Category = iota
+	// CategoryExif : Time is read from exif data or else modtime
+	CategoryExif
+	// CategoryMovie : Time is read from movie metadata or else modtime
+	CategoryMovie
+	// CategoryModTime : Time is the modtime of the file
+	CategoryModTime
+	// CategorySidecar : Time is that of the media file it belongs to
+	CategorySidecar
+	// CategoryNone : Error Value
+	CategoryNone
 )
 
-func categorizeFile(path string) fileCategory {
-	// All comparisons are lower case as case don't matter
-	path = strings.ToLower(path)
+// Returns name of category value (all lower case).
+func (c Category) String() string {
+	return [...]string{"skip", "exif", "movie-metadata", "modtime-only",
+		"sidecar", "none"}[c]
+}
 
-	for _, str := range SynologySkip() {
-		if strings.Contains(path, str) {
-			return categorySkip
+// Categories returns all category values used excluding CategoryNone.
+func Categories() []Category {
+	return []Category{
+		CategorySkip,
+		CategoryExif,
+		CategoryMovie,
+		CategoryModTime,
+		CategorySidecar,
+	}
+}
+
+// CategoryParse returns Category from string (must be lower case). Returns
+// CategoryNone if invalid.
+func CategoryParse(str string) (Category, error) {
+	for _, val := range Categories() {
+		if str == val.String() {
+			return val, nil
 		}
 	}
 
+	return CategoryNone, fmt.Errorf("invalid category %s", str)
+}
+
+// Extensions maps file extensions to the Category of files that have them.
+// Keys are lower case and start with ".". Extensions not in the map are
+// skipped.
+type Extensions map[string]Category
+
+// NewExtensions returns the default Extensions. ExtensionsPhoto are read for
+// exif, ExtensionsQuickTime for movie metadata, the rest of ExtensionsMovie
+// only have modtime and ExtensionsSidecar are sidecars.
+func NewExtensions() Extensions {
+	e := make(Extensions)
+
+	for _, ext := range ExtensionsPhoto() {
+		e[ext] = CategoryExif
+	}
+
+	for _, ext := range ExtensionsMovie() {
+		e[ext] = CategoryModTime
+	}
+
+	for _, ext := range ExtensionsQuickTime() {
+		e[ext] = CategoryMovie
+	}
+
+	for _, ext := range ExtensionsSidecar() {
+		e[ext] = CategorySidecar
+	}
+
+	return e
+}
+
+func extNormalize(ext string) (string, error) {
+	ext = strings.ToLower(strings.TrimSpace(ext))
+	if !strings.HasPrefix(ext, ".") {
+		ext = "." + ext
+	}
+
+	if len(ext) < len(".x") || strings.ContainsAny(ext[1:], `./\`) {
+		return "", fmt.Errorf("invalid extension %s", ext)
+	}
+
+	return ext, nil
+}
+
+// Add sets the category of files with extension ext. The leading "." is
+// optional and case does not matter. Adding CategorySkip is the same as
+// removing the extension.
+func (e Extensions) Add(ext string, category Category) error {
+	ext, err := extNormalize(ext)
+	if err != nil {
+		return err
+	}
+
+	if category == CategorySkip {
+		delete(e, ext)
+		return nil
+	}
+
+	if category < CategorySkip || category >= CategoryNone {
+		return fmt.Errorf("invalid category %d", category)
+	}
+
+	e[ext] = category
+
+	return nil
+}
+
+// Remove stops files with extension ext from being processed.
+func (e Extensions) Remove(ext string) {
+	ext, err := extNormalize(ext)
+	if err == nil {
+		delete(e, ext)
+	}
+}
+
+// Parse adds an extension from a string of the form "<ext>=<category>" such as
+// ".heic=exif" or "png=skip".
+func (e Extensions) Parse(str string) error {
+	const numParts = 2
+
+	parts := strings.Split(str, "=")
+	if len(parts) != numParts {
+		return fmt.Errorf("invalid extension %s, expected <ext>=<category>", str)
+	}
+
+	category, err := CategoryParse(strings.TrimSpace(parts[1]))
+	if err != nil {
+		return err
+	}
+
+	return e.Add(parts[0], category)
+}
+
+// Sorted returns the extensions in e in order.
+func (e Extensions) Sorted() []string {
+	exts := make([]string, 0, len(e))
+	for ext := range e {
+		exts = append(exts, ext)
+	}
+
+	sort.Strings(exts)
+
+	return exts
+}
+
+// Category returns the category of the file at path.
+func (e Extensions) Category(path string) Category {
+	// All comparisons are lower case as case don't matter
+	path = strings.ToLower(path)
+
 	extension := filepath.Ext(path)
 	if extension == "" {
 		// no extension found so we skip
-		return categorySkip
+		return CategorySkip
 	}
 
-	for _, str := range ExtensionsPhoto() {
-		if extension == str {
-			return categoryExif
-		}
+	category, present := e[extension]
+	if !present {
+		return CategorySkip
 	}
 
-	for _, str := range ExtensionsMovie() {
-		if extension == str {
-			return categoryModTime
-		}
+	return category
+}
+
+// extensionsOrDefault lets callers leave their Extensions nil for defaults.
+func extensionsOrDefault(e Extensions) Extensions {
+	if e == nil {
+		return NewExtensions()
 	}
 
-	return categorySkip
+	return e
 }
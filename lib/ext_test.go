@@ -11,8 +11,8 @@ func TestExtSkipFileType(t *testing.T) {
 	for _, extension := range ExtensionsPhoto() {
 		goodInput := fmt.Sprintf("gobo.%s", extension)
 
-		category := categorizeFile(goodInput)
-		if category == categorySkip {
+		category := NewExtensions().Category(goodInput)
+		if category == CategorySkip {
 			t.Errorf("Expected to not skip for %s\n", goodInput)
 		}
 	}
@@ -20,8 +20,8 @@ func TestExtSkipFileType(t *testing.T) {
 	for _, extension := range ExtensionsPhoto() {
 		goodInput := strings.ToUpper(fmt.Sprintf("gobo.%s", extension))
 
-		category := categorizeFile(goodInput)
-		if category == categorySkip {
+		category := NewExtensions().Category(goodInput)
+		if category == CategorySkip {
 			t.Errorf("Expected to not skip for %s\n", goodInput)
 		}
 	}
@@ -30,41 +30,111 @@ func TestExtSkipFileType(t *testing.T) {
 	for _, extension := range ExtensionsPhoto() {
 		goodInput := fmt.Sprintf("hey.gobo.%s", extension)
 
-		category := categorizeFile(goodInput)
-		if category == categorySkip {
+		category := NewExtensions().Category(goodInput)
+		if category == CategorySkip {
 			t.Errorf("Expected to not skip for %s\n", goodInput)
 		}
 	}
 
 	badInput := "gobobob.."
 
-	category := categorizeFile(badInput)
-	if category != categorySkip {
+	category := NewExtensions().Category(badInput)
+	if category != CategorySkip {
 		t.Errorf("Expected to skip for %s\n", badInput)
 	}
 
 	badInput = "gobo"
 
-	category = categorizeFile(badInput)
-	if category != categorySkip {
+	category = NewExtensions().Category(badInput)
+	if category != CategorySkip {
 		t.Errorf("Expected to skip for %s\n", badInput)
 	}
 }
 
-func TestExtSkipSynologyTypes(t *testing.T) {
-	badInput := []string{
-		".DS_Store@SynoResource",
-		"2019@SynoEAStream",
-		"PSD_Work/@eaDir/",
-		"@eaDir",
-		"@syno",
-		"IMG_0269.JPG/SYNOFILE_THUMB_M_r1.jpg",
+func TestExtDefaults(t *testing.T) {
+	exts := NewExtensions()
+
+	expected := map[string]Category{
+		"IMG_1.HEIC":  CategoryExif,
+		"IMG_1.arw":   CategoryExif,
+		"IMG_1.orf":   CategoryExif,
+		"IMG_1.rw2":   CategoryExif,
+		"IMG_1.webp":  CategoryExif,
+		"MVI_1.MOV":   CategoryMovie,
+		"MVI_1.mp4":   CategoryMovie,
+		"MVI_1.mts":   CategoryModTime,
+		"MVI_1.avi":   CategoryModTime,
+		"IMG_1.xmp":   CategorySidecar,
+		"IMG_1.AAE":   CategorySidecar,
+		"README.md":   CategorySkip,
+		"IMG_1.bogus": CategorySkip,
+	}
+
+	for path, category := range expected {
+		if exts.Category(path) != category {
+			t.Errorf("%s: expected %s got %s\n", path, category,
+				exts.Category(path))
+		}
+	}
+}
+
+func TestExtAddRemove(t *testing.T) {
+	exts := NewExtensions()
+
+	err := exts.Add("JXL", CategoryExif)
+	if err != nil {
+		t.Fatalf("Unexpected error %s\n", err.Error())
+	}
+
+	if exts.Category("gobo.jxl") != CategoryExif {
+		t.Errorf("Expected jxl to be exif\n")
+	}
+
+	exts.Remove(".JPG")
+
+	if exts.Category("gobo.jpg") != CategorySkip {
+		t.Errorf("Expected jpg to be skipped\n")
+	}
+
+	err = exts.Add(".png", CategorySkip)
+	if err != nil {
+		t.Fatalf("Unexpected error %s\n", err.Error())
 	}
 
-	for _, input := range badInput {
-		category := categorizeFile(input)
-		if category != categorySkip {
-			t.Errorf("Expected to skip for %s\n", input)
+	if exts.Category("gobo.png") != CategorySkip {
+		t.Errorf("Expected png to be skipped\n")
+	}
+
+	for _, bad := range []string{"", ".", "a.b", "a/b"} {
+		if exts.Add(bad, CategoryExif) == nil {
+			t.Errorf("Expected error for extension %q\n", bad)
 		}
 	}
+
+	if exts.Add(".jxl", CategoryNone) == nil {
+		t.Errorf("Expected error for CategoryNone\n")
+	}
+}
+
+func TestExtParse(t *testing.T) {
+	exts := make(Extensions)
+
+	err := exts.Parse(".mts=movie-metadata")
+	if err != nil {
+		t.Fatalf("Unexpected error %s\n", err.Error())
+	}
+
+	if exts.Category("gobo.MTS") != CategoryMovie {
+		t.Errorf("Expected mts to be movie-metadata\n")
+	}
+
+	for _, bad := range []string{".mts", ".mts=bogus", "=exif", ".a=exif=skip"} {
+		if exts.Parse(bad) == nil {
+			t.Errorf("Expected error for %q\n", bad)
+		}
+	}
+
+	if len(exts.Sorted()) != 1 {
+		t.Errorf("Expected one extension, got %v\n", exts.Sorted())
+	}
 }
@@ -0,0 +1,122 @@
+package exifsort
+
+import (
+	"fmt"
+	"image"
+	"os"
+	"path/filepath"
+	"strings"
+
+	// Registered so image.DecodeConfig reads their headers.
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+)
+
+// Limits describe the files of a category that are worth keeping. Zero means
+// no limit, so empty files are kept unless MinSize is at least 1.
+type Limits struct {
+	MinSize int64 // Bytes
+	MaxSize int64 // Bytes
+	// The smaller of MinWidth and MinHeight is compared to the shorter side
+	// of an image and the larger to the longer side so rotated images pass
+	// the same limits. Movies have no dimensions we read so they pass.
+	MinWidth  int
+	MinHeight int
+}
+
+func (l Limits) needDimensions() bool {
+	return l.MinWidth > 0 || l.MinHeight > 0
+}
+
+// Filters hold the Limits of each Category. Files that fall outside the
+// limits of their category are filtered out by ScanDir.
+type Filters map[Category]Limits
+
+// Dimensions returns the width and height in pixels of the image at path. They
+// come from exif data or else the image header for GIF, JPEG and PNG files.
+func Dimensions(path string) (int, int, error) {
+	rootIfd, err := exifRootIfd(path)
+	if err == nil {
+		width, height := exifDimensions(rootIfd)
+		if width > 0 && height > 0 {
+			return width, height, nil
+		}
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer file.Close()
+
+	config, _, err := image.DecodeConfig(file)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return config.Width, config.Height, nil
+}
+
+// isMovie reports if path has one of ExtensionsMovie.
+func isMovie(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+
+	for _, movie := range ExtensionsMovie() {
+		if ext == movie {
+			return true
+		}
+	}
+
+	return false
+}
+
+func minMax(a int, b int) (int, int) {
+	if a < b {
+		return a, b
+	}
+
+	return b, a
+}
+
+// filtered returns why the file at path of category falls outside the
+// filters, or "" if it does not. Files we can't stat or measure are not
+// filtered, scanning reports what is wrong with them.
+func (f Filters) filtered(path string, category Category) string {
+	limits, present := f[category]
+	if !present {
+		return ""
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return ""
+	}
+
+	size := info.Size()
+
+	switch {
+	case size < limits.MinSize:
+		return fmt.Sprintf("size %d below minimum %d", size, limits.MinSize)
+	case limits.MaxSize > 0 && size > limits.MaxSize:
+		return fmt.Sprintf("size %d above maximum %d", size, limits.MaxSize)
+	case !limits.needDimensions() || category == CategoryMovie || isMovie(path):
+		// Reading the dimensions would parse the whole movie.
+		return ""
+	}
+
+	width, height, err := Dimensions(path)
+	if err != nil {
+		return ""
+	}
+
+	short, long := minMax(width, height)
+	minShort, minLong := minMax(limits.MinWidth, limits.MinHeight)
+
+	if short < minShort || long < minLong {
+		return fmt.Sprintf("dimensions %dx%d below minimum %dx%d",
+			width, height, limits.MinWidth, limits.MinHeight)
+	}
+
+	return ""
+}
@@ -0,0 +1,115 @@
+package exifsort
+
+import (
+	"image"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func testWritePNG(t *testing.T, path string, width int, height int) {
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	err = png.Encode(file, image.NewGray(image.Rect(0, 0, width, height)))
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDimensions(t *testing.T) {
+	tmpPath, err := ioutil.TempDir("", "Dimensions")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpPath)
+
+	thumbPath := filepath.Join(tmpPath, "thumb.png")
+	testWritePNG(t, thumbPath, 160, 120)
+
+	expected := map[string][2]int{
+		exifPath:       {4032, 3024}, // From exif
+		noExifPath:     {3024, 4032}, // From the JPEG header
+		noRootExifPath: {750, 500},
+		thumbPath:      {160, 120},
+	}
+
+	for path, dims := range expected {
+		width, height, err := Dimensions(path)
+		if err != nil {
+			t.Fatalf("Unexpected error %s\n", err.Error())
+		}
+
+		if width != dims[0] || height != dims[1] {
+			t.Errorf("%s: expected %v got %dx%d\n", path, dims, width, height)
+		}
+	}
+
+	_, _, err = Dimensions(skipPath)
+	if err == nil {
+		t.Errorf("Expected error for %s\n", skipPath)
+	}
+}
+
+func TestScanFilters(t *testing.T) {
+	t.Parallel()
+
+	tmpPath, err := ioutil.TempDir("", "ScanFilters")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpPath)
+
+	td := newTestDir(t, MethodNone, fileNoDefault)
+	td.populateExifFiles(tmpPath, 2)
+	// Portrait so the limits have to be rotated.
+	td.populateNoExifFiles(tmpPath, 1)
+	td.populateSkipFiles(tmpPath, 1)
+
+	for _, name := range []string{"empty.jpg", "empty.mov"} {
+		err = ioutil.WriteFile(filepath.Join(tmpPath, name), nil, 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	testWritePNG(t, filepath.Join(tmpPath, "thumb.png"), 160, 120)
+	// A movie is never measured, even one whose header we could read.
+	testWritePNG(t, filepath.Join(tmpPath, "clip.mts"), 160, 120)
+
+	s := NewScanner()
+	s.Filters = Filters{
+		CategoryExif:    {MinSize: 1, MinWidth: 640, MinHeight: 480},
+		CategoryMovie:   {MaxSize: 1024},
+		CategoryModTime: {MinWidth: 640, MinHeight: 480},
+	}
+	_ = s.ScanDir(tmpPath, NopObserver{})
+
+	// The empty movie is under the maximum and has no limit on its size.
+	filtered := []string{"empty.jpg", "thumb.png"}
+
+	for _, name := range filtered {
+		if _, present := s.Filtered[filepath.Join(tmpPath, name)]; !present {
+			t.Errorf("Expected %s to be filtered\n", name)
+		}
+	}
+
+	if len(s.Filtered) != len(filtered) {
+		t.Errorf("Expected %d filtered got %v\n", len(filtered), s.Filtered)
+	}
+
+	if len(s.Data) != td.numData+2 || s.SkippedCount != td.numSkipped {
+		t.Errorf("Expected %d data %d skipped got %d data %d skipped\n",
+			td.numData+2, td.numSkipped, len(s.Data), s.SkippedCount)
+	}
+
+	if s.NumTotal() != td.numTotal()+len(filtered)+2 {
+		t.Errorf("Expected %d total got %d\n",
+			td.numTotal()+len(filtered)+2, s.NumTotal())
+	}
+}
@@ -0,0 +1,199 @@
+package exifsort
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	// Only for go:embed.
+	_ "embed"
+)
+
+// gazetteerCities is the built-in list of places used by NewGazetteer:
+// every place of at least 1000 people GeoNames knows, one per line, as name,
+// latitude, longitude and ISO country code separated by tabs. See
+// geonames/README.md for where it comes from and its license.
+//
+//go:embed geonames/cities1000.tsv.gz
+var gazetteerCities []byte
+
+// Place is a named location in a Gazetteer.
+type Place struct {
+	Name    string
+	Country string
+	Coordinates
+}
+
+type gridCell struct {
+	lat int
+	lon int
+}
+
+// Gazetteer finds the place closest to coordinates without the network.
+// Places are kept in a grid of one degree cells so we only measure the
+// distance to places in the cells around the coordinates.
+type Gazetteer struct {
+	// MaxDistance is how far in kilometers a place may be and still be
+	// the place of coordinates.
+	MaxDistance float64
+	places      []Place
+	grid        map[gridCell][]int
+}
+
+// The columns of the built-in list and of GeoNames files such as
+// cities500.txt.
+type gazetteerColumns struct {
+	name    int
+	lat     int
+	lon     int
+	country int
+}
+
+func builtinColumns() gazetteerColumns  { return gazetteerColumns{0, 1, 2, 3} }
+func geoNamesColumns() gazetteerColumns { return gazetteerColumns{1, 4, 5, 8} }
+
+// The default MaxDistance in kilometers.
+const gazetteerMaxDistance = 50
+
+func newGridCell(c Coordinates) gridCell {
+	return gridCell{int(math.Floor(c.Latitude)), int(math.Floor(c.Longitude))}
+}
+
+func newGazetteer() *Gazetteer {
+	return &Gazetteer{
+		MaxDistance: gazetteerMaxDistance,
+		grid:        make(map[gridCell][]int),
+	}
+}
+
+func (g *Gazetteer) add(p Place) {
+	cell := newGridCell(p.Coordinates)
+	g.grid[cell] = append(g.grid[cell], len(g.places))
+	g.places = append(g.places, p)
+}
+
+func (g *Gazetteer) read(r io.Reader, columns gazetteerColumns) error {
+	scanner := bufio.NewScanner(r)
+	lineNo := 0
+
+	for scanner.Scan() {
+		lineNo++
+
+		line := scanner.Text()
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Split(line, "\t")
+		if len(fields) <= columns.country {
+			return fmt.Errorf("line %d: expected %d columns got %d",
+				lineNo, columns.country+1, len(fields))
+		}
+
+		lat, errLat := strconv.ParseFloat(fields[columns.lat], 64)
+		lon, errLon := strconv.ParseFloat(fields[columns.lon], 64)
+
+		if errLat != nil || errLon != nil || !coordinatesValid(lat, lon) {
+			return fmt.Errorf("line %d: bad coordinates", lineNo)
+		}
+
+		g.add(Place{
+			Name:        fields[columns.name],
+			Country:     fields[columns.country],
+			Coordinates: Coordinates{Latitude: lat, Longitude: lon},
+		})
+	}
+
+	return scanner.Err()
+}
+
+// Len returns the number of places in g.
+func (g *Gazetteer) Len() int {
+	return len(g.places)
+}
+
+// Nearest returns the place closest to c if it is within MaxDistance.
+func (g *Gazetteer) Nearest(c Coordinates) (Place, bool) {
+	const (
+		kmPerDegree = 111.0
+		maxCells    = 180
+	)
+
+	var (
+		nearest  Place
+		found    bool
+		distance = g.MaxDistance
+	)
+
+	center := newGridCell(c)
+	latCells := int(math.Ceil(g.MaxDistance / kmPerDegree))
+
+	// Degrees of longitude get shorter away from the equator, we use the
+	// shortest ones we may search.
+	const maxLatitude = 89
+
+	farthest := math.Min(math.Abs(c.Latitude)+float64(latCells), maxLatitude)
+	lonKm := kmPerDegree * math.Cos(degreesToRadians(farthest))
+	lonCells := int(math.Min(math.Ceil(g.MaxDistance/lonKm), maxCells))
+
+	for lat := center.lat - latCells; lat <= center.lat+latCells; lat++ {
+		for lon := center.lon - lonCells; lon <= center.lon+lonCells; lon++ {
+			// Wrap around the antimeridian.
+			wrapped := (lon+540)%360 - 180
+
+			for _, i := range g.grid[gridCell{lat, wrapped}] {
+				d := c.Distance(g.places[i].Coordinates)
+				if d <= distance {
+					nearest, distance, found = g.places[i], d, true
+				}
+			}
+		}
+	}
+
+	return nearest, found
+}
+
+// NewGazetteer returns a Gazetteer of the built-in list of places of at least
+// 1000 people. LoadGazetteer reads another, such as one of smaller places.
+func NewGazetteer() *Gazetteer {
+	g := newGazetteer()
+
+	// The built-in list is ours, it can't be bad.
+	r, _ := gzip.NewReader(bytes.NewReader(gazetteerCities))
+	_ = g.read(r, builtinColumns())
+
+	return g
+}
+
+// LoadGazetteer reads a Gazetteer from a GeoNames file such as cities500.txt
+// from https://download.geonames.org/export/dump/.
+func LoadGazetteer(path string) (*Gazetteer, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	g := newGazetteer()
+
+	err = g.read(file, geoNamesColumns())
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return g, nil
+}
+
+func gazetteerOrDefault(g *Gazetteer) *Gazetteer {
+	if g == nil {
+		return NewGazetteer()
+	}
+
+	return g
+}
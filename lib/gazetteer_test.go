@@ -0,0 +1,101 @@
+package exifsort
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGazetteerNearest(t *testing.T) {
+	g := NewGazetteer()
+
+	if g.Len() == 0 {
+		t.Fatalf("Expected built-in places\n")
+	}
+
+	// Places of a thousand people, even the parts of big cities, are places.
+	expected := map[Coordinates]string{
+		{Latitude: 37.28519, Longitude: -122.02392}: "Saratoga",
+		{Latitude: 38.7167, Longitude: -9.1333}:     "Lisbon",
+		{Latitude: 38.7124, Longitude: -9.1305}:     "Alfama",
+		{Latitude: -33.8678, Longitude: 151.2073}:   "Sydney",
+		{Latitude: 64.14, Longitude: -21.90}:        "Reykjavík",
+		{Latitude: 69.6489, Longitude: 18.9551}:     "Tromsø",
+	}
+
+	for c, name := range expected {
+		place, found := g.Nearest(c)
+		if !found || place.Name != name {
+			t.Errorf("%v: expected %s got %s\n", c, name, place.Name)
+		}
+	}
+
+	// The middle of the Pacific.
+	_, found := g.Nearest(Coordinates{Latitude: -30, Longitude: -140})
+	if found {
+		t.Errorf("Expected no place in the middle of the ocean\n")
+	}
+
+	g.MaxDistance = 1
+
+	_, found = g.Nearest(Coordinates{Latitude: 38.9, Longitude: -9.14})
+	if found {
+		t.Errorf("Expected no place within 1 km\n")
+	}
+}
+
+func TestLoadGazetteer(t *testing.T) {
+	t.Parallel()
+
+	tmpPath, err := ioutil.TempDir("", "LoadGazetteer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpPath)
+
+	// Lines in the GeoNames columns.
+	content := "2267057\tLisbon\tLisbon\tLisboa\t38.71667\t-9.13333\tP\tPPLC\tPT\n" +
+		"2735943\tPorto\tPorto\tOporto\t41.14961\t-8.61099\tP\tPPLA\tPT\n" +
+		"1\tEdge\tEdge\t\t-16.8\t179.9\tP\tPPL\tFJ\n"
+
+	goodPath := filepath.Join(tmpPath, "cities.txt")
+
+	err = ioutil.WriteFile(goodPath, []byte(content), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g, err := LoadGazetteer(goodPath)
+	if err != nil {
+		t.Fatalf("Unexpected error %s\n", err.Error())
+	}
+
+	place, found := g.Nearest(Coordinates{Latitude: 41.1, Longitude: -8.6})
+	if g.Len() != 3 || !found || place.Name != "Porto" || place.Country != "PT" {
+		t.Errorf("Unexpected place %v in %d places\n", place, g.Len())
+	}
+
+	// Across the antimeridian.
+	place, found = g.Nearest(Coordinates{Latitude: -16.8, Longitude: -179.9})
+	if !found || place.Name != "Edge" {
+		t.Errorf("Expected Edge across the antimeridian got %v\n", place)
+	}
+
+	badPath := filepath.Join(tmpPath, "bad.txt")
+
+	err = ioutil.WriteFile(badPath, []byte("Lisbon\t38.7\t-9.1\tPT\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = LoadGazetteer(badPath)
+	if err == nil {
+		t.Errorf("Expected error for too few columns\n")
+	}
+
+	_, err = LoadGazetteer(filepath.Join(tmpPath, "missing.txt"))
+	if err == nil {
+		t.Errorf("Expected error for a missing file\n")
+	}
+}
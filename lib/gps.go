@@ -0,0 +1,175 @@
+package exifsort
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/dsoprea/go-exif/v2"
+	exifcommon "github.com/dsoprea/go-exif/v2/common"
+)
+
+const earthRadiusKm = 6371.0
+
+// Coordinates are where a photo was taken. Latitude and Longitude are in
+// degrees, negative to the south and west. Altitude is in meters above sea
+// level.
+type Coordinates struct {
+	Latitude  float64
+	Longitude float64
+	Altitude  float64 `json:",omitempty"`
+}
+
+func (c Coordinates) String() string {
+	return fmt.Sprintf("%.5f,%.5f", c.Latitude, c.Longitude)
+}
+
+func degreesToRadians(degrees float64) float64 {
+	return degrees * math.Pi / 180
+}
+
+// Distance returns the distance in kilometers to o over the surface of the
+// earth, ignoring altitude.
+func (c Coordinates) Distance(o Coordinates) float64 {
+	lat1 := degreesToRadians(c.Latitude)
+	lat2 := degreesToRadians(o.Latitude)
+	dLat := lat2 - lat1
+	dLon := degreesToRadians(o.Longitude - c.Longitude)
+
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+
+	return 2 * earthRadiusKm * math.Asin(math.Min(1, math.Sqrt(a)))
+}
+
+func coordinatesValid(lat float64, lon float64) bool {
+	const (
+		maxLatitude  = 90
+		maxLongitude = 180
+	)
+
+	return lat >= -maxLatitude && lat <= maxLatitude &&
+		lon >= -maxLongitude && lon <= maxLongitude
+}
+
+// exifAltitude returns GPSAltitude in meters, below sea level when
+// GPSAltitudeRef is 1.
+func exifAltitude(gpsIfd *exif.Ifd) float64 {
+	results, err := gpsIfd.FindTagWithName("GPSAltitude")
+	if err != nil || len(results) == 0 {
+		return 0
+	}
+
+	value, err := results[0].Value()
+	if err != nil {
+		return 0
+	}
+
+	rationals, ok := value.([]exifcommon.Rational)
+	if !ok || len(rationals) == 0 || rationals[0].Denominator == 0 {
+		return 0
+	}
+
+	altitude := float64(rationals[0].Numerator) / float64(rationals[0].Denominator)
+
+	results, err = gpsIfd.FindTagWithName("GPSAltitudeRef")
+	if err == nil && len(results) != 0 {
+		ref, _ := results[0].Value()
+		if bytes, ok := ref.([]uint8); ok && len(bytes) != 0 && bytes[0] == 1 {
+			altitude = -altitude
+		}
+	}
+
+	return altitude
+}
+
+// exifGPS returns the coordinates in the GPS IFD, or nil if there are none.
+func exifGPS(rootIfd *exif.Ifd) *Coordinates {
+	gpsIfd, err := exif.FindIfdFromRootIfd(rootIfd, "IFD/GPSInfo")
+	if err != nil {
+		return nil
+	}
+
+	gpsInfo, err := gpsIfd.GpsInfo()
+	if err != nil {
+		return nil
+	}
+
+	lat := gpsInfo.Latitude.Decimal()
+	lon := gpsInfo.Longitude.Decimal()
+
+	// Cameras without a fix may write zeros or garbage.
+	if !coordinatesValid(lat, lon) || (lat == 0 && lon == 0) {
+		return nil
+	}
+
+	return &Coordinates{lat, lon, exifAltitude(gpsIfd)}
+}
+
+// Near selects media taken within Radius kilometers of Center. The zero Near
+// selects all media.
+type Near struct {
+	Center Coordinates
+	Radius float64
+}
+
+// IsZero reports if n selects all media.
+func (n Near) IsZero() bool {
+	return n == Near{}
+}
+
+// Contains reports if gps is within n. Media without coordinates is only in
+// the zero Near.
+func (n Near) Contains(gps *Coordinates) bool {
+	if n.IsZero() {
+		return true
+	}
+
+	if gps == nil {
+		return false
+	}
+
+	return n.Center.Distance(*gps) <= n.Radius
+}
+
+// ParseNear parses "<latitude>,<longitude>,<radius>" with the radius in
+// kilometers such as "38.72,-9.14,25". The radius may end in "km" or "m".
+func ParseNear(str string) (Near, error) {
+	const (
+		numNearSplit = 3
+		metersPerKm  = 1000
+	)
+
+	var n Near
+
+	parts := strings.Split(str, ",")
+	if len(parts) != numNearSplit {
+		return n, fmt.Errorf("invalid near %s, expected <lat>,<lon>,<radius>", str)
+	}
+
+	lat, errLat := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+	lon, errLon := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+
+	if errLat != nil || errLon != nil || !coordinatesValid(lat, lon) {
+		return n, fmt.Errorf("invalid near %s, bad coordinates", str)
+	}
+
+	radiusStr := strings.ToLower(strings.TrimSpace(parts[2]))
+	scale := 1.0
+
+	switch {
+	case strings.HasSuffix(radiusStr, "km"):
+		radiusStr = strings.TrimSuffix(radiusStr, "km")
+	case strings.HasSuffix(radiusStr, "m"):
+		radiusStr = strings.TrimSuffix(radiusStr, "m")
+		scale = 1.0 / metersPerKm
+	}
+
+	radius, err := strconv.ParseFloat(radiusStr, 64)
+	if err != nil || radius <= 0 {
+		return n, fmt.Errorf("invalid near %s, bad radius", str)
+	}
+
+	return Near{Coordinates{Latitude: lat, Longitude: lon}, radius * scale}, nil
+}
@@ -0,0 +1,106 @@
+package exifsort
+
+import (
+	"math"
+	"testing"
+)
+
+func TestCoordinatesDistance(t *testing.T) {
+	lisbon := Coordinates{Latitude: 38.72, Longitude: -9.14}
+	porto := Coordinates{Latitude: 41.15, Longitude: -8.61}
+
+	// About 274 km apart.
+	d := lisbon.Distance(porto)
+	if math.Abs(d-274) > 5 {
+		t.Errorf("Expected about 274 km got %f\n", d)
+	}
+
+	if lisbon.Distance(lisbon) != 0 {
+		t.Errorf("Expected no distance to itself\n")
+	}
+}
+
+func TestParseNear(t *testing.T) {
+	goodInput := map[string]Near{
+		"38.72,-9.14,25":     {Coordinates{Latitude: 38.72, Longitude: -9.14}, 25},
+		"38.72, -9.14, 25km": {Coordinates{Latitude: 38.72, Longitude: -9.14}, 25},
+		"0,0,500m":           {Coordinates{}, 0.5},
+	}
+
+	for input, expected := range goodInput {
+		near, err := ParseNear(input)
+		if err != nil {
+			t.Errorf("%s: unexpected error %s\n", input, err.Error())
+			continue
+		}
+
+		if near != expected {
+			t.Errorf("%s: expected %v got %v\n", input, expected, near)
+		}
+	}
+
+	badInput := []string{
+		"",
+		"38.72,-9.14",
+		"38.72,-9.14,25,1",
+		"91,0,1",
+		"0,181,1",
+		"a,0,1",
+		"0,0,0",
+		"0,0,-1",
+		"0,0,far",
+	}
+
+	for _, input := range badInput {
+		_, err := ParseNear(input)
+		if err == nil {
+			t.Errorf("%s: expected error\n", input)
+		}
+	}
+}
+
+func TestNearContains(t *testing.T) {
+	var all Near
+	if !all.Contains(nil) {
+		t.Errorf("Expected the zero near to contain everything\n")
+	}
+
+	near := Near{Coordinates{Latitude: 38.72, Longitude: -9.14}, 25}
+
+	if !near.Contains(&Coordinates{Latitude: 38.80, Longitude: -9.38}) {
+		t.Errorf("Expected Sintra near Lisbon\n")
+	}
+
+	if near.Contains(&Coordinates{Latitude: 41.15, Longitude: -8.61}) {
+		t.Errorf("Expected Porto not near Lisbon\n")
+	}
+
+	if near.Contains(nil) {
+		t.Errorf("Expected no coordinates not to be near\n")
+	}
+}
+
+func TestExifGPS(t *testing.T) {
+	info, err := ExifInfoGet(exifPath)
+	if err != nil {
+		t.Fatalf("Unexpected error %s\n", err.Error())
+	}
+
+	if info.GPS == nil {
+		t.Fatalf("Expected coordinates in %s\n", exifPath)
+	}
+
+	const tolerance = 0.001
+
+	gps := *info.GPS
+	if math.Abs(gps.Latitude-37.28519) > tolerance ||
+		math.Abs(gps.Longitude+122.02392) > tolerance ||
+		math.Abs(gps.Altitude-93.43) > 0.01 {
+		t.Errorf("Unexpected coordinates %v altitude %f\n", gps, gps.Altitude)
+	}
+
+	info, _ = ExifInfoGet(noExifPath)
+	if info.GPS != nil {
+		t.Errorf("Expected no coordinates in %s\n", noExifPath)
+	}
+}
@@ -73,7 +73,9 @@ func (n *node) getNode(id int) node {
 // Add a file to the mediaMap. It needs to handle collisions, duplicates, etc.
 func (n *node) mediaAdd(path string) error {
 	// Use collisionRename to find a name that won't collide with others.
-	base, err := uniqueName(path, func(filename string) string { return n.media[filename] })
+	// Sorter gives NamingHash names to the ones renamed.
+	base, err := uniqueName(path, NamingCounter,
+		func(filename string) string { return n.media[filename] })
 	if err != nil {
 		return err
 	}
@@ -290,6 +292,217 @@ func (d dayIndex) String() string {
 	return retStr
 }
 
+const daysPerWeek = 7
+
+// isoWeekStart returns the Monday that starts ISO week of year.
+func isoWeekStart(year int, week int) time.Time {
+	// January 4th is always in the first week.
+	jan4 := time.Date(year, time.January, 4, 0, 0, 0, 0, time.Local)
+	offset := (int(jan4.Weekday()) + daysPerWeek - 1) % daysPerWeek
+
+	return jan4.AddDate(0, 0, (week-1)*daysPerWeek-offset)
+}
+
+// weekIndex will sort the paths by ISO week. Weeks are kept in the year they
+// belong to by ISO 8601, which is not always the calendar year.
+type weekIndex struct {
+	n node
+}
+
+func (w *weekIndex) Put(path string, time time.Time) error {
+	year, week := time.ISOWeek()
+	yearNode := w.n.getNode(year)
+	weekNode := yearNode.getNode(week)
+
+	return weekNode.mediaAdd(path)
+}
+
+func (w *weekIndex) PathStr(time time.Time, base string) string {
+	year, week := time.ISOWeek()
+
+	return filepath.Join(fmt.Sprintf("%04d", year),
+		fmt.Sprintf("%04d-W%02d", year, week), base)
+}
+
+func (w *weekIndex) Get(path string) (string, bool) {
+	soughtBase := filepath.Base(path)
+
+	for year, yearNode := range w.n.children {
+		for week, weekNode := range yearNode.children {
+			if _, present := weekNode.media[soughtBase]; present {
+				return w.PathStr(isoWeekStart(year, week), soughtBase), true
+			}
+		}
+	}
+
+	return "", false
+}
+
+func (w *weekIndex) GetAll() mediaMap {
+	var retMap = make(mediaMap)
+
+	for year, yearNode := range w.n.children {
+		for week, weekNode := range yearNode.children {
+			for base, oldPath := range weekNode.media {
+				newPath := w.PathStr(isoWeekStart(year, week), base)
+				retMap[newPath] = oldPath
+			}
+		}
+	}
+
+	return retMap
+}
+
+func (w weekIndex) String() string {
+	var retStr string
+
+	media := w.GetAll()
+
+	keys := w.n.sortMediaKeys(media)
+	for _, newPath := range keys {
+		oldPath := media[newPath]
+		retStr += fmt.Sprintf("%s => %s\n", oldPath, newPath)
+	}
+
+	return retStr
+}
+
+const monthsPerQuarter = 3
+
+func quarterOf(month time.Month) int {
+	return (int(month)-1)/monthsPerQuarter + 1
+}
+
+// quarterIndex will sort the paths by quarter of the year.
+type quarterIndex struct {
+	n node
+}
+
+func (q *quarterIndex) Put(path string, time time.Time) error {
+	yearNode := q.n.getNode(time.Year())
+	quarterNode := yearNode.getNode(quarterOf(time.Month()))
+
+	return quarterNode.mediaAdd(path)
+}
+
+func (q *quarterIndex) PathStr(time time.Time, base string) string {
+	year := fmt.Sprintf("%04d", time.Year())                                 // Year label
+	quarter := fmt.Sprintf("%04d-Q%d", time.Year(), quarterOf(time.Month())) // Quarter label
+
+	return filepath.Join(year, quarter, base)
+}
+
+// quarterStart returns the first day of quarter of year.
+func quarterStart(year int, quarter int) time.Time {
+	month := time.Month((quarter-1)*monthsPerQuarter + 1)
+	return time.Date(year, month, 1, 0, 0, 0, 0, time.Local)
+}
+
+func (q *quarterIndex) Get(path string) (string, bool) {
+	soughtBase := filepath.Base(path)
+
+	for year, yearNode := range q.n.children {
+		for quarter, quarterNode := range yearNode.children {
+			if _, present := quarterNode.media[soughtBase]; present {
+				return q.PathStr(quarterStart(year, quarter), soughtBase), true
+			}
+		}
+	}
+
+	return "", false
+}
+
+func (q *quarterIndex) GetAll() mediaMap {
+	var retMap = make(mediaMap)
+
+	for year, yearNode := range q.n.children {
+		for quarter, quarterNode := range yearNode.children {
+			for base, oldPath := range quarterNode.media {
+				newPath := q.PathStr(quarterStart(year, quarter), base)
+				retMap[newPath] = oldPath
+			}
+		}
+	}
+
+	return retMap
+}
+
+func (q quarterIndex) String() string {
+	var retStr string
+
+	media := q.GetAll()
+
+	keys := q.n.sortMediaKeys(media)
+	for _, newPath := range keys {
+		oldPath := media[newPath]
+		retStr += fmt.Sprintf("%s => %s\n", oldPath, newPath)
+	}
+
+	return retStr
+}
+
+// yearMonthIndex will sort the paths by month in one flat level of
+// directories.
+type yearMonthIndex struct {
+	n node
+}
+
+func (m *yearMonthIndex) Put(path string, time time.Time) error {
+	yearNode := m.n.getNode(time.Year())
+	monthNode := yearNode.getNode(int(time.Month()))
+
+	return monthNode.mediaAdd(path)
+}
+
+func (m *yearMonthIndex) PathStr(time time.Time, base string) string {
+	month := fmt.Sprintf("%04d-%02d", time.Year(), time.Month()) // Month label
+	return filepath.Join(month, base)
+}
+
+func (m *yearMonthIndex) Get(path string) (string, bool) {
+	soughtBase := filepath.Base(path)
+
+	for year, yearNode := range m.n.children {
+		for month, monthNode := range yearNode.children {
+			if _, present := monthNode.media[soughtBase]; present {
+				time := time.Date(year, time.Month(month), 1, 1, 1, 1, 1, time.Local)
+				return m.PathStr(time, soughtBase), true
+			}
+		}
+	}
+
+	return "", false
+}
+
+func (m *yearMonthIndex) GetAll() mediaMap {
+	var retMap = make(mediaMap)
+
+	for year, yearNode := range m.n.children {
+		for month, monthNode := range yearNode.children {
+			for base, oldPath := range monthNode.media {
+				time := time.Date(year, time.Month(month), 1, 1, 1, 1, 1, time.Local)
+				retMap[m.PathStr(time, base)] = oldPath
+			}
+		}
+	}
+
+	return retMap
+}
+
+func (m yearMonthIndex) String() string {
+	var retStr string
+
+	media := m.GetAll()
+
+	keys := m.n.sortMediaKeys(media)
+	for _, newPath := range keys {
+		oldPath := media[newPath]
+		retStr += fmt.Sprintf("%s => %s\n", oldPath, newPath)
+	}
+
+	return retStr
+}
+
 type index interface {
 	Get(string) (string, bool)
 	GetAll() mediaMap
@@ -318,6 +531,26 @@ func newIndex(method Method) (index, error) {
 		d.n.init(rootIndex)
 
 		return &d, nil
+	case MethodEvent:
+		return &eventIndex{gap: EventGap}, nil
+	case MethodWeek:
+		var w weekIndex
+
+		w.n.init(rootIndex)
+
+		return &w, nil
+	case MethodQuarter:
+		var q quarterIndex
+
+		q.n.init(rootIndex)
+
+		return &q, nil
+	case MethodYearMonth:
+		var m yearMonthIndex
+
+		m.n.init(rootIndex)
+
+		return &m, nil
 	default:
 		errStr := fmt.Sprintf("Invalid method %s\n", method)
 		return nil, errors.New(errStr)
@@ -3,6 +3,7 @@ package exifsort
 import (
 	"fmt"
 	"io/ioutil"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
@@ -183,3 +184,44 @@ func TestIndexBadGet(t *testing.T) {
 		}
 	}
 }
+
+func TestIndexPathStr(t *testing.T) {
+	expected := map[Method]map[time.Time]string{
+		MethodWeek: {
+			// ISO weeks may belong to the year before or after.
+			time.Date(2019, 12, 30, 0, 0, 0, 0, time.Local): "2020/2020-W01/x.jpg",
+			time.Date(2020, 4, 27, 0, 0, 0, 0, time.Local):  "2020/2020-W18/x.jpg",
+			time.Date(2020, 12, 31, 0, 0, 0, 0, time.Local): "2020/2020-W53/x.jpg",
+			time.Date(2021, 1, 3, 0, 0, 0, 0, time.Local):   "2020/2020-W53/x.jpg",
+		},
+		MethodQuarter: {
+			time.Date(2020, 1, 1, 0, 0, 0, 0, time.Local):   "2020/2020-Q1/x.jpg",
+			time.Date(2020, 4, 27, 0, 0, 0, 0, time.Local):  "2020/2020-Q2/x.jpg",
+			time.Date(2020, 9, 30, 0, 0, 0, 0, time.Local):  "2020/2020-Q3/x.jpg",
+			time.Date(2020, 12, 31, 0, 0, 0, 0, time.Local): "2020/2020-Q4/x.jpg",
+		},
+		MethodYearMonth: {
+			time.Date(2020, 4, 27, 0, 0, 0, 0, time.Local): "2020-04/x.jpg",
+		},
+	}
+
+	for method, paths := range expected {
+		idx, _ := newIndex(method)
+
+		for time, path := range paths {
+			got := idx.PathStr(time, "x.jpg")
+			if filepath.ToSlash(got) != path {
+				t.Errorf("%s %s: expected %s got %s\n", method, time, path, got)
+			}
+		}
+	}
+
+	for year := 2015; year < 2030; year++ {
+		for _, week := range []int{1, 20, 52} {
+			gotYear, gotWeek := isoWeekStart(year, week).ISOWeek()
+			if gotYear != year || gotWeek != week {
+				t.Errorf("Week %d of %d starts in week %d of %d\n", week, year, gotWeek, gotYear)
+			}
+		}
+	}
+}
@@ -0,0 +1,324 @@
+package exifsort
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ManifestName is the name of the manifest file at the root of a sorted
+// directory.
+const ManifestName = ".exifsort.sha256"
+
+// ManifestEntry is the size and SHA-256 of a file when it was added.
+type ManifestEntry struct {
+	Size   int64
+	SHA256 string
+}
+
+// Manifest records the size and SHA-256 of every file transferred into a
+// sorted directory so we can tell later if any changed or went missing. It
+// is saved as lines of "<sha256>\t<size>\t<path>" with paths relative to the
+// root in ManifestName. Like sha256sum, a line whose path holds a "\" or a new
+// line starts with "\" and has them escaped as "\\" and "\n".
+type Manifest struct {
+	// Entries maps paths relative to the root, with "/" separators, to
+	// what they were.
+	Entries map[string]ManifestEntry
+	root    string
+}
+
+// ManifestCheck is what Manifest.Check found.
+type ManifestCheck struct {
+	// Verified is the number of files that are as they were.
+	Verified int
+	// Modified are the files whose size or SHA-256 changed.
+	Modified []string
+	// Missing are the files in the manifest that are gone.
+	Missing []string
+	// Unexpected are the files that are not in the manifest.
+	Unexpected []string
+	// Errors holds the files that could not be read.
+	Errors map[string]string
+}
+
+// hashFile returns the SHA-256 and size of the file at path.
+func hashFile(path string) (ManifestEntry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return ManifestEntry{}, err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+
+	size, err := io.Copy(hash, file)
+	if err != nil {
+		return ManifestEntry{}, err
+	}
+
+	return ManifestEntry{size, hex.EncodeToString(hash.Sum(nil))}, nil
+}
+
+// rootKey returns path relative to root with "/" separators, the name of
+// path in the manifest and catalog of root.
+func rootKey(root string, path string) (string, error) {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return "", err
+	}
+
+	if rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%s is not in %s", path, root)
+	}
+
+	return filepath.ToSlash(rel), nil
+}
+
+// key returns the name of path in the manifest.
+func (m *Manifest) key(path string) (string, error) {
+	return rootKey(m.root, path)
+}
+
+// Add hashes the file at path, which must be below the root, and records it.
+func (m *Manifest) Add(path string) error {
+	key, err := m.key(path)
+	if err != nil {
+		return err
+	}
+
+	entry, err := hashFile(path)
+	if err != nil {
+		return err
+	}
+
+	m.Entries[key] = entry
+
+	return nil
+}
+
+// Moved records that the file at oldPath was moved to newPath. What was
+// recorded for oldPath is kept so a change made by the move is caught later,
+// if oldPath is not in the manifest newPath is hashed.
+func (m *Manifest) Moved(oldPath string, newPath string) error {
+	oldKey, err := m.key(oldPath)
+	if err != nil {
+		return m.Add(newPath)
+	}
+
+	entry, present := m.Entries[oldKey]
+	if !present {
+		return m.Add(newPath)
+	}
+
+	newKey, err := m.key(newPath)
+	if err != nil {
+		return err
+	}
+
+	delete(m.Entries, oldKey)
+	m.Entries[newKey] = entry
+
+	return nil
+}
+
+// Remove forgets the file at path.
+func (m *Manifest) Remove(path string) {
+	key, err := m.key(path)
+	if err == nil {
+		delete(m.Entries, key)
+	}
+}
+
+// Check hashes every file below the root again. Files and directories
+// excluded by rules are left out, nil means NewRules().
+func (m *Manifest) Check(rules *Rules) (ManifestCheck, error) {
+	check := ManifestCheck{Errors: make(map[string]string)}
+	seen := make(map[string]bool)
+
+	err := rulesOrDefault(rules).Walk(m.root,
+		func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				check.Errors[path] = err.Error()
+				return nil
+			}
+
+			key, _ := m.key(path)
+			if info.IsDir() || key == ManifestName || key == CatalogName {
+				return nil
+			}
+
+			seen[key] = true
+
+			want, present := m.Entries[key]
+			if !present {
+				check.Unexpected = append(check.Unexpected, path)
+				return nil
+			}
+
+			got, err := hashFile(path)
+
+			switch {
+			case err != nil:
+				check.Errors[path] = err.Error()
+			case got != want:
+				check.Modified = append(check.Modified, path)
+			default:
+				check.Verified++
+			}
+
+			return nil
+		})
+	if err != nil {
+		return check, err
+	}
+
+	for key := range m.Entries {
+		if !seen[key] {
+			check.Missing = append(check.Missing, filepath.Join(m.root, filepath.FromSlash(key)))
+		}
+	}
+
+	sort.Strings(check.Missing)
+
+	return check, nil
+}
+
+// manifestEscape escapes path for a line of the manifest. It returns false if
+// path needs no escaping.
+func manifestEscape(path string) (string, bool) {
+	if !strings.ContainsAny(path, "\\\n") {
+		return path, false
+	}
+
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(path), true
+}
+
+// manifestUnescape reverses manifestEscape.
+func manifestUnescape(escaped string) (string, error) {
+	var path strings.Builder
+
+	for ii := 0; ii < len(escaped); ii++ {
+		if escaped[ii] != '\\' {
+			path.WriteByte(escaped[ii])
+			continue
+		}
+
+		ii++
+
+		switch {
+		case ii == len(escaped):
+			return "", fmt.Errorf("path %s ends in an escape", escaped)
+		case escaped[ii] == '\\':
+			path.WriteByte('\\')
+		case escaped[ii] == 'n':
+			path.WriteByte('\n')
+		default:
+			return "", fmt.Errorf("path %s has unknown escape \\%c", escaped, escaped[ii])
+		}
+	}
+
+	return path.String(), nil
+}
+
+// Save writes the manifest to ManifestName in the root.
+func (m *Manifest) Save() error {
+	keys := make([]string, 0, len(m.Entries))
+	for key := range m.Entries {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	var content strings.Builder
+
+	for _, key := range keys {
+		entry := m.Entries[key]
+
+		path, escaped := manifestEscape(key)
+		if escaped {
+			content.WriteString(`\`)
+		}
+
+		fmt.Fprintf(&content, "%s\t%d\t%s\n", entry.SHA256, entry.Size, path)
+	}
+
+	return ioutil.WriteFile(filepath.Join(m.root, ManifestName), []byte(content.String()), 0600)
+}
+
+func (m *Manifest) read(r io.Reader) error {
+	const numManifestFields = 3
+
+	scanner := bufio.NewScanner(r)
+	lineNo := 0
+
+	for scanner.Scan() {
+		lineNo++
+
+		line := scanner.Text()
+		escaped := strings.HasPrefix(line, `\`)
+
+		if escaped {
+			line = line[1:]
+		}
+
+		fields := strings.SplitN(line, "\t", numManifestFields)
+		if len(fields) != numManifestFields {
+			return fmt.Errorf("line %d: expected %d fields got %d",
+				lineNo, numManifestFields, len(fields))
+		}
+
+		size, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("line %d: bad size %s", lineNo, fields[1])
+		}
+
+		path := fields[2]
+		if escaped {
+			path, err = manifestUnescape(path)
+			if err != nil {
+				return fmt.Errorf("line %d: %w", lineNo, err)
+			}
+		}
+
+		m.Entries[path] = ManifestEntry{size, fields[0]}
+	}
+
+	return scanner.Err()
+}
+
+// ManifestExists reports if the sorted directory root has a manifest.
+func ManifestExists(root string) bool {
+	return exists(filepath.Join(root, ManifestName))
+}
+
+// LoadManifest reads the manifest of the sorted directory root. A root
+// without one has an empty manifest.
+func LoadManifest(root string) (*Manifest, error) {
+	m := &Manifest{Entries: make(map[string]ManifestEntry), root: root}
+
+	file, err := os.Open(filepath.Join(root, ManifestName))
+	if os.IsNotExist(err) {
+		return m, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	err = m.read(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file.Name(), err)
+	}
+
+	return m, nil
+}
@@ -0,0 +1,169 @@
+package exifsort
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// manifestFiles returns the files of root that are not the manifest.
+func manifestFiles(t *testing.T, root string) []string {
+	var paths []string
+
+	_ = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() && info.Name() != ManifestName {
+			paths = append(paths, path)
+		}
+
+		return nil
+	})
+
+	if len(paths) < 2 {
+		t.Fatalf("Expected at least 2 files in %s got %d\n", root, len(paths))
+	}
+
+	return paths
+}
+
+func TestManifest(t *testing.T) {
+	t.Parallel()
+
+	td := newTestDir(t, MethodMonth, fileNoDefault)
+	src := td.buildRoot()
+
+	defer os.RemoveAll(src)
+
+	m, err := LoadManifest(src)
+	if err != nil || len(m.Entries) != 0 {
+		t.Fatalf("Expected an empty manifest got %d entries err %v\n", len(m.Entries), err)
+	}
+
+	paths := manifestFiles(t, src)
+	for _, path := range paths {
+		err = m.Add(path)
+		if err != nil {
+			t.Fatalf("Unexpected error %s\n", err.Error())
+		}
+	}
+
+	err = m.Save()
+	if err != nil || !ManifestExists(src) {
+		t.Fatalf("Expected a saved manifest err %v\n", err)
+	}
+
+	m, err = LoadManifest(src)
+	if err != nil || len(m.Entries) != len(paths) {
+		t.Fatalf("Expected %d entries got %d err %v\n", len(paths), len(m.Entries), err)
+	}
+
+	check, err := m.Check(nil)
+	if err != nil || check.Verified != len(paths) {
+		t.Fatalf("Expected %d verified got %d err %v\n", len(paths), check.Verified, err)
+	}
+
+	modified, missing := paths[0], paths[1]
+	unexpected := filepath.Join(src, "new.jpg")
+
+	_ = ioutil.WriteFile(modified, []byte("rot"), 0600)
+	_ = os.Remove(missing)
+	_ = ioutil.WriteFile(unexpected, []byte("new"), 0600)
+
+	check, err = m.Check(nil)
+	if err != nil {
+		t.Fatalf("Unexpected error %s\n", err.Error())
+	}
+
+	if check.Verified != len(paths)-2 {
+		t.Errorf("Expected %d verified got %d\n", len(paths)-2, check.Verified)
+	}
+
+	expected := map[string][]string{
+		modified:   check.Modified,
+		missing:    check.Missing,
+		unexpected: check.Unexpected,
+	}
+
+	for path, got := range expected {
+		if len(got) != 1 || got[0] != path {
+			t.Errorf("Expected only %s got %v\n", path, got)
+		}
+	}
+}
+
+func TestManifestMoved(t *testing.T) {
+	root := filepath.Join("a", "sorted")
+	m := &Manifest{Entries: make(map[string]ManifestEntry), root: root}
+	entry := ManifestEntry{1, "abc"}
+
+	m.Entries["2020/2020_04/x.jpg"] = entry
+
+	err := m.Moved(filepath.Join(root, "2020", "2020_04", "x.jpg"),
+		filepath.Join(root, "2020", "x.jpg"))
+	if err != nil {
+		t.Fatalf("Unexpected error %s\n", err.Error())
+	}
+
+	if len(m.Entries) != 1 || m.Entries["2020/x.jpg"] != entry {
+		t.Errorf("Expected the entry to move got %v\n", m.Entries)
+	}
+
+	err = m.Add(filepath.Join("a", "x.jpg"))
+	if err == nil {
+		t.Errorf("Expected error adding a file outside the root\n")
+	}
+}
+
+func TestManifestEscape(t *testing.T) {
+	root, _ := ioutil.TempDir("", "manifest_escape_")
+	defer os.RemoveAll(root)
+
+	m := &Manifest{Entries: make(map[string]ManifestEntry), root: root}
+	paths := []string{"2020/plain.jpg", "2020/back\\slash.jpg", "2020/new\nline.jpg",
+		"2020/tab\tand\\n.jpg"}
+
+	for i, path := range paths {
+		m.Entries[path] = ManifestEntry{int64(i), "abc"}
+	}
+
+	err := m.Save()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	content, _ := ioutil.ReadFile(filepath.Join(root, ManifestName))
+	if lines := strings.Count(string(content), "\n"); lines != len(paths) {
+		t.Errorf("Expected %d lines got %d\n", len(paths), lines)
+	}
+
+	loaded, err := LoadManifest(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for path, entry := range m.Entries {
+		if loaded.Entries[path] != entry {
+			t.Errorf("Expected %q to load as %v got %v\n", path, entry,
+				loaded.Entries[path])
+		}
+	}
+}
+
+func TestManifestBad(t *testing.T) {
+	badLines := []string{
+		"abc\t1",
+		"abc\tone\tx.jpg",
+		"\\abc\t1\tx\\q.jpg",
+		"\\abc\t1\tx\\",
+	}
+
+	for _, line := range badLines {
+		m := &Manifest{Entries: make(map[string]ManifestEntry)}
+
+		err := m.read(strings.NewReader(line))
+		if err == nil {
+			t.Errorf("Expected error reading %q\n", line)
+		}
+	}
+}
@@ -3,16 +3,59 @@ package exifsort
 import (
 	"errors"
 	"fmt"
-	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"regexp"
 	"strings"
+	"time"
 )
 
 // Merger holds the API and statistics to merge sorted directories.
 type Merger struct {
+	// Extensions decides which files are media. Nil means NewExtensions().
+	Extensions Extensions
+	// Rules leave files and directories out. Nil means NewRules().
+	Rules *Rules
+	// Range only merges files whose sorted directory is entirely in it.
+	// The zero DateRange merges everything.
+	Range DateRange
+	// OutOfRange is the number of files left out by Range.
+	OutOfRange int
+	// Cameras only merges media taken by one of them. Nil merges
+	// everything.
+	Cameras Cameras
+	// OtherCameras is the number of files left out by Cameras.
+	OtherCameras int
+	// Near only merges media taken within it. The zero Near merges
+	// everything.
+	Near Near
+	// FarAway is the number of files left out by Near.
+	FarAway int
+	// Manifest records the files merged when it is not nil. It must be the
+	// manifest of dst.
+	Manifest *Manifest
+	// Collision decides what happens to files whose name is taken in dst.
+	Collision Collision
+	// Duplicate decides what happens to files in src that dst has.
+	Duplicate Duplicate
+	// DuplicateDir is where DuplicateMove moves duplicates.
+	DuplicateDir string
+	// Naming decides how files are renamed by CollisionRename.
+	Naming Naming
+	// Catalog records the files merged when it is not nil. It must be the
+	// catalog of dst. Files it has anywhere in dst already are duplicates and
+	// it tells the method of dst without walking it.
+	Catalog *Catalog
+	// Collisions maps the files left in src by Collision to the file in
+	// dst that has their name.
+	Collisions map[string]string
+	// Replaced are the older files in dst CollisionNewer replaced.
+	Replaced []string
+	// Verify reads every file back after it is merged and compares its
+	// SHA-256 with the source. Files are moved by copying them and removing
+	// the source once it matches.
+	Verify  bool
 	action  Action
 	srcRoot string
 	dstRoot string
@@ -22,6 +65,20 @@ type Merger struct {
 	Removed []string
 }
 
+// InvalidDirError is returned by Merge when src or dst is not a sorted
+// directory or they are not sorted by the same method. Nothing has been
+// transferred when it is returned.
+type InvalidDirError struct {
+	Dir string
+	Err error
+}
+
+func (e *InvalidDirError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *InvalidDirError) Unwrap() error { return e.Err }
+
 func (m *Merger) storeMergeRemoved(path string) {
 	m.Removed = append(m.Removed, path)
 }
@@ -30,6 +87,10 @@ func (m *Merger) storeMergeError(path string, err error) {
 	m.Errors[path] = err.Error()
 }
 
+func (m *Merger) storeCollision(path string, taker string) {
+	m.Collisions[path] = taker
+}
+
 func (m *Merger) storeMerged(src string, dst string) {
 	m.Merged[dst] = src
 }
@@ -70,8 +131,35 @@ func mergeStrToMethod(str string) Method {
 		// Example: ^2020/2020_04/2020_04_27$
 		regexpPathDay = `^` + regexpYear + regexSep + regexpYear + "_" + regexpMonth +
 			regexSep + regexpYear + "_" + regexpMonth + "_" + regexpDay + `$`
+		// Example: ^2020/2020_04_27_0800-2020_04_29$
+		regexpDate      = regexpYear + "_" + regexpMonth + "_" + regexpDay
+		regexpStart     = `(_([01][0-9]|2[0-3])[0-5][0-9]([0-5][0-9])?)?`
+		regexpPathEvent = `^` + regexpYear + regexSep + regexpDate + regexpStart +
+			`(-` + regexpDate + `)?$`
+		// Example: ^2020/2020-W18$
+		regexpPathWeek = `^` + regexpYear + regexSep + regexpYear + `-W(0[1-9]|[1-4][0-9]|5[0-3])$`
+		// Example: ^2020/2020-Q2$
+		regexpPathQuarter = `^` + regexpYear + regexSep + regexpYear + `-Q[1-4]$`
+		// Example: ^2020-04$
+		regexpPathYearMonth = `^` + regexpYear + "-" + regexpMonth + `$`
 	)
 
+	if mergeMatch(regexpPathWeek, str) {
+		return MethodWeek
+	}
+
+	if mergeMatch(regexpPathQuarter, str) {
+		return MethodQuarter
+	}
+
+	if mergeMatch(regexpPathYearMonth, str) {
+		return MethodYearMonth
+	}
+
+	if mergeMatch(regexpPathEvent, str) {
+		return MethodEvent
+	}
+
 	if mergeMatch(regexpPathDay, str) {
 		return MethodDay
 	}
@@ -87,10 +175,64 @@ func mergeStrToMethod(str string) Method {
 	return MethodNone
 }
 
-func mergePathValid(root string, path string) Method {
+// mergeTrimPlace removes the place LayoutLocation adds to the last directory
+// of dir, as in 2020/2020_04 Lisbon.
+func mergeTrimPlace(dir string) string {
+	base := filepath.Base(dir)
+
+	space := strings.IndexByte(base, ' ')
+	if space < 0 {
+		return dir
+	}
+
+	return filepath.Join(filepath.Dir(dir), base[:space])
+}
+
+// mergeDirToLayout is mergeStrToMethod for directories that may end in the
+// device folder of LayoutDevice or the place of LayoutLocation. Either only
+// follows the last directory of a method, and neither may hold a date, so a
+// bad date or a folder holding sorted ones is not mistaken for one.
+func mergeDirToLayout(dir string) (Method, Layout) {
+	const regexpDateLike = `(^|[^0-9A-Za-z])\d{4}(_|-|$)`
+
+	trimmed := mergeTrimPlace(dir)
+
+	method := mergeStrToMethod(trimmed)
+
+	switch {
+	case method == MethodNone:
+	case trimmed == dir:
+		return method, LayoutDate
+	case mergeMatch(regexpDateLike, strings.SplitN(filepath.Base(dir), " ", 2)[1]):
+		return MethodNone, LayoutNone
+	default:
+		return method, LayoutLocation
+	}
+
+	device := filepath.Base(dir)
+	if device == dir || mergeMatch(regexpDateLike, device) {
+		return MethodNone, LayoutNone
+	}
+
+	method = mergeStrToMethod(filepath.Dir(dir))
+	if method == MethodNone {
+		return MethodNone, LayoutNone
+	}
+
+	return method, LayoutDevice
+}
+
+// mergeDirToMethod returns the method of dir whatever its layout.
+func mergeDirToMethod(dir string) Method {
+	method, _ := mergeDirToLayout(dir)
+	return method
+}
+
+// mergeRelDir returns the directory of path relative to root.
+func mergeRelDir(root string, path string) string {
 	dir := filepath.Dir(path)
 	if dir == "" {
-		return MethodNone
+		return ""
 	}
 
 	// We want the replace below to strip out the "/" for us
@@ -103,20 +245,148 @@ func mergePathValid(root string, path string) Method {
 	}
 
 	// get the time based paths.
-	path = strings.Replace(dir, root, "", 1)
+	return strings.Replace(dir, root, "", 1)
+}
+
+func mergePathValid(root string, path string) Method {
+	return mergeDirToMethod(mergeRelDir(root, path))
+}
+
+// mergePeriod parses the name of the last directory of a method into the
+// period of time it stands for.
+type mergePeriod func(name string) (time.Time, time.Time, error)
 
-	return mergeStrToMethod(path)
+func mergePeriodLayout(layout string, years int, months int, days int) mergePeriod {
+	return func(name string) (time.Time, time.Time, error) {
+		start, err := time.ParseInLocation(layout, name, time.Local)
+		return start, start.AddDate(years, months, days), err
+	}
+}
+
+// Events are named for their first day or first-last day. The first day may
+// end in the time the event starts.
+func mergePeriodEvent(name string) (time.Time, time.Time, error) {
+	const layout = "2006_01_02"
+
+	days := strings.SplitN(name, "-", 2)
+
+	first := days[0]
+	if len(first) > len(layout) {
+		first = first[:len(layout)]
+	}
+
+	start, err := time.ParseInLocation(layout, first, time.Local)
+	if err != nil {
+		return start, start, err
+	}
+
+	end, err := time.ParseInLocation(layout, days[len(days)-1], time.Local)
+
+	return start, end.AddDate(0, 0, 1), err
+}
+
+func mergePeriodWeek(name string) (time.Time, time.Time, error) {
+	var year, week int
+
+	_, err := fmt.Sscanf(name, "%04d-W%02d", &year, &week)
+	start := isoWeekStart(year, week)
+
+	return start, start.AddDate(0, 0, daysPerWeek), err
+}
+
+func mergePeriodQuarter(name string) (time.Time, time.Time, error) {
+	var year, quarter int
+
+	_, err := fmt.Sscanf(name, "%04d-Q%d", &year, &quarter)
+	start := quarterStart(year, quarter)
+
+	return start, start.AddDate(0, monthsPerQuarter, 0), err
+}
+
+// mergePathPeriod returns the period of time the sorted directory of path
+// stands for. It returns false if path is not in a sorted directory.
+func mergePathPeriod(root string, path string) (time.Time, time.Time, bool) {
+	var start time.Time
+
+	dir := mergeRelDir(root, path)
+
+	// How to parse the last directory of each method and how deep it is.
+	// A device folder may follow it.
+	periods := map[Method]struct {
+		period mergePeriod
+		depth  int
+	}{
+		MethodYear:      {mergePeriodLayout("2006", 1, 0, 0), 1},
+		MethodMonth:     {mergePeriodLayout("2006_01", 0, 1, 0), 2},
+		MethodDay:       {mergePeriodLayout("2006_01_02", 0, 0, 1), 3},
+		MethodEvent:     {mergePeriodEvent, 2},
+		MethodWeek:      {mergePeriodWeek, 2},
+		MethodQuarter:   {mergePeriodQuarter, 2},
+		MethodYearMonth: {mergePeriodLayout("2006-01", 0, 1, 0), 1},
+	}
+
+	method := mergeDirToMethod(dir)
+
+	period, present := periods[method]
+	if !present {
+		return start, start, false
+	}
+
+	dirs := strings.Split(dir, string(filepath.Separator))
+	name := strings.SplitN(dirs[period.depth-1], " ", 2)[0]
+
+	start, end, err := period.period(name)
+	if err != nil {
+		return start, start, false
+	}
+
+	return start, end, true
+}
+
+// mergeAddMethod returns the method and layout of root, rootMethod and
+// rootLayout so far, once it has the file path. Media without a device or
+// place is in the directories of LayoutDate, so those go with either.
+func mergeAddMethod(root string, path string, rootMethod Method,
+	rootLayout Layout) (Method, Layout, error) {
+	pathMethod, pathLayout := mergeDirToLayout(mergeRelDir(root, path))
+	if pathMethod == MethodNone {
+		return rootMethod, rootLayout, fmt.Errorf("path violates method structure %s", path)
+	}
+
+	if rootLayout == LayoutDate {
+		rootLayout = pathLayout
+	} else if pathLayout != LayoutDate && pathLayout != rootLayout {
+		// Device folders and places are never sorted together
+		return rootMethod, rootLayout, fmt.Errorf("%s has at least two layouts %s and %s",
+			root, rootLayout, pathLayout)
+	}
+
+	// Now we know the method of the file based on its path
+	// so we compare it to other ones we found.
+	switch {
+	case rootMethod == MethodNone:
+		// First time we found any method type for this directory
+		return pathMethod, rootLayout, nil
+	case rootMethod != pathMethod:
+		// Cannot have more than one method in a directory
+		return rootMethod, rootLayout, fmt.Errorf("%s has at least two methods %s and %s",
+			root, rootMethod, pathMethod)
+	default:
+		// We found method consistency, nothing to do.
+		return rootMethod, rootLayout, nil
+	}
 }
 
 // We are pretty strict on the directories we merge from and to here.
 // They must fulfill several requirements:
 // 1) No walk errors.
 // 2) Must contain at least one media file.
-// 3) Must follow the nested directory structure of:
-func mergeCheck(root string) (Method, error) {
+// 3) Must follow the nested directory structure of one method and layout.
+func mergeCheck(root string, exts Extensions, rules *Rules) (Method, error) {
 	rootMethod := MethodNone
+	rootLayout := LayoutDate
 
-	err := filepath.Walk(root,
+	err := rules.Walk(root,
 		func(path string, info os.FileInfo, err error) error {
 			if err != nil {
 				errStr := fmt.Sprintf("walk err on %s with %s",
@@ -129,31 +399,13 @@ func mergeCheck(root string) (Method, error) {
 				return nil
 			}
 
-			fileCategory := categorizeFile(path)
-			if fileCategory == categorySkip {
+			if exts.Category(path) == CategorySkip {
 				return nil
 			}
 
-			pathMethod := mergePathValid(root, path)
-			if pathMethod == MethodNone {
-				return fmt.Errorf("path violates method structure %s", path)
-			}
+			rootMethod, rootLayout, err = mergeAddMethod(root, path, rootMethod, rootLayout)
 
-			// Now we know the method of the file based on its path
-			// so we compare it to other ones we found.
-			switch {
-			case rootMethod == MethodNone:
-				// First time we found any method type for this directory
-				rootMethod = pathMethod
-			case rootMethod != pathMethod:
-				// Cannot have more than one method in a directory
-				return fmt.Errorf("%s has at least two methods %s and %s",
-					root, rootMethod, pathMethod)
-			case rootMethod == pathMethod:
-				// We found method consistency, nothing to do.
-			}
-
-			return nil
+			return err
 		})
 
 	if err != nil {
@@ -163,29 +415,61 @@ func mergeCheck(root string) (Method, error) {
 	return rootMethod, nil
 }
 
-func (m *Merger) mergeDuplicate(err error, action Action) error {
+func (m *Merger) mergeDuplicate(srcPath string, err error, action Action,
+	observer Observer) error {
 	// Is this error a duplicate file?
 	// If not a duplicate error just propagate it
 	var dupErr *duplicateError
 	if !errors.As(err, &dupErr) {
-		m.storeMergeError(dupErr.src, dupErr)
+		m.storeMergeError(srcPath, err)
 		return err
 	}
 
-	// If we are not moving files then we should just do nothing
-	if action != ActionMove {
-		return nil
+	// The duplicate policy decides if it stays in src.
+	removed, err := disposeDuplicate(dupErr.src, m.Duplicate, action, m.DuplicateDir)
+	if err != nil {
+		return err
 	}
 
-	// We have a duplicate so we have to remove it
-	// Hopefully there is no os problem with doing so.
-	m.storeMergeRemoved(dupErr.src)
+	if removed {
+		m.storeMergeRemoved(dupErr.src)
+		observer.DuplicateRemoved(dupErr.src)
+	}
+
+	return nil
+}
+
+// mergeTarget returns the path in dstDir to merge srcPath to by the collision
+// policy, or "" to leave it where it is. entries maps the names in dstDir to
+// their paths. It returns a duplicateError if dstDir has the same file.
+func (m *Merger) mergeTarget(srcPath string, dstDir string,
+	entries map[string]string) (string, error) {
+	taken := entries[filepath.Base(srcPath)]
 
-	return os.Remove(dupErr.src)
+	if taken == "" || m.Collision == CollisionRename {
+		// Find a new one based on ours
+		dstBase, err := uniqueName(srcPath, m.Naming, func(filename string) string {
+			return entries[filename]
+		})
+
+		return filepath.Join(dstDir, dstBase), err
+	}
+
+	replace, err := collide(m.Collision, srcPath, taken)
+
+	switch {
+	case err != nil:
+		return "", err
+	case replace:
+		return taken, nil
+	default:
+		m.storeCollision(srcPath, taken)
+		return "", nil
+	}
 }
 
 func (m *Merger) merge(srcPath string, srcRoot string, dstRoot string,
-	action Action, logger io.Writer) error {
+	action Action, observer Observer) error {
 	// Remove the root but this is not the basename just what is between
 	// root and base
 	filePath := strings.Replace(srcPath, srcRoot, "", 1)
@@ -193,6 +477,14 @@ func (m *Merger) merge(srcPath string, srcRoot string, dstRoot string,
 	// The directory we are going to put the file into
 	dstDir := filepath.Join(dstRoot, filepath.Dir(filePath))
 
+	if m.Catalog != nil {
+		archived, found, err := m.Catalog.Archived(srcPath)
+		if err != nil || found {
+			return m.mergeDuplicate(srcPath, duplicateOf(srcPath, archived, err),
+				action, observer)
+		}
+	}
+
 	dirEntries, err := ioutil.ReadDir(dstDir)
 
 	var dstPath string
@@ -224,44 +516,117 @@ func (m *Merger) merge(srcPath string, srcRoot string, dstRoot string,
 			entryMap[baseName] = fullPath
 		}
 
-		// Find a new one based on ours
-		dstBase, err := uniqueName(srcPath, func(filename string) string {
-			return entryMap[filename]
-		})
+		dstPath, err = m.mergeTarget(srcPath, dstDir, entryMap)
 
 		// We got an error it's either a duplicate or a real problem.
 		if err != nil {
-			return m.mergeDuplicate(err, action)
+			return m.mergeDuplicate(srcPath, err, action, observer)
 		}
 
-		dstPath = filepath.Join(dstDir, dstBase)
+		// Left where it is by the collision policy.
+		if dstPath == "" {
+			return nil
+		}
 	}
 
-	// Finally we have everything we need to move the media
-	switch action {
-	case ActionCopy:
-		err = copyFile(srcPath, dstPath)
-	case ActionMove:
-		err = moveFile(srcPath, dstPath)
-	default:
-		return fmt.Errorf("unknown Action %s", action)
+	// Finally we have everything we need to move the media. Only a newer
+	// file is merged to a name that is taken.
+	replace := exists(dstPath)
+	if replace {
+		err = replaceFile(srcPath, dstPath, action, m.Verify)
+	} else {
+		err = transferFile(srcPath, dstPath, action, m.Verify)
+	}
+
+	var mismatch *mismatchError
+	if errors.As(err, &mismatch) {
+		// The source is still there, we can go on.
+		m.storeMergeError(srcPath, err)
+		observer.Error(srcPath, err)
+
+		return nil
 	}
 
 	if err != nil {
 		return err
 	}
 
-	fmt.Fprintf(logger, "Merged %s to %s\n", srcPath, dstPath)
+	if replace {
+		m.Replaced = append(m.Replaced, dstPath)
+	}
+
+	observer.Merged(srcPath, dstPath)
 	m.storeMerged(srcPath, dstPath)
 
+	// The file is merged, we don't stop merging the rest for this.
+	err = m.record(srcPath, dstPath)
+	if err != nil {
+		m.storeMergeError(dstPath, err)
+		observer.Error(dstPath, err)
+	}
+
 	return nil
 }
 
-func (m *Merger) mergeRoots(logger io.Writer) error {
-	err := filepath.Walk(m.srcRoot,
+// record adds the file merged to dstPath to the manifest and catalog.
+func (m *Merger) record(srcPath string, dstPath string) error {
+	if m.Manifest != nil {
+		err := m.Manifest.Add(dstPath)
+		if err != nil {
+			return err
+		}
+	}
+
+	if m.Catalog == nil {
+		return nil
+	}
+
+	return m.Catalog.addScanned(dstPath, srcPath, extensionsOrDefault(m.Extensions))
+}
+
+func (m *Merger) inRange(srcFile string) bool {
+	if m.Range.IsZero() {
+		return true
+	}
+
+	start, end, ok := mergePathPeriod(m.srcRoot, srcFile)
+
+	return ok && m.Range.ContainsPeriod(start, end)
+}
+
+// selected reports if srcFile is taken by Cameras and Near, counting the
+// files that are not.
+func (m *Merger) selected(srcFile string, exts Extensions) bool {
+	if len(m.Cameras) == 0 && m.Near.IsZero() {
+		return true
+	}
+
+	var info MediaInfo
+	if exts.Category(srcFile) == CategoryExif {
+		info, _ = ExifInfoGet(srcFile)
+	}
+
+	if !m.Cameras.Match(info) {
+		m.OtherCameras++
+		return false
+	}
+
+	if !m.Near.Contains(info.GPS) {
+		m.FarAway++
+		return false
+	}
+
+	return true
+}
+
+func (m *Merger) mergeRoots(exts Extensions, rules *Rules,
+	observer Observer) error {
+	err := rules.Walk(m.srcRoot,
 		func(srcFile string, info os.FileInfo, err error) error {
 			if err != nil {
 				m.storeMergeError(srcFile, err)
+				observer.Error(srcFile, err)
+
 				return fmt.Errorf("walk on %s with %s", srcFile, err.Error())
 			}
 
@@ -270,8 +635,7 @@ func (m *Merger) mergeRoots(logger io.Writer) error {
 				return nil
 			}
 
-			fileCategory := categorizeFile(srcFile)
-			if fileCategory == categorySkip {
+			if exts.Category(srcFile) == CategorySkip {
 				return nil
 			}
 
@@ -279,9 +643,20 @@ func (m *Merger) mergeRoots(logger io.Writer) error {
 				return nil
 			}
 
-			err = m.merge(srcFile, m.srcRoot, m.dstRoot, m.action, logger)
+			if !m.inRange(srcFile) {
+				m.OutOfRange++
+				return nil
+			}
+
+			if !m.selected(srcFile, exts) {
+				return nil
+			}
+
+			err = m.merge(srcFile, m.srcRoot, m.dstRoot, m.action, observer)
 			if err != nil {
 				m.storeMergeError(srcFile, err)
+				observer.Error(srcFile, err)
+
 				return err
 			}
 
@@ -298,23 +673,46 @@ func (m *Merger) mergeRoots(logger io.Writer) error {
 // directories are made if necessary. If the move action was specified then
 // duplicate files are removd from src. If files have the same filename and end
 // up in the same dst directory they will be renamed to not collide.
-func (m *Merger) Merge(logger io.Writer) error {
-	srcMethod, err := mergeCheck(m.srcRoot)
+//
+// observer receives the events generated while merging.
+func (m *Merger) Merge(observer Observer) error {
+	exts := extensionsOrDefault(m.Extensions)
+	rules := rulesOrDefault(m.Rules)
+
+	if m.Collision >= CollisionNone || m.Duplicate >= DuplicateNone {
+		return fmt.Errorf("invalid policies %s and %s", m.Collision, m.Duplicate)
+	}
+
+	if m.Naming >= NamingNone {
+		return fmt.Errorf("invalid naming %s", m.Naming)
+	}
+
+	srcMethod, err := mergeCheck(m.srcRoot, exts, rules)
 	if err != nil {
-		return fmt.Errorf("src dir invalid: %s", err.Error())
+		return &InvalidDirError{m.srcRoot,
+			fmt.Errorf("src dir invalid: %w", err)}
+	}
+
+	// The catalog knows the files of dst without walking it.
+	var dstMethod Method
+	if m.Catalog != nil {
+		dstMethod, err = m.Catalog.Method()
+	} else {
+		dstMethod, err = mergeCheck(m.dstRoot, exts, rules)
 	}
 
-	dstMethod, err := mergeCheck(m.dstRoot)
 	if err != nil {
-		return fmt.Errorf("dst dir invalid: %s", err.Error())
+		return &InvalidDirError{m.dstRoot,
+			fmt.Errorf("dst dir invalid: %w", err)}
 	}
 
 	if srcMethod != dstMethod {
-		return fmt.Errorf("src %s sorted by %s, dst %s sorted by %s",
-			m.srcRoot, srcMethod, m.dstRoot, dstMethod)
+		return &InvalidDirError{m.dstRoot,
+			fmt.Errorf("src %s sorted by %s, dst %s sorted by %s",
+				m.srcRoot, srcMethod, m.dstRoot, dstMethod)}
 	}
 
-	return m.mergeRoots(logger)
+	return m.mergeRoots(exts, rules, observer)
 }
 
 // Reset will clear all previous state of a merge from the struct so it is ready
@@ -324,10 +722,15 @@ func (m *Merger) Merge(logger io.Writer) error {
 func (m *Merger) Reset(src string, dst string, action Action, filter string) {
 	m.Errors = make(map[string]string)
 	m.Merged = make(map[string]string)
+	m.Collisions = make(map[string]string)
+	m.Replaced = nil
 	m.srcRoot = src
 	m.dstRoot = dst
 	m.action = action
 	m.filter = filter
+	m.OutOfRange = 0
+	m.OtherCameras = 0
+	m.FarAway = 0
 }
 
 // NewMerger returns a Merger to execute a merge of two sorted directories.
@@ -5,7 +5,9 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestMergeCheckGood(t *testing.T) {
@@ -22,7 +24,7 @@ func TestMergeCheckGood(t *testing.T) {
 
 			dst := td.buildSortedDir(src, "dst", ActionCopy)
 
-			method, err := mergeCheck(dst)
+			method, err := mergeCheck(dst, NewExtensions(), NewRules())
 			if err != nil {
 				t.Errorf("Err %s, method %s\n", err.Error(), method)
 			}
@@ -56,7 +58,7 @@ func TestMergeCheckBad(t *testing.T) {
 
 			_ = ioutil.WriteFile(badFilePath, message, 0600)
 
-			method, err := mergeCheck(dst)
+			method, err := mergeCheck(dst, NewExtensions(), NewRules())
 			if err == nil {
 				t.Errorf("Unexpected Success method %s\n", method)
 			}
@@ -130,7 +132,7 @@ func testMerge(t *testing.T, method Method, action Action, dstFileNo int, dup bo
 	// merge them
 	m := NewMerger(fromDir, toDir, action, "")
 
-	err := m.Merge(ioutil.Discard)
+	err := m.Merge(NopObserver{})
 	if err != nil {
 		return err
 	}
@@ -163,7 +165,7 @@ func testMergeCollisions(t *testing.T, method Method, action Action) error {
 	// merge them
 	m := NewMerger(fromDir, toDir, action, "")
 
-	err := m.Merge(ioutil.Discard)
+	err := m.Merge(NopObserver{})
 	if err != nil {
 		return err
 	}
@@ -196,7 +198,7 @@ func testMergeTimeSpread(t *testing.T, method Method, action Action) error {
 	// merge them
 	m := NewMerger(fromDir, toDir, action, "")
 
-	err := m.Merge(ioutil.Discard)
+	err := m.Merge(NopObserver{})
 	if err != nil {
 		return err
 	}
@@ -231,7 +233,7 @@ func testMergeFilter(t *testing.T, method Method, action Action) error {
 
 	m := NewMerger(fromDir, toDir, action, regex)
 
-	err := m.Merge(ioutil.Discard)
+	err := m.Merge(NopObserver{})
 	if err != nil {
 		return err
 	}
@@ -372,10 +374,15 @@ func TestMergeMethodDiff(t *testing.T) {
 
 	m := NewMerger(fromDir, toDir, ActionCopy, "")
 
-	err := m.Merge(ioutil.Discard)
+	err := m.Merge(NopObserver{})
 	if err == nil {
 		t.Errorf("Succes is unexpected. src and dst have the different methods\n")
 	}
+
+	var dirErr *InvalidDirError
+	if !errors.As(err, &dirErr) {
+		t.Errorf("Expected InvalidDirError got %v\n", err)
+	}
 }
 
 // Here we create a situation where one sorted driectory by a method has other
@@ -400,7 +407,7 @@ func TestMergeMethodMultiple(t *testing.T) {
 
 	m := NewMerger(fromDir, toDir, ActionCopy, "")
 
-	err := m.Merge(ioutil.Discard)
+	err := m.Merge(NopObserver{})
 	if err == nil {
 		t.Errorf("Succes is unexpected. src and dst have the different methods\n")
 	}
@@ -435,3 +442,304 @@ func TestMergeFilter(t *testing.T) {
 		})
 	}
 }
+
+func TestMergePathPeriod(t *testing.T) {
+	root := filepath.Join("a", "sorted")
+
+	expected := map[string][2]time.Time{
+		filepath.Join(root, "2020", "x.jpg"): {testDate(2020, 1, 1),
+			testDate(2021, 1, 1)},
+		filepath.Join(root, "2020", "2020_04", "x.jpg"): {testDate(2020, 4, 1),
+			testDate(2020, 5, 1)},
+		filepath.Join(root, "2020", "2020_04", "2020_04_27", "x.jpg"): {
+			testDate(2020, 4, 27), testDate(2020, 4, 28)},
+		filepath.Join(root, "2020", "Canon EOS R6", "x.jpg"): {
+			testDate(2020, 1, 1), testDate(2021, 1, 1)},
+		filepath.Join(root, "2020", "2020_04", "Canon EOS R6", "x.jpg"): {
+			testDate(2020, 4, 1), testDate(2020, 5, 1)},
+		filepath.Join(root, "2020", "2020_04 Lisbon", "x.jpg"): {
+			testDate(2020, 4, 1), testDate(2020, 5, 1)},
+		filepath.Join(root, "2020 Santa Cruz de Tenerife", "x.jpg"): {
+			testDate(2020, 1, 1), testDate(2021, 1, 1)},
+		filepath.Join(root, "2020", "2020_04_27", "x.jpg"): {
+			testDate(2020, 4, 27), testDate(2020, 4, 28)},
+		filepath.Join(root, "2020", "2020_04_27-2020_04_29 Lisbon", "x.jpg"): {
+			testDate(2020, 4, 27), testDate(2020, 4, 30)},
+		filepath.Join(root, "2020", "2020_04_27_2000-2020_04_28", "x.jpg"): {
+			testDate(2020, 4, 27), testDate(2020, 4, 29)},
+		filepath.Join(root, "2020", "2020-W18", "x.jpg"): {
+			testDate(2020, 4, 27), testDate(2020, 5, 4)},
+		filepath.Join(root, "2020", "2020-Q2 Lisbon", "x.jpg"): {
+			testDate(2020, 4, 1), testDate(2020, 7, 1)},
+		filepath.Join(root, "2020-04", "Apple", "x.jpg"): {
+			testDate(2020, 4, 1), testDate(2020, 5, 1)},
+	}
+
+	for path, period := range expected {
+		start, end, ok := mergePathPeriod(root, path)
+		if !ok || !start.Equal(period[0]) || !end.Equal(period[1]) {
+			t.Errorf("%s: expected %v got %s %s\n", path, period, start, end)
+		}
+	}
+
+	_, _, ok := mergePathPeriod(root, filepath.Join(root, "misc", "x.jpg"))
+	if ok {
+		t.Errorf("Expected no period outside a sorted directory\n")
+	}
+}
+
+func TestMergeDateRange(t *testing.T) {
+	for _, method := range []Method{MethodYear, MethodMonth, MethodDay} {
+		method := method
+		t.Run(method.String(), func(t *testing.T) {
+			t.Parallel()
+			tdSrc := newTestDir(t, method, fileNoDefault)
+			tdDst := newTestDir(t, method, fileNoDefault)
+
+			src := tdSrc.buildTimeSpreadRoot()
+			dst := tdDst.buildRoot()
+			fromDir := tdSrc.buildSortedDir(src, "fromDir_", ActionCopy)
+			toDir := tdDst.buildSortedDir(dst, "toDir_", ActionCopy)
+
+			defer os.RemoveAll(fromDir)
+			defer os.RemoveAll(toDir)
+			defer os.RemoveAll(dst)
+			defer os.RemoveAll(src)
+
+			m := NewMerger(fromDir, toDir, ActionMove, "")
+			m.Range = tdSrc.testTimeSpreadRange()
+
+			err := m.Merge(NopObserver{})
+			if err != nil {
+				t.Fatalf("Unexpected error %s\n", err.Error())
+			}
+
+			// Only the nested directory is in range.
+			const inRange = 25
+
+			if len(m.Merged) != inRange || m.OutOfRange != tdSrc.numData-inRange {
+				t.Errorf("Expected %d merged %d out of range got %d %d\n",
+					inRange, tdSrc.numData-inRange, len(m.Merged), m.OutOfRange)
+			}
+
+			err = countFiles(t, fromDir, tdSrc.numData-inRange, "Src Dir")
+			if err != nil {
+				t.Errorf("%s\n", err.Error())
+			}
+		})
+	}
+}
+
+func testSortedLayoutDir(t *testing.T, td *testdir, src string, layout Layout) string {
+	scanner := NewScanner()
+	_ = scanner.ScanDir(src, NopObserver{})
+
+	dst, _ := ioutil.TempDir("", "fromDir_")
+
+	sorter, err := NewSorter(scanner, td.method, WithLayout(layout))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = sorter.Transfer(dst, ActionCopy, NopObserver{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return dst
+}
+
+func TestMergeCheckLayouts(t *testing.T) {
+	// Media without a device or place goes with either, but not both.
+	expected := map[string]bool{
+		"date":     true,
+		"device":   true,
+		"location": true,
+		"both":     false,
+		"home":     false,
+	}
+
+	trees := map[string][]string{
+		"date":     {"2020/a.jpg", "2021/b.jpg"},
+		"device":   {"2020/a.jpg", "2020/Apple iPhone 11 Pro/b.jpg"},
+		"location": {"2020/a.jpg", "2020 Lisbon/b.jpg"},
+		"both":     {"2020/Apple iPhone 11 Pro/a.jpg", "2020 Lisbon/b.jpg"},
+		"home":     {"2020/a.jpg", "2020/Documents/Work/b.jpg"},
+	}
+
+	for name, tree := range trees {
+		root, _ := ioutil.TempDir("", "layouts_")
+		defer os.RemoveAll(root)
+
+		for _, path := range tree {
+			path = filepath.Join(root, filepath.FromSlash(path))
+			_ = os.MkdirAll(filepath.Dir(path), 0755)
+
+			err := copyFile(exifPath, path)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		method, err := mergeCheck(root, NewExtensions(), NewRules())
+		if (err == nil) != expected[name] {
+			t.Errorf("%s: expected valid %t got %s %v\n", name, expected[name],
+				method, err)
+		}
+	}
+}
+
+func TestMergeDeviceLayout(t *testing.T) {
+	for _, method := range []Method{MethodYear, MethodMonth, MethodDay} {
+		method := method
+		t.Run(method.String(), func(t *testing.T) {
+			t.Parallel()
+			tdSrc := newTestDir(t, method, fileNoDefault)
+			tdDst := newTestDir(t, method, fileNoDefault)
+
+			src := tdSrc.buildRoot()
+			dst := tdDst.buildRoot()
+			fromDir := testSortedLayoutDir(t, tdSrc, src, LayoutDevice)
+			toDir := tdDst.buildSortedDir(dst, "toDir_", ActionCopy)
+
+			defer os.RemoveAll(fromDir)
+			defer os.RemoveAll(toDir)
+			defer os.RemoveAll(dst)
+			defer os.RemoveAll(src)
+
+			m := NewMerger(fromDir, toDir, ActionCopy, "")
+			m.Cameras = Cameras{"iphone"}
+
+			err := m.Merge(NopObserver{})
+			if err != nil {
+				t.Fatalf("Unexpected error %s\n", err.Error())
+			}
+
+			numPhone := tdSrc.numData - tdSrc.numExifError
+
+			if len(m.Merged) != numPhone || m.OtherCameras != tdSrc.numExifError {
+				t.Errorf("Expected %d merged %d other cameras got %d %d\n",
+					numPhone, tdSrc.numExifError, len(m.Merged), m.OtherCameras)
+			}
+
+			for dstPath := range m.Merged {
+				if filepath.Base(filepath.Dir(dstPath)) != "Apple iPhone 11 Pro" {
+					t.Errorf("Expected %s in its device folder\n", dstPath)
+				}
+			}
+		})
+	}
+}
+
+func TestMergeLocationLayout(t *testing.T) {
+	for _, method := range []Method{MethodYear, MethodMonth, MethodDay} {
+		method := method
+		t.Run(method.String(), func(t *testing.T) {
+			t.Parallel()
+			tdSrc := newTestDir(t, method, fileNoDefault)
+			tdDst := newTestDir(t, method, fileNoDefault)
+
+			src := tdSrc.buildRoot()
+			dst := tdDst.buildRoot()
+			fromDir := testSortedLayoutDir(t, tdSrc, src, LayoutLocation)
+			toDir := tdDst.buildSortedDir(dst, "toDir_", ActionCopy)
+
+			defer os.RemoveAll(fromDir)
+			defer os.RemoveAll(toDir)
+			defer os.RemoveAll(dst)
+			defer os.RemoveAll(src)
+
+			m := NewMerger(fromDir, toDir, ActionCopy, "")
+			m.Near = Near{Coordinates{Latitude: 37.26, Longitude: -122.02}, 10}
+
+			err := m.Merge(NopObserver{})
+			if err != nil {
+				t.Fatalf("Unexpected error %s\n", err.Error())
+			}
+
+			numNear := tdSrc.numData - tdSrc.numExifError
+
+			if len(m.Merged) != numNear || m.FarAway != tdSrc.numExifError {
+				t.Errorf("Expected %d merged %d far away got %d %d\n",
+					numNear, tdSrc.numExifError, len(m.Merged), m.FarAway)
+			}
+
+			for dstPath := range m.Merged {
+				if !strings.HasSuffix(filepath.Dir(dstPath), " Saratoga") {
+					t.Errorf("Expected %s in its place directory\n", dstPath)
+				}
+			}
+		})
+	}
+}
+
+// testPolicyDirs returns two directories sorted by year. Both have 2020/x.jpg,
+// the one of src newer, and the same 2020/y.jpg.
+func testPolicyDirs(t *testing.T) (string, string) {
+	src, _ := ioutil.TempDir("", "policy_src_")
+	dst, _ := ioutil.TempDir("", "policy_dst_")
+
+	files := map[string]string{
+		filepath.Join(src, "2020", "x.jpg"): "../data/diff_exif.jpg",
+		filepath.Join(src, "2020", "y.jpg"): noExifPath,
+		filepath.Join(dst, "2020", "x.jpg"): exifPath,
+		filepath.Join(dst, "2020", "y.jpg"): noExifPath,
+	}
+
+	for path, content := range files {
+		_ = os.MkdirAll(filepath.Dir(path), 0755)
+
+		err := copyFile(content, path)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	past := time.Now().Add(-time.Hour)
+	_ = os.Chtimes(filepath.Join(dst, "2020", "x.jpg"), past, past)
+
+	return src, dst
+}
+
+func TestMergePolicies(t *testing.T) {
+	t.Parallel()
+
+	for _, collision := range []Collision{CollisionSkip, CollisionNewer, CollisionFail} {
+		src, dst := testPolicyDirs(t)
+		defer os.RemoveAll(src)
+		defer os.RemoveAll(dst)
+
+		srcX, dstX := filepath.Join(src, "2020", "x.jpg"), filepath.Join(dst, "2020", "x.jpg")
+		srcY := filepath.Join(src, "2020", "y.jpg")
+
+		m := NewMerger(src, dst, ActionCopy, "")
+		m.Collision = collision
+		m.Duplicate = DuplicateDelete
+
+		err := m.Merge(NopObserver{})
+
+		equal, _ := isEqual(srcX, dstX)
+
+		switch collision {
+		case CollisionSkip:
+			if err != nil || equal || m.Collisions[srcX] != dstX {
+				t.Errorf("Expected %s skipped got %v err %v\n", srcX, m.Collisions, err)
+			}
+		case CollisionNewer:
+			if err != nil || !equal || len(m.Replaced) != 1 {
+				t.Errorf("Expected %s replaced got %v err %v\n", dstX, m.Replaced, err)
+			}
+		case CollisionFail:
+			if err == nil {
+				t.Errorf("Expected error for a collision\n")
+			}
+
+			continue
+		}
+
+		// Deleted even when copying.
+		if exists(srcY) || len(m.Removed) != 1 {
+			t.Errorf("Expected %s deleted got %v\n", srcY, m.Removed)
+		}
+	}
+}
@@ -23,6 +23,19 @@ func TestWinMergePathGood(t *testing.T) {
 		`gobo\2010\2010_02\2010_02_30\m.jpg`: MethodDay,
 		`gobo\2010\2010_02\2010_02_31\m.jpg`: MethodDay,
 		`gobo\2020\2020_04\2020_04_28\m.jpg`: MethodDay,
+
+		`gobo\2020\2020_04_28\m.jpg`:                   MethodEvent,
+		`gobo\2020\2020_04_28-2020_05_02\m.jpg`:        MethodEvent,
+		`gobo\2020\2020_04_28-2020_05_02 Lisbon\m.jpg`: MethodEvent,
+		`gobo\2020\2020_04_28-2020_05_02\Apple\m.jpg`:  MethodEvent,
+
+		`gobo\2020\2020-W01\m.jpg`:       MethodWeek,
+		`gobo\2020\2020-W53\m.jpg`:       MethodWeek,
+		`gobo\2020\2020-Q2\m.jpg`:        MethodQuarter,
+		`gobo\2020\2020-Q4 Lisbon\m.jpg`: MethodQuarter,
+		`gobo\2020-04\m.jpg`:             MethodYearMonth,
+		`gobo\2020-12\Apple\m.jpg`:       MethodYearMonth,
+		`gobo\2020\Nikon D3100\m.jpg`:    MethodYear,
 	}
 
 	root := `gobo`
@@ -70,6 +83,18 @@ func TestWinMergePathBad(t *testing.T) {
 		`gobo\2010\2010_02\2010_02_gobo\m.jpg`,
 		`gobo\2010\2010_02\2010_02_00\m.jpg`,
 		`gobo\2010\2010_02\2010_02_32\m.jpg`,
+		`gobo\2010\2010_02_32\m.jpg`,
+		`gobo\2010\2010_02_01-\m.jpg`,
+		`gobo\2010\2010_02_01-2010_02_32\m.jpg`,
+		`gobo\2020\2020-W00\m.jpg`,
+		`gobo\2020\2020-W54\m.jpg`,
+		`gobo\2020\2020-Q5\m.jpg`,
+		`gobo\2020-13\m.jpg`,
+		`gobo\2020-04\2020-04\m.jpg`,
+		`gobo\2020\Trip 2020_04\m.jpg`,
+		`gobo\2020\2020_04 Trip 2020_04\m.jpg`,
+		`gobo\2020\Documents\Work\m.jpg`,
+		`gobo\Documents\2020\m.jpg`,
 	}
 
 	for _, input := range badInput {
@@ -0,0 +1,139 @@
+package exifsort
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// QuickTime, MP4 and 3GP files are a tree of atoms. Each atom starts with a
+// 32 bit size and a four letter type. The creation time lives in the "mvhd"
+// atom inside the top level "moov" atom.
+
+const (
+	atomSizeLen        = 4
+	atomHeaderLen      = 8
+	atomLargeLen       = 16
+	atomSizeLarge      = 1 // The 64 bit size follows the type
+	atomSizeToEOF      = 0 // The atom runs until the end of the file
+	mvhdVersionLarge   = 1 // Times are 64 bit
+	mvhdTimeOffset     = 4 // Skip version and flags
+	mvhdTimeLen        = 8
+	secsFrom1904To1970 = 2082844800
+)
+
+type atom struct {
+	kind   string
+	offset int64 // Start of the atom body
+	size   int64 // Size of the atom body
+}
+
+func atomRead(r io.ReaderAt, offset int64, end int64) (atom, error) {
+	var header [atomLargeLen]byte
+
+	_, err := r.ReadAt(header[:atomHeaderLen], offset)
+	if err != nil {
+		return atom{}, err
+	}
+
+	size := int64(binary.BigEndian.Uint32(header[:atomSizeLen]))
+	a := atom{kind: string(header[atomSizeLen:atomHeaderLen]), offset: offset + atomHeaderLen}
+
+	switch size {
+	case atomSizeLarge:
+		_, err = r.ReadAt(header[atomHeaderLen:], offset+atomHeaderLen)
+		if err != nil {
+			return atom{}, err
+		}
+
+		size = int64(binary.BigEndian.Uint64(header[atomHeaderLen:]))
+		a.offset = offset + atomLargeLen
+	case atomSizeToEOF:
+		size = end - offset
+	}
+
+	a.size = size - (a.offset - offset)
+	if a.size < 0 || a.offset+a.size > end {
+		return atom{}, fmt.Errorf("bad atom %q at %d", a.kind, offset)
+	}
+
+	return a, nil
+}
+
+// atomFind returns the first atom of kind between offset and end.
+func atomFind(r io.ReaderAt, kind string, offset int64, end int64) (atom, error) {
+	for offset+atomHeaderLen <= end {
+		a, err := atomRead(r, offset, end)
+		if err != nil {
+			return atom{}, err
+		}
+
+		if a.kind == kind {
+			return a, nil
+		}
+
+		offset = a.offset + a.size
+	}
+
+	return atom{}, fmt.Errorf("no %s atom", kind)
+}
+
+func movieTimeRead(r io.ReaderAt, size int64) (time.Time, error) {
+	var t time.Time
+
+	moov, err := atomFind(r, "moov", 0, size)
+	if err != nil {
+		return t, err
+	}
+
+	mvhd, err := atomFind(r, "mvhd", moov.offset, moov.offset+moov.size)
+	if err != nil {
+		return t, err
+	}
+
+	var buf [mvhdTimeOffset + mvhdTimeLen]byte
+
+	_, err = r.ReadAt(buf[:], mvhd.offset)
+	if err != nil {
+		return t, err
+	}
+
+	var secs uint64
+	if buf[0] == mvhdVersionLarge {
+		secs = binary.BigEndian.Uint64(buf[mvhdTimeOffset:])
+	} else {
+		secs = uint64(binary.BigEndian.Uint32(buf[mvhdTimeOffset:]))
+	}
+
+	// Cameras that don't know the time leave it as zero.
+	if secs <= secsFrom1904To1970 {
+		return t, errors.New("no movie creation time")
+	}
+
+	// Movie times are UTC. We show them local like exif times.
+	t = time.Unix(int64(secs-secsFrom1904To1970), 0).In(time.Local)
+
+	return t, nil
+}
+
+// MovieTimeGet returns the creation time stored in the metadata of a
+// QuickTime, MP4 or 3GP movie.
+func MovieTimeGet(path string) (time.Time, error) {
+	var t time.Time
+
+	file, err := os.Open(path)
+	if err != nil {
+		return t, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return t, err
+	}
+
+	return movieTimeRead(file, info.Size())
+}
@@ -0,0 +1,106 @@
+package exifsort
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func testAtom(kind string, body []byte) []byte {
+	var buf bytes.Buffer
+
+	_ = binary.Write(&buf, binary.BigEndian, uint32(len(body)+atomHeaderLen))
+	buf.WriteString(kind)
+	buf.Write(body)
+
+	return buf.Bytes()
+}
+
+func testMovie(created time.Time) []byte {
+	var mvhd bytes.Buffer
+
+	mvhd.Write([]byte{0, 0, 0, 0}) // version and flags
+	secs := uint32(created.Unix() + secsFrom1904To1970)
+	_ = binary.Write(&mvhd, binary.BigEndian, secs)
+	_ = binary.Write(&mvhd, binary.BigEndian, secs) // modification time
+
+	movie := testAtom("ftyp", []byte("qt  "))
+	movie = append(movie, testAtom("free", nil)...)
+	movie = append(movie, testAtom("moov", testAtom("mvhd", mvhd.Bytes()))...)
+
+	return movie
+}
+
+func TestMovieTimeGet(t *testing.T) {
+	tmpPath, err := ioutil.TempDir("", "MovieTime")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpPath)
+
+	created := time.Date(2019, 6, 1, 12, 30, 45, 0, time.UTC)
+	moviePath := filepath.Join(tmpPath, "MVI_1.MOV")
+
+	err = ioutil.WriteFile(moviePath, testMovie(created), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	movieTime, err := MovieTimeGet(moviePath)
+	if err != nil {
+		t.Fatalf("Unexpected error %s\n", err.Error())
+	}
+
+	if !movieTime.Equal(created) {
+		t.Errorf("Expected %s got %s\n", created, movieTime)
+	}
+
+	s := NewScanner()
+	_ = s.ScanDir(tmpPath, NopObserver{})
+
+	if !s.Data[moviePath].Equal(created) || len(s.ExifErrors) != 0 {
+		t.Errorf("Expected scan to use movie time %s got %s\n",
+			created, s.Data[moviePath])
+	}
+}
+
+func TestMovieTimeBad(t *testing.T) {
+	tmpPath, err := ioutil.TempDir("", "MovieTimeBad")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpPath)
+
+	movies := map[string][]byte{
+		"zero.mov":      testMovie(time.Unix(-secsFrom1904To1970, 0)),
+		"truncated.mov": testMovie(time.Now())[:20],
+		"nomoov.mov":    testAtom("ftyp", []byte("qt  ")),
+	}
+
+	for name, content := range movies {
+		moviePath := filepath.Join(tmpPath, name)
+
+		err = ioutil.WriteFile(moviePath, content, 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = MovieTimeGet(moviePath)
+		if err == nil {
+			t.Errorf("Expected error for %s\n", name)
+		}
+	}
+
+	// The scanner falls back to modtime.
+	s := NewScanner()
+	_ = s.ScanDir(tmpPath, NopObserver{})
+
+	if len(s.Data) != len(movies) || len(s.ExifErrors) != len(movies) {
+		t.Errorf("Expected %d data and exif errors got %d and %d\n",
+			len(movies), len(s.Data), len(s.ExifErrors))
+	}
+}
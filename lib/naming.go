@@ -0,0 +1,62 @@
+package exifsort
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// Naming decides how media is renamed when its name is taken by another file.
+type Naming int
+
+const (
+	// NamingCounter : <name>_N.<ext>, N counting up from 0
+	NamingCounter Naming = iota
+	// NamingHash : <name>_<hash>.<ext>, hash the start of the SHA-256 of the
+	// contents, so the name is the same whatever else is sorted
+	NamingHash
+	// NamingNone : Error Value
+	NamingNone
+)
+
+// hashNameLen is how many hex digits of the SHA-256 NamingHash adds.
+const hashNameLen = 8
+
+// Returns name of naming value (all lower case).
+func (n Naming) String() string {
+	return [...]string{"counter", "hash", "none"}[n]
+}
+
+// Namings returns all naming values used excluding NamingNone.
+func Namings() []Naming {
+	return []Naming{
+		NamingCounter,
+		NamingHash,
+	}
+}
+
+// NamingParse returns Naming from string (must be lower case). Returns
+// NamingNone if invalid.
+func NamingParse(str string) (Naming, error) {
+	for _, val := range Namings() {
+		if str == val.String() {
+			return val, nil
+		}
+	}
+
+	return NamingNone, fmt.Errorf("invalid naming %s", str)
+}
+
+// hashName returns the NamingHash name of path.
+func hashName(path string) (string, error) {
+	entry, err := hashFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	filename := filepath.Base(path)
+	extension := filepath.Ext(filename)
+	prefix := strings.TrimSuffix(filename, extension)
+
+	return fmt.Sprintf("%s_%s%s", prefix, entry.SHA256[:hashNameLen], extension), nil
+}
@@ -0,0 +1,141 @@
+package exifsort
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+// Observer receives progress events from Scanner, Sorter and Merger as they
+// work. Methods are never called concurrently so implementations need no
+// locking, but they should return quickly as the work waits on them.
+type Observer interface {
+	// FileScanned is called when a media file's time has been determined.
+	FileScanned(path string, time time.Time)
+	// ExifError is called when exif data could not be read from a file
+	// and the scanner falls back to its modtime.
+	ExifError(path string, err error)
+	// Skipped is called for files that are not processed.
+	Skipped(path string)
+	// Transferred is called when a file is copied or moved by a Sorter.
+	Transferred(src string, dst string)
+	// DuplicateRemoved is called when a duplicate file is removed.
+	DuplicateRemoved(path string)
+	// Merged is called when a file is copied or moved by a Merger.
+	Merged(src string, dst string)
+	// Error is called when an operation on a file fails.
+	Error(path string, err error)
+}
+
+// NopObserver ignores every event. Embed it to implement only the events you
+// care about.
+type NopObserver struct{}
+
+// FileScanned does nothing.
+func (NopObserver) FileScanned(path string, time time.Time) {}
+
+// ExifError does nothing.
+func (NopObserver) ExifError(path string, err error) {}
+
+// Skipped does nothing.
+func (NopObserver) Skipped(path string) {}
+
+// Transferred does nothing.
+func (NopObserver) Transferred(src string, dst string) {}
+
+// DuplicateRemoved does nothing.
+func (NopObserver) DuplicateRemoved(path string) {}
+
+// Merged does nothing.
+func (NopObserver) Merged(src string, dst string) {}
+
+// Error does nothing.
+func (NopObserver) Error(path string, err error) {}
+
+// LogObserver writes one line of text per event to a writer.
+type LogObserver struct {
+	NopObserver
+	w io.Writer
+}
+
+// NewLogObserver returns an Observer that logs events as text to w.
+func NewLogObserver(w io.Writer) *LogObserver {
+	return &LogObserver{w: w}
+}
+
+// FileScanned logs the path and its time.
+func (l *LogObserver) FileScanned(path string, time time.Time) {
+	fmt.Fprintf(l.w, "%s, %s\n", path, exifTimeToStr(time))
+}
+
+// Transferred logs the destination of the transfer.
+func (l *LogObserver) Transferred(src string, dst string) {
+	fmt.Fprintf(l.w, "Transferred %s\n", dst)
+}
+
+// DuplicateRemoved logs the removed path.
+func (l *LogObserver) DuplicateRemoved(path string) {
+	fmt.Fprintf(l.w, "Removed duplicate %s\n", path)
+}
+
+// Merged logs the source and destination of the merge.
+func (l *LogObserver) Merged(src string, dst string) {
+	fmt.Fprintf(l.w, "Merged %s to %s\n", src, dst)
+}
+
+// Error logs the path and the error.
+func (l *LogObserver) Error(path string, err error) {
+	fmt.Fprintf(l.w, "Error: %s: (%s)\n", path, err.Error())
+}
+
+// MultiObserver sends every event to each of its observers in order.
+type MultiObserver []Observer
+
+// FileScanned notifies every observer.
+func (m MultiObserver) FileScanned(path string, time time.Time) {
+	for _, o := range m {
+		o.FileScanned(path, time)
+	}
+}
+
+// ExifError notifies every observer.
+func (m MultiObserver) ExifError(path string, err error) {
+	for _, o := range m {
+		o.ExifError(path, err)
+	}
+}
+
+// Skipped notifies every observer.
+func (m MultiObserver) Skipped(path string) {
+	for _, o := range m {
+		o.Skipped(path)
+	}
+}
+
+// Transferred notifies every observer.
+func (m MultiObserver) Transferred(src string, dst string) {
+	for _, o := range m {
+		o.Transferred(src, dst)
+	}
+}
+
+// DuplicateRemoved notifies every observer.
+func (m MultiObserver) DuplicateRemoved(path string) {
+	for _, o := range m {
+		o.DuplicateRemoved(path)
+	}
+}
+
+// Merged notifies every observer.
+func (m MultiObserver) Merged(src string, dst string) {
+	for _, o := range m {
+		o.Merged(src, dst)
+	}
+}
+
+// Error notifies every observer.
+func (m MultiObserver) Error(path string, err error) {
+	for _, o := range m {
+		o.Error(path, err)
+	}
+}
@@ -0,0 +1,154 @@
+package exifsort
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+type countObserver struct {
+	NopObserver
+	scanned     int
+	exifErrors  int
+	skipped     int
+	transferred int
+	merged      int
+	errors      int
+}
+
+func (c *countObserver) FileScanned(path string, time time.Time) { c.scanned++ }
+func (c *countObserver) ExifError(path string, err error)        { c.exifErrors++ }
+func (c *countObserver) Skipped(path string)                     { c.skipped++ }
+func (c *countObserver) Transferred(src string, dst string)      { c.transferred++ }
+func (c *countObserver) Merged(src string, dst string)           { c.merged++ }
+func (c *countObserver) Error(path string, err error)            { c.errors++ }
+
+func TestObserverScanSort(t *testing.T) {
+	t.Parallel()
+	td := newTestDir(t, MethodMonth, fileNoDefault)
+
+	src := td.buildRoot()
+	defer os.RemoveAll(src)
+
+	td.populateSkipFiles(src, 3)
+
+	var c countObserver
+
+	s := NewScanner()
+	_ = s.ScanDir(src, &c)
+
+	if c.scanned != td.numData {
+		t.Errorf("Expected %d scanned events got %d\n", td.numData, c.scanned)
+	}
+
+	if c.exifErrors != td.numExifError {
+		t.Errorf("Expected %d exif error events got %d\n", td.numExifError, c.exifErrors)
+	}
+
+	if c.skipped != td.numSkipped {
+		t.Errorf("Expected %d skipped events got %d\n", td.numSkipped, c.skipped)
+	}
+
+	dst, _ := ioutil.TempDir("", "observer_dst_")
+	defer os.RemoveAll(dst)
+
+	sorter, _ := NewSorter(s, MethodMonth)
+
+	err := sorter.Transfer(dst, ActionCopy, &c)
+	if err != nil {
+		t.Fatalf("Unexpected error %s\n", err.Error())
+	}
+
+	if c.transferred != td.numData {
+		t.Errorf("Expected %d transferred events got %d\n", td.numData, c.transferred)
+	}
+
+	if c.errors != 0 {
+		t.Errorf("Expected no error events got %d\n", c.errors)
+	}
+}
+
+func TestObserverMerge(t *testing.T) {
+	t.Parallel()
+	tdSrc := newTestDir(t, MethodDay, fileNoDefault)
+	tdDst := newTestDir(t, MethodDay, 10000)
+
+	src := tdSrc.buildRoot()
+	defer os.RemoveAll(src)
+
+	dst := tdDst.buildRoot()
+	defer os.RemoveAll(dst)
+
+	fromDir := tdSrc.buildSortedDir(src, "fromDir_", ActionCopy)
+	defer os.RemoveAll(fromDir)
+
+	toDir := tdDst.buildSortedDir(dst, "toDir_", ActionCopy)
+	defer os.RemoveAll(toDir)
+
+	var c countObserver
+
+	m := NewMerger(fromDir, toDir, ActionCopy, "")
+
+	err := m.Merge(&c)
+	if err != nil {
+		t.Fatalf("Unexpected error %s\n", err.Error())
+	}
+
+	if c.merged != tdSrc.numData {
+		t.Errorf("Expected %d merged events got %d\n", tdSrc.numData, c.merged)
+	}
+}
+
+func TestObserverLog(t *testing.T) {
+	var buf bytes.Buffer
+
+	l := NewLogObserver(&buf)
+	exifTime, _ := extractTimeFromStr(exifTimeStr)
+
+	l.FileScanned("a.jpg", exifTime)
+	l.ExifError("b.jpg", errors.New("no exif"))
+	l.Skipped("c.txt")
+	l.Transferred("a.jpg", "dst/a.jpg")
+	l.DuplicateRemoved("d.jpg")
+	l.Merged("e.jpg", "dst/e.jpg")
+	l.Error("f.jpg", errors.New("gobo"))
+
+	expected := []string{
+		"a.jpg, 2020:04:28 14:12:21",
+		"Transferred dst/a.jpg",
+		"Removed duplicate d.jpg",
+		"Merged e.jpg to dst/e.jpg",
+		"Error: f.jpg: (gobo)",
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != len(expected) {
+		t.Fatalf("Expected %d lines got %d: %q\n", len(expected), len(lines), lines)
+	}
+
+	for ii, line := range lines {
+		if line != expected[ii] {
+			t.Errorf("Expected %q got %q\n", expected[ii], line)
+		}
+	}
+}
+
+func TestObserverMulti(t *testing.T) {
+	var lhs, rhs countObserver
+
+	m := MultiObserver{&lhs, &rhs}
+
+	m.FileScanned("a.jpg", time.Now())
+	m.Skipped("b.txt")
+	m.Error("c.jpg", errors.New("gobo"))
+
+	for _, c := range []*countObserver{&lhs, &rhs} {
+		if c.scanned != 1 || c.skipped != 1 || c.errors != 1 {
+			t.Errorf("Expected every observer to see every event: %+v\n", *c)
+		}
+	}
+}
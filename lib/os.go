@@ -1,9 +1,11 @@
 package exifsort
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
-	"io/ioutil"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
@@ -25,11 +27,43 @@ func (e *duplicateError) Error() string {
 
 func (e *duplicateError) Unwrap() error { return e.Err }
 
+// duplicateOf returns a duplicateError of src and dst, or err if there is one.
+func duplicateOf(src string, dst string, err error) error {
+	if err != nil {
+		return err
+	}
+
+	return &duplicateError{src: src, dst: dst}
+}
+
+// A transferred file that does not read back the same as its source. The
+// source is kept.
+type mismatchError struct {
+	src string
+	dst string
+}
+
+func (e *mismatchError) Error() string {
+	return fmt.Sprintf("%s does not match %s after transfer", e.dst, e.src)
+}
+
 func exists(filename string) bool {
 	_, err := os.Stat(filename)
 	return !os.IsNotExist(err)
 }
 
+// sameFile reports if a and b are both the same existing file.
+func sameFile(a string, b string) bool {
+	aInfo, err := os.Stat(a)
+	if err != nil {
+		return false
+	}
+
+	bInfo, err := os.Stat(b)
+
+	return err == nil && os.SameFile(aInfo, bInfo)
+}
+
 func moveFile(src string, dst string) error {
 	if exists(dst) {
 		errStr := fmt.Sprintf("Cannot clobber %s with %s\n", dst, src)
@@ -39,22 +73,158 @@ func moveFile(src string, dst string) error {
 	return os.Rename(src, dst)
 }
 
-func copyFile(src string, dst string) error {
+// writeCopy copies src to a new dst and returns the size and SHA-256 of src,
+// hashed while it is copied. With sync dst is flushed to disk, or the server
+// it is on, before it is closed so reading it back reads what was stored and
+// not the page cache.
+func writeCopy(src string, dst string, sync bool) (ManifestEntry, error) {
 	if exists(dst) {
 		errStr := fmt.Sprintf("Cannot clobber %s with %s\n", dst, src)
-		return errors.New(errStr)
+		return ManifestEntry{}, errors.New(errStr)
 	}
 
-	content, err := ioutil.ReadFile(src)
+	in, err := os.Open(src)
+	if err != nil {
+		return ManifestEntry{}, err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return ManifestEntry{}, err
+	}
+
+	hash := sha256.New()
+
+	size, err := io.Copy(out, io.TeeReader(in, hash))
+	if err == nil && sync {
+		err = out.Sync()
+	}
+
+	closeErr := out.Close()
+	if err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		_ = os.Remove(dst)
+		return ManifestEntry{}, err
+	}
+
+	return ManifestEntry{size, hex.EncodeToString(hash.Sum(nil))}, nil
+}
+
+func copyFile(src string, dst string) error {
+	_, err := writeCopy(src, dst, false)
+	return err
+}
+
+// verifiedTransfer copies src to dst and reads dst back to check it has the
+// SHA-256 of src before it counts as transferred, and src is removed when
+// moving. A dst that does not match is removed.
+func verifiedTransfer(src string, dst string, action Action) error {
+	want, err := writeCopy(src, dst, true)
 	if err != nil {
 		return err
 	}
 
-	err = ioutil.WriteFile(dst, content, 0600)
+	got, err := hashFile(dst)
 	if err != nil {
 		return err
 	}
 
+	if got != want {
+		_ = os.Remove(dst)
+		return &mismatchError{src, dst}
+	}
+
+	if action == ActionMove {
+		return os.Remove(src)
+	}
+
+	return nil
+}
+
+// transferFile copies or moves src to dst, checking dst after when verify is
+// set.
+func transferFile(src string, dst string, action Action, verify bool) error {
+	switch {
+	case action != ActionCopy && action != ActionMove:
+		return fmt.Errorf("unknown Action %s", action)
+	case verify:
+		return verifiedTransfer(src, dst, action)
+	case action == ActionMove:
+		return moveFile(src, dst)
+	default:
+		return copyFile(src, dst)
+	}
+}
+
+// replaceFile transfers src over the file dst. src is transferred to a
+// temporary name next to dst, and checked when verify is set, before it is
+// renamed to dst so dst is kept if the transfer fails.
+func replaceFile(src string, dst string, action Action, verify bool) error {
+	tmp := dst + ".exifsort-replace"
+
+	err := transferFile(src, tmp, action, verify)
+	if err != nil {
+		if !exists(src) {
+			// Moved, tmp is all there is.
+			return err
+		}
+
+		_ = os.Remove(tmp)
+
+		return err
+	}
+
+	return os.Rename(tmp, dst)
+}
+
+// moveInto moves path into dir, renamed if its name is taken there. It is
+// removed if dir has the same file already.
+func moveInto(path string, dir string) error {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return err
+	}
+
+	base, err := uniqueName(path, NamingCounter, func(filename string) string {
+		dirPath := filepath.Join(dir, filename)
+		if exists(dirPath) {
+			return dirPath
+		}
+
+		return ""
+	})
+
+	var dupErr *duplicateError
+
+	switch {
+	case errors.As(err, &dupErr):
+		return os.Remove(path)
+	case err != nil:
+		return err
+	}
+
+	return moveFile(path, filepath.Join(dir, base))
+}
+
+// linkFile replaces dst with a hard link to src.
+func linkFile(src string, dst string) error {
+	tmp := dst + ".exifsort-link"
+
+	err := os.Link(src, tmp)
+	if err != nil {
+		return err
+	}
+
+	err = os.Rename(tmp, dst)
+	if err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+
 	return nil
 }
 
@@ -82,16 +252,18 @@ type collisionNameFunc func(filename string) string
 // the filename with a counter as part of the name.
 //
 // So <name>.jpg => <name>_#.jpg. The number increments as it may have
-// multiple collisions. This way we can create a new unique name.
+// multiple collisions. This way we can create a new unique name. NamingHash
+// tries <name>_<hash>.jpg first, then counts from there.
 // We accept a function to determine if the filenames collide with the caller's
 // file set.
-func uniqueName(path string, doesCollide collisionNameFunc) (string, error) {
+func uniqueName(path string, naming Naming, doesCollide collisionNameFunc) (string, error) {
 	var filename = filepath.Base(path)
 
 	extension := filepath.Ext(filename)
-	prefix := strings.TrimRight(filename, extension)
+	prefix := strings.TrimSuffix(filename, extension)
+	hashed := false
 
-	for counter := 0; true; counter++ {
+	for counter := 0; true; {
 		// Test for unique filename
 		collisionPath := doesCollide(filename)
 		if collisionPath == "" {
@@ -115,8 +287,21 @@ func uniqueName(path string, doesCollide collisionNameFunc) (string, error) {
 			return "", &err
 		}
 
+		if naming == NamingHash && !hashed {
+			filename, err = hashName(path)
+			if err != nil {
+				return "", err
+			}
+
+			prefix = strings.TrimSuffix(filename, extension)
+			hashed = true
+
+			continue
+		}
+
 		// Try a new filename then
 		filename = fmt.Sprintf("%s_%d%s", prefix, counter, extension)
+		counter++
 	}
 
 	return filename, nil
@@ -102,3 +102,84 @@ func TestOSCopyFile(t *testing.T) {
 		t.Fatalf("src does not exist.\n")
 	}
 }
+
+func TestOSVerifiedTransfer(t *testing.T) {
+	t.Parallel()
+
+	testDir, _ := ioutil.TempDir("", "verifyDir_")
+	defer os.RemoveAll(testDir)
+
+	err := testOSPopulateFile(testDir, exifPath)
+	if err != nil {
+		t.Fatalf("Cannot write %s %s\n", exifPath, err.Error())
+	}
+
+	src := filepath.Join(testDir, filepath.Base(exifPath))
+	copied := filepath.Join(testDir, "copied.jpg")
+	moved := filepath.Join(testDir, "moved.jpg")
+
+	err = transferFile(src, copied, ActionCopy, true)
+	if err != nil || !exists(src) || !exists(copied) {
+		t.Fatalf("Expected a verified copy err %v\n", err)
+	}
+
+	err = transferFile(src, copied, ActionMove, true)
+	if err == nil || !exists(src) {
+		t.Fatalf("We clobbered a file.\n")
+	}
+
+	err = transferFile(src, moved, ActionMove, true)
+	if err != nil || exists(src) || !exists(moved) {
+		t.Fatalf("Expected a verified move err %v\n", err)
+	}
+
+	equal, _ := isEqual(copied, moved)
+	if !equal {
+		t.Errorf("%s and %s differ\n", copied, moved)
+	}
+
+	err = transferFile(moved, src, ActionNone, true)
+	if err == nil {
+		t.Errorf("Expected error for an unknown action\n")
+	}
+}
+
+func TestOSReplaceFile(t *testing.T) {
+	t.Parallel()
+
+	testDir, _ := ioutil.TempDir("", "replaceDir_")
+	defer os.RemoveAll(testDir)
+
+	_ = testOSPopulateFile(testDir, exifPath)
+	_ = testOSPopulateFile(testDir, noExifPath)
+
+	src := filepath.Join(testDir, filepath.Base(exifPath))
+	dst := filepath.Join(testDir, filepath.Base(noExifPath))
+
+	// A transfer that fails leaves dst as it was.
+	_ = os.Mkdir(dst+".exifsort-replace", 0755)
+
+	err := replaceFile(src, dst, ActionMove, true)
+	if err == nil || !exists(src) || exists(dst+".exifsort-replace") {
+		t.Fatalf("Expected a failed replace err %v\n", err)
+	}
+
+	equal, _ := isEqual(noExifPath, dst)
+	if !equal {
+		t.Errorf("%s changed by a failed replace\n", dst)
+	}
+
+	for _, action := range []Action{ActionCopy, ActionMove} {
+		_ = copyFile(exifPath, src)
+
+		err = replaceFile(src, dst, action, true)
+		if err != nil || exists(src) != (action == ActionCopy) {
+			t.Fatalf("%s: expected a replace err %v\n", action, err)
+		}
+
+		equal, _ = isEqual(exifPath, dst)
+		if !equal || exists(dst+".exifsort-replace") {
+			t.Errorf("%s: %s was not replaced\n", action, dst)
+		}
+	}
+}
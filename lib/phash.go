@@ -0,0 +1,235 @@
+package exifsort
+
+import (
+	"image"
+	"math/bits"
+	"os"
+	"sort"
+
+	// Register the formats image.Decode reads.
+	_ "image/jpeg"
+	_ "image/png"
+)
+
+// dHash compares neighbouring cells of a dHashWidth by dHashHeight grey
+// thumbnail of the image, one bit for each pair in a row.
+const (
+	dHashWidth  = 9
+	dHashHeight = 8
+	// dHashSamples is the most pixels read across and down each cell.
+	dHashSamples = 8
+)
+
+// NearThreshold is the default number of bits two dHashes may differ by for
+// their images to be near duplicates.
+const NearThreshold = 10
+
+// ImageHash is the perceptual hash and size of a decoded image. Resizing or
+// compressing an image again barely changes its DHash.
+type ImageHash struct {
+	DHash  uint64
+	Width  int
+	Height int
+}
+
+// IsZero reports if h is not the hash of an image.
+func (h ImageHash) IsZero() bool {
+	return h.Width == 0 || h.Height == 0
+}
+
+// Distance returns the number of bits the dHashes of h and other differ by.
+func (h ImageHash) Distance(other ImageHash) int {
+	return bits.OnesCount64(h.DHash ^ other.DHash)
+}
+
+// cellGrey returns the mean grey of the cell of img from min to max,
+// reading at most dHashSamples pixels across and down.
+func cellGrey(img image.Image, min image.Point, max image.Point) uint32 {
+	stepX := (max.X-min.X)/dHashSamples + 1
+	stepY := (max.Y-min.Y)/dHashSamples + 1
+
+	var sum, count uint32
+
+	for y := min.Y; y < max.Y; y += stepY {
+		for x := min.X; x < max.X; x += stepX {
+			r, g, b, _ := img.At(x, y).RGBA()
+			// ITU-R 601 luma, out of 1000.
+			sum += (299*(r>>8) + 587*(g>>8) + 114*(b>>8)) / 1000
+			count++
+		}
+	}
+
+	if count == 0 {
+		return 0
+	}
+
+	return sum / count
+}
+
+// dHash returns the difference hash of img.
+func dHash(img image.Image) uint64 {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+
+	var hash uint64
+
+	for row := 0; row < dHashHeight; row++ {
+		minY := bounds.Min.Y + row*height/dHashHeight
+		maxY := bounds.Min.Y + (row+1)*height/dHashHeight
+
+		var greys [dHashWidth]uint32
+
+		for col := 0; col < dHashWidth; col++ {
+			minX := bounds.Min.X + col*width/dHashWidth
+			maxX := bounds.Min.X + (col+1)*width/dHashWidth
+			greys[col] = cellGrey(img, image.Pt(minX, minY), image.Pt(maxX, maxY))
+		}
+
+		for col := 0; col < dHashWidth-1; col++ {
+			hash <<= 1
+
+			if greys[col] < greys[col+1] {
+				hash |= 1
+			}
+		}
+	}
+
+	return hash
+}
+
+// ImageHashGet decodes the JPEG or PNG image at path and returns its hash.
+func ImageHashGet(path string) (ImageHash, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return ImageHash{}, err
+	}
+	defer file.Close()
+
+	img, _, err := image.Decode(file)
+	if err != nil {
+		return ImageHash{}, err
+	}
+
+	bounds := img.Bounds()
+
+	return ImageHash{dHash(img), bounds.Dx(), bounds.Dy()}, nil
+}
+
+// bkTree is a BK-tree of dHashes. Children are keyed by their distance to
+// their parent, so by the triangle inequality a search for the hashes within
+// threshold of one at distance d of a node only descends into the children
+// from d-threshold to d+threshold.
+type bkTree struct {
+	hash uint64
+	// indexes are those of the images with the hash.
+	indexes  []int
+	children map[int]*bkTree
+}
+
+func newBKTree(hash uint64, index int) *bkTree {
+	return &bkTree{hash, []int{index}, make(map[int]*bkTree)}
+}
+
+func (t *bkTree) add(hash uint64, index int) {
+	for {
+		dist := bits.OnesCount64(t.hash ^ hash)
+		if dist == 0 {
+			t.indexes = append(t.indexes, index)
+			return
+		}
+
+		child, present := t.children[dist]
+		if !present {
+			t.children[dist] = newBKTree(hash, index)
+			return
+		}
+
+		t = child
+	}
+}
+
+// near calls found with the index of every image whose hash is within
+// threshold bits of hash.
+func (t *bkTree) near(hash uint64, threshold int, found func(index int)) {
+	dist := bits.OnesCount64(t.hash ^ hash)
+	if dist <= threshold {
+		for _, index := range t.indexes {
+			found(index)
+		}
+	}
+
+	for childDist, child := range t.children {
+		if childDist >= dist-threshold && childDist <= dist+threshold {
+			child.near(hash, threshold, found)
+		}
+	}
+}
+
+// NearDuplicates returns the groups of images in hashes whose dHashes differ
+// by at most threshold bits, directly or through other images of the group.
+// The image with the most pixels comes first in every group, it is the one
+// to keep. Groups are sorted by their first path.
+//
+// Each image is only compared to the ones a BK-tree of the images before it
+// finds could be near, not to every other image.
+func NearDuplicates(hashes map[string]ImageHash, threshold int) [][]string {
+	paths := make([]string, 0, len(hashes))
+	for path := range hashes {
+		paths = append(paths, path)
+	}
+
+	sort.Strings(paths)
+
+	// Union find of the index of each path.
+	parents := make([]int, len(paths))
+	for i := range parents {
+		parents[i] = i
+	}
+
+	var find func(i int) int
+	find = func(i int) int {
+		if parents[i] != i {
+			parents[i] = find(parents[i])
+		}
+
+		return parents[i]
+	}
+
+	var tree *bkTree
+
+	for i, path := range paths {
+		hash := hashes[path].DHash
+
+		if tree == nil {
+			tree = newBKTree(hash, i)
+			continue
+		}
+
+		tree.near(hash, threshold, func(j int) { parents[find(i)] = find(j) })
+		tree.add(hash, i)
+	}
+
+	members := make(map[int][]string)
+	for i, path := range paths {
+		members[find(i)] = append(members[find(i)], path)
+	}
+
+	var groups [][]string
+
+	for _, group := range members {
+		if len(group) < 2 {
+			continue
+		}
+
+		sort.SliceStable(group, func(i, j int) bool {
+			a, b := hashes[group[i]], hashes[group[j]]
+			return a.Width*a.Height > b.Width*b.Height
+		})
+
+		groups = append(groups, group)
+	}
+
+	sort.Slice(groups, func(i, j int) bool { return groups[i][0] < groups[j][0] })
+
+	return groups
+}
@@ -0,0 +1,201 @@
+package exifsort
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// testShrink returns img a quarter of its width and height.
+func testShrink(img image.Image) image.Image {
+	const factor = 4
+
+	bounds := img.Bounds()
+	small := image.NewRGBA(image.Rect(0, 0, bounds.Dx()/factor, bounds.Dy()/factor))
+
+	for y := 0; y < small.Bounds().Dy(); y++ {
+		for x := 0; x < small.Bounds().Dx(); x++ {
+			small.Set(x, y, img.At(bounds.Min.X+x*factor, bounds.Min.Y+y*factor))
+		}
+	}
+
+	return small
+}
+
+// testGradient returns an image getting lighter to the right, or to the left
+// when mirrored.
+func testGradient(mirrored bool) image.Image {
+	const size = 64
+
+	img := image.NewGray(image.Rect(0, 0, size, size))
+
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			grey := uint8(x * 4)
+			if mirrored {
+				grey = uint8((size - 1 - x) * 4)
+			}
+
+			img.SetGray(x, y, color.Gray{grey})
+		}
+	}
+
+	return img
+}
+
+func TestImageHash(t *testing.T) {
+	t.Parallel()
+
+	dir, _ := ioutil.TempDir("", "phash_")
+	defer os.RemoveAll(dir)
+
+	original, err := ImageHashGet(exifPath)
+	if err != nil {
+		t.Fatalf("Unexpected error %s\n", err.Error())
+	}
+
+	file, _ := os.Open(exifPath)
+	img, _ := jpeg.Decode(file)
+	file.Close()
+
+	// A smaller copy compressed again, the way messaging apps do.
+	smallPath := filepath.Join(dir, "small.jpg")
+	file, _ = os.Create(smallPath)
+	_ = jpeg.Encode(file, testShrink(img), &jpeg.Options{Quality: 40})
+	file.Close()
+
+	small, err := ImageHashGet(smallPath)
+	if err != nil {
+		t.Fatalf("Unexpected error %s\n", err.Error())
+	}
+
+	if original.Distance(small) > NearThreshold {
+		t.Errorf("Expected a near duplicate got distance %d\n", original.Distance(small))
+	}
+
+	if small.Width != original.Width/4 || small.Height != original.Height/4 {
+		t.Errorf("Expected %dx%d got %dx%d\n", original.Width/4, original.Height/4,
+			small.Width, small.Height)
+	}
+
+	gradient := dHash(testGradient(false))
+	mirrored := dHash(testGradient(true))
+
+	if distance := (ImageHash{DHash: gradient}).Distance(ImageHash{DHash: mirrored}); distance != 64 {
+		t.Errorf("Expected mirrored gradients to differ in every bit got %d\n", distance)
+	}
+
+	_, err = ImageHashGet(skipPath)
+	if err == nil {
+		t.Errorf("Expected error hashing %s\n", skipPath)
+	}
+}
+
+func TestNearDuplicates(t *testing.T) {
+	hashes := map[string]ImageHash{
+		"small.jpg":  {0xff00, 10, 10},
+		"big.jpg":    {0xff01, 100, 100},
+		"medium.jpg": {0xff03, 50, 50},
+		"other.jpg":  {0x00ff, 100, 100},
+		"alone.png":  {0xf0f0f0f0, 10, 10},
+	}
+
+	groups := NearDuplicates(hashes, 1)
+	if len(groups) != 1 {
+		t.Fatalf("Expected 1 group got %v\n", groups)
+	}
+
+	expected := []string{"big.jpg", "medium.jpg", "small.jpg"}
+	for i, path := range expected {
+		if groups[0][i] != path {
+			t.Errorf("Expected %v got %v\n", expected, groups[0])
+			break
+		}
+	}
+
+	if len(NearDuplicates(hashes, 0)) != 0 {
+		t.Errorf("Expected no groups with threshold 0\n")
+	}
+}
+
+func TestNearDuplicatesMany(t *testing.T) {
+	// Images near a few originals, each bit flipped with a chance of 1/16.
+	const numImages, numOriginals, threshold = 2000, 50, 6
+
+	random := rand.New(rand.NewSource(1))
+	originals := make([]uint64, numOriginals)
+
+	for i := range originals {
+		originals[i] = random.Uint64()
+	}
+
+	hashes := make(map[string]ImageHash)
+
+	for i := 0; i < numImages; i++ {
+		hash := originals[random.Intn(numOriginals)]
+		for bit := 0; bit < 64; bit++ {
+			if random.Intn(16) == 0 {
+				hash ^= 1 << bit
+			}
+		}
+
+		hashes[fmt.Sprintf("%04d.jpg", i)] = ImageHash{hash, 10, 10}
+	}
+
+	group := make(map[string]int)
+
+	for i, paths := range NearDuplicates(hashes, threshold) {
+		for _, path := range paths {
+			group[path] = i + 1
+		}
+	}
+
+	// Every pair within the threshold has to share a group.
+	for a, hashA := range hashes {
+		for b, hashB := range hashes {
+			if a != b && hashA.Distance(hashB) <= threshold &&
+				(group[a] == 0 || group[a] != group[b]) {
+				t.Fatalf("Expected %s and %s in a group\n", a, b)
+			}
+		}
+	}
+}
+
+func TestScanPHash(t *testing.T) {
+	t.Parallel()
+
+	dir, _ := ioutil.TempDir("", "scan_phash_")
+	defer os.RemoveAll(dir)
+
+	_ = copyFile(exifPath, filepath.Join(dir, "photo.jpg"))
+
+	file, _ := os.Create(filepath.Join(dir, "gradient.png"))
+	_ = png.Encode(file, testGradient(false))
+	file.Close()
+
+	scanner := NewScanner()
+	scanner.PHash = true
+
+	err := scanner.ScanDir(dir, NopObserver{})
+	if err != nil {
+		t.Fatalf("Unexpected error %s\n", err.Error())
+	}
+
+	if len(scanner.Hashes) != 2 {
+		t.Errorf("Expected 2 hashes got %d\n", len(scanner.Hashes))
+	}
+
+	scanner = NewScanner()
+	_ = scanner.ScanDir(dir, NopObserver{})
+
+	if len(scanner.Hashes) != 0 {
+		t.Errorf("Expected no hashes without PHash got %d\n", len(scanner.Hashes))
+	}
+}
@@ -0,0 +1,166 @@
+package exifsort
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+// Collision decides what happens to media whose name is taken by another
+// file in the directory it is transferred to.
+type Collision int
+
+const (
+	// CollisionRename : the media is renamed <name>_N.<ext>
+	CollisionRename Collision = iota
+	// CollisionSkip : the media is left where it is
+	CollisionSkip
+	// CollisionNewer : the file with the newer modtime takes the name, the
+	// other is left where it is
+	CollisionNewer
+	// CollisionFail : nothing more is transferred
+	CollisionFail
+	// CollisionNone : Error Value
+	CollisionNone
+)
+
+// Returns name of collision value (all lower case).
+func (c Collision) String() string {
+	return [...]string{"rename", "skip", "newer", "fail", "none"}[c]
+}
+
+// Collisions returns all collision values used excluding CollisionNone.
+func Collisions() []Collision {
+	return []Collision{
+		CollisionRename,
+		CollisionSkip,
+		CollisionNewer,
+		CollisionFail,
+	}
+}
+
+// CollisionParse returns Collision from string (must be lower case). Returns
+// CollisionNone if invalid.
+func CollisionParse(str string) (Collision, error) {
+	for _, val := range Collisions() {
+		if str == val.String() {
+			return val, nil
+		}
+	}
+
+	return CollisionNone, fmt.Errorf("invalid collision %s", str)
+}
+
+// Duplicate decides what happens to media in src with the same name and
+// contents as a file that is transferred.
+type Duplicate int
+
+const (
+	// DuplicateAuto : removed from src when moving, kept when copying
+	DuplicateAuto Duplicate = iota
+	// DuplicateDelete : removed from src
+	DuplicateDelete
+	// DuplicateKeep : left in src
+	DuplicateKeep
+	// DuplicateMove : moved to the duplicates directory
+	DuplicateMove
+	// DuplicateNone : Error Value
+	DuplicateNone
+)
+
+// Returns name of duplicate value (all lower case).
+func (d Duplicate) String() string {
+	return [...]string{"auto", "delete", "keep", "move", "none"}[d]
+}
+
+// Duplicates returns all duplicate values used excluding DuplicateNone.
+func Duplicates() []Duplicate {
+	return []Duplicate{
+		DuplicateAuto,
+		DuplicateDelete,
+		DuplicateKeep,
+		DuplicateMove,
+	}
+}
+
+// DuplicateParse returns Duplicate from string (must be lower case). Returns
+// DuplicateNone if invalid.
+func DuplicateParse(str string) (Duplicate, error) {
+	for _, val := range Duplicates() {
+		if str == val.String() {
+			return val, nil
+		}
+	}
+
+	return DuplicateNone, fmt.Errorf("invalid duplicate %s", str)
+}
+
+// collisionError is returned when media can't be transferred because of
+// CollisionFail.
+type collisionError struct {
+	src string
+	dst string
+}
+
+func (e *collisionError) Error() string {
+	return fmt.Sprintf("%s collides with %s", e.src, e.dst)
+}
+
+// isNewer reports if the modtime of path is after the one of other.
+func isNewer(path string, other string) (bool, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return false, err
+	}
+
+	otherInfo, err := os.Stat(other)
+	if err != nil {
+		return false, err
+	}
+
+	return info.ModTime().After(otherInfo.ModTime()), nil
+}
+
+// collide applies policy to media src whose name is taken by the file taken.
+// It reports if src replaces taken, which is left to the caller to do with
+// replaceFile so taken is kept until src has made it. It returns a
+// duplicateError if they are the same file and a collisionError for
+// CollisionFail. CollisionRename is up to the caller.
+func collide(policy Collision, src string, taken string) (bool, error) {
+	equal, err := isEqual(src, taken)
+
+	switch {
+	case err != nil:
+		return false, err
+	case equal:
+		return false, &duplicateError{src: src, dst: taken}
+	}
+
+	switch policy {
+	case CollisionSkip:
+		return false, nil
+	case CollisionNewer:
+		return isNewer(src, taken)
+	default:
+		return false, &collisionError{src, taken}
+	}
+}
+
+// disposeDuplicate does what policy says with the duplicate path of media
+// transferred by action. dir is the duplicates directory of DuplicateMove. It
+// reports if path was removed from where it was.
+func disposeDuplicate(path string, policy Duplicate, action Action,
+	dir string) (bool, error) {
+	switch {
+	case policy == DuplicateKeep, policy == DuplicateAuto && action != ActionMove:
+		return false, nil
+	case policy == DuplicateDelete, policy == DuplicateAuto:
+		return true, os.Remove(path)
+	case policy == DuplicateMove && dir == "":
+		return false, errors.New("no duplicates directory")
+	case policy == DuplicateMove:
+		return true, moveInto(path, dir)
+	default:
+		return false, fmt.Errorf("invalid duplicate %s", policy)
+	}
+}
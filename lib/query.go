@@ -0,0 +1,179 @@
+package exifsort
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrorState selects media by the errors scanning it.
+type ErrorState int
+
+const (
+	// ErrorStateAny : all media, with errors or not
+	ErrorStateAny ErrorState = iota
+	// ErrorStateNone : media scanned without errors
+	ErrorStateNone
+	// ErrorStateExif : media whose exif or movie metadata could not be read
+	ErrorStateExif
+	// ErrorStateScan : files that could not be scanned at all
+	ErrorStateScan
+	// ErrorStateInvalid : Error Value
+	ErrorStateInvalid
+)
+
+// Returns name of error state value (all lower case).
+func (e ErrorState) String() string {
+	return [...]string{"any", "none", "exif", "scan", "invalid"}[e]
+}
+
+// ErrorStates returns all error state values used excluding
+// ErrorStateInvalid.
+func ErrorStates() []ErrorState {
+	return []ErrorState{
+		ErrorStateAny,
+		ErrorStateNone,
+		ErrorStateExif,
+		ErrorStateScan,
+	}
+}
+
+// ErrorStateParse returns ErrorState from string (must be lower case).
+// Returns ErrorStateInvalid if invalid.
+func ErrorStateParse(str string) (ErrorState, error) {
+	for _, val := range ErrorStates() {
+		if str == val.String() {
+			return val, nil
+		}
+	}
+
+	return ErrorStateInvalid, fmt.Errorf("invalid error state %s", str)
+}
+
+// QueryMatch is one file a Query selected.
+type QueryMatch struct {
+	Path string
+	// Time is zero for files that could not be scanned.
+	Time   time.Time
+	Source TimeSource
+	Info   MediaInfo
+	// Error is why the file could not be scanned, or its exif read.
+	Error string
+}
+
+// Query selects files of a scan. The zero Query selects every file that
+// was scanned, with errors or not.
+type Query struct {
+	// Range only selects media with times in it.
+	Range DateRange
+	// Extensions only selects files with one of them, such as ".mov".
+	// They are compared without case.
+	Extensions []string
+	// Cameras only selects media taken by one of them.
+	Cameras Cameras
+	// Sources only selects media whose time came from one of them.
+	Sources []TimeSource
+	// Errors selects files by the errors scanning them.
+	Errors ErrorState
+}
+
+func (q *Query) hasExtension(path string) bool {
+	if len(q.Extensions) == 0 {
+		return true
+	}
+
+	ext := strings.ToLower(filepath.Ext(path))
+
+	for _, want := range q.Extensions {
+		if ext == "."+strings.TrimPrefix(strings.ToLower(want), ".") {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (q *Query) hasSource(source TimeSource) bool {
+	if len(q.Sources) == 0 {
+		return true
+	}
+
+	for _, want := range q.Sources {
+		if source == want {
+			return true
+		}
+	}
+
+	return false
+}
+
+// selects reports if match is selected by everything but the errors.
+func (q *Query) selects(match QueryMatch) bool {
+	if !q.hasExtension(match.Path) {
+		return false
+	}
+
+	// Files that could not be scanned only have a name.
+	if match.Time.IsZero() {
+		return q.Range.IsZero() && len(q.Cameras) == 0 && len(q.Sources) == 0
+	}
+
+	return q.Range.Contains(match.Time) && q.Cameras.Match(match.Info) &&
+		q.hasSource(match.Source)
+}
+
+// Run returns the files of scanner the query selects in time order, then
+// path order. Files that could not be scanned come first.
+func (q *Query) Run(scanner *Scanner) ([]QueryMatch, error) {
+	if q.Errors >= ErrorStateInvalid {
+		return nil, fmt.Errorf("invalid error state %s", q.Errors)
+	}
+
+	var matches []QueryMatch
+
+	if q.Errors == ErrorStateAny || q.Errors == ErrorStateScan {
+		for path, err := range scanner.ScanErrors {
+			match := QueryMatch{Path: path, Source: TimeSourceNone, Error: err}
+			if q.selects(match) {
+				matches = append(matches, match)
+			}
+		}
+	}
+
+	if q.Errors == ErrorStateScan {
+		return sortMatches(matches), nil
+	}
+
+	for path, t := range scanner.Data {
+		exifErr, failed := scanner.ExifErrors[path]
+
+		switch {
+		case q.Errors == ErrorStateNone && failed:
+			continue
+		case q.Errors == ErrorStateExif && !failed:
+			continue
+		}
+
+		match := QueryMatch{path, t, scanner.Source(path), scanner.Info[path], exifErr}
+		if q.selects(match) {
+			matches = append(matches, match)
+		}
+	}
+
+	return sortMatches(matches), nil
+}
+
+func sortMatches(matches []QueryMatch) []QueryMatch {
+	sort.Slice(matches, func(i, j int) bool {
+		ti, tj := matches[i].Time, matches[j].Time
+		if !ti.Equal(tj) {
+			return ti.Before(tj)
+		}
+
+		return matches[i].Path < matches[j].Path
+	})
+
+	return matches
+}
@@ -0,0 +1,110 @@
+package exifsort
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// queryScanner returns a scanner of a photo with exif data, one without and
+// a movie from 2015 and 2020, and a file that could not be scanned.
+func queryScanner() Scanner {
+	scanner := NewScanner()
+
+	scanner.Data["a/photo.jpg"] = time.Date(2020, 4, 27, 12, 0, 0, 0, time.Local)
+	scanner.Info["a/photo.jpg"] = MediaInfo{Make: "Apple", Model: "iPhone 11 Pro"}
+	scanner.Sources["a/photo.jpg"] = TimeSourceExif
+
+	scanner.Data["a/noexif.JPG"] = time.Date(2020, 7, 1, 12, 0, 0, 0, time.Local)
+	scanner.ExifErrors["a/noexif.JPG"] = "no exif data"
+
+	scanner.Data["b/clip.mov"] = time.Date(2015, 7, 4, 12, 0, 0, 0, time.Local)
+	scanner.Sources["b/clip.mov"] = TimeSourceMovie
+
+	scanner.ScanErrors["b/broken.mov"] = "permission denied"
+
+	return scanner
+}
+
+func TestQuery(t *testing.T) {
+	t.Parallel()
+
+	scanner := queryScanner()
+
+	july, _ := ParseDateRange("", "", "2020-07")
+
+	expected := []struct {
+		query Query
+		paths []string
+	}{
+		{Query{}, []string{"b/broken.mov", "b/clip.mov", "a/photo.jpg", "a/noexif.JPG"}},
+		{Query{Extensions: []string{"MOV"}}, []string{"b/broken.mov", "b/clip.mov"}},
+		{Query{Extensions: []string{".jpg"}, Range: july}, []string{"a/noexif.JPG"}},
+		{Query{Cameras: Cameras{"iphone"}}, []string{"a/photo.jpg"}},
+		{Query{Sources: []TimeSource{TimeSourceModTime}}, []string{"a/noexif.JPG"}},
+		{Query{Errors: ErrorStateNone}, []string{"b/clip.mov", "a/photo.jpg"}},
+		{Query{Errors: ErrorStateExif}, []string{"a/noexif.JPG"}},
+		{Query{Errors: ErrorStateScan}, []string{"b/broken.mov"}},
+	}
+
+	for i, e := range expected {
+		matches, err := e.query.Run(&scanner)
+		if err != nil {
+			t.Fatalf("%d: unexpected error %s\n", i, err.Error())
+		}
+
+		if len(matches) != len(e.paths) {
+			t.Errorf("%d: expected %v got %v\n", i, e.paths, matches)
+			continue
+		}
+
+		for j, match := range matches {
+			if match.Path != e.paths[j] {
+				t.Errorf("%d: expected %v got %v\n", i, e.paths, matches)
+				break
+			}
+		}
+	}
+
+	_, err := (&Query{Errors: ErrorStateInvalid}).Run(&scanner)
+	if err == nil {
+		t.Errorf("Expected error for an invalid error state\n")
+	}
+}
+
+func TestScanSources(t *testing.T) {
+	t.Parallel()
+
+	dir, _ := ioutil.TempDir("", "sources_")
+	defer os.RemoveAll(dir)
+
+	photo, noExif := filepath.Join(dir, "photo.jpg"), filepath.Join(dir, "noexif.jpg")
+	_ = copyFile(exifPath, photo)
+	_ = copyFile(noExifPath, noExif)
+
+	scanner := NewScanner()
+	_ = scanner.ScanDir(dir, NopObserver{})
+
+	jsonPath := filepath.Join(dir, "scan.json")
+	_ = scanner.Save(jsonPath)
+
+	loaded := NewScanner()
+
+	err := loaded.Load(jsonPath)
+	if err != nil {
+		t.Fatalf("Unexpected error %s\n", err.Error())
+	}
+
+	// Scans saved before Sources still have a source.
+	old := NewScanner()
+	_ = old.Load(jsonPath)
+	old.Sources = nil
+
+	for _, s := range []Scanner{scanner, loaded, old} {
+		if s.Source(photo) != TimeSourceExif || s.Source(noExif) != TimeSourceModTime {
+			t.Errorf("Expected exif and modtime got %s and %s\n", s.Source(photo), s.Source(noExif))
+		}
+	}
+}
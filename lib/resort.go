@@ -0,0 +1,114 @@
+package exifsort
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// sortedTime returns the start of the period the directory of path in root
+// stands for if that is all idx needs to know to place path.
+func sortedTime(root string, path string, idx index) (time.Time, bool) {
+	start, end, ok := mergePathPeriod(root, path)
+	if !ok {
+		return start, false
+	}
+
+	last := end.Add(-time.Nanosecond)
+	if idx.PathStr(start, "") != idx.PathStr(last, "") {
+		return start, false
+	}
+
+	return start, true
+}
+
+// ScanSorted is ScanDir for src, a directory sorted by any method such as the
+// dst of a sort, that is going to be sorted by method. The directory of a
+// file often tells its time well enough for method, only the files method
+// needs a finer time for are read, such as for day from a directory sorted by
+// month. With rescan every file is read. It returns the method src is sorted
+// by.
+//
+// Files that are not read are neither sniffed nor filtered, have no Info and
+// their source is TimeSourcePath.
+func (s *Scanner) ScanSorted(src string, method Method, rescan bool,
+	observer Observer) (Method, error) {
+	s.Input = ScannerInputDir
+
+	exts := extensionsOrDefault(s.Extensions)
+	rules := rulesOrDefault(s.Rules)
+
+	srcMethod, err := mergeCheck(src, exts, rules)
+	if err != nil {
+		return MethodNone, &InvalidDirError{src, err}
+	}
+
+	idx, err := newIndex(method)
+	if err != nil {
+		return srcMethod, err
+	}
+
+	// Events need the time of every file.
+	rescan = rescan || method == MethodEvent
+
+	s.scan(exts, observer, func(paths chan<- string, results chan<- scanResult) {
+		scanFunc := s.scanFunc(paths, results)
+
+		_ = rules.Walk(src, func(path string, info os.FileInfo, err error) error {
+			if err != nil || info.IsDir() || rescan {
+				return scanFunc(path, info, err)
+			}
+
+			switch exts.Category(path) {
+			case CategoryExif, CategoryMovie, CategoryModTime:
+				time, ok := sortedTime(src, path, idx)
+				if ok {
+					results <- scanResult{path: path, time: time, source: TimeSourcePath}
+					return nil
+				}
+			case CategorySkip, CategorySidecar:
+			}
+
+			return scanFunc(path, info, err)
+		})
+	})
+
+	return srcMethod, nil
+}
+
+// RemoveEmptyDirs removes the directories of paths below root that are empty,
+// and the ones above them that only held those, such as the ones a move
+// leaves behind. Other empty directories are kept. It returns how many it
+// removed.
+func RemoveEmptyDirs(root string, paths []string) (int, error) {
+	removed := 0
+
+	for _, path := range paths {
+		// Up to but not including root.
+		for dir := filepath.Dir(path); inDir(root, dir) && !inDir(dir, root); dir = filepath.Dir(dir) {
+			entries, err := ioutil.ReadDir(dir)
+			if os.IsNotExist(err) {
+				// Removed for another path already
+				continue
+			}
+
+			if err != nil {
+				return removed, err
+			}
+
+			if len(entries) != 0 {
+				break
+			}
+
+			err = os.Remove(dir)
+			if err != nil {
+				return removed, err
+			}
+
+			removed++
+		}
+	}
+
+	return removed, nil
+}
@@ -0,0 +1,248 @@
+package exifsort
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestScanSorted(t *testing.T) {
+	t.Parallel()
+
+	td := newTestDir(t, MethodDay, fileNoDefault)
+	src := td.buildRoot()
+	sorted := td.buildSortedDir(src, "sorted_", ActionCopy)
+
+	defer os.RemoveAll(src)
+	defer os.RemoveAll(sorted)
+
+	// Days tell the month, no file is read.
+	scanner := NewScanner()
+
+	method, err := scanner.ScanSorted(sorted, MethodMonth, false, NopObserver{})
+	if err != nil || method != MethodDay {
+		t.Fatalf("Expected day got %s err %v\n", method, err)
+	}
+
+	if len(scanner.Data) != td.numData || len(scanner.Info) != 0 {
+		t.Errorf("Expected %d files and no info got %d and %d\n",
+			td.numData, len(scanner.Data), len(scanner.Info))
+	}
+
+	for path, time := range scanner.Data {
+		start, _, _ := mergePathPeriod(sorted, path)
+		if !time.Equal(start) || scanner.Source(path) != TimeSourcePath {
+			t.Errorf("%s: expected %s from path got %s from %s\n", path, start,
+				time, scanner.Source(path))
+		}
+	}
+
+	// Unless we ask for it.
+	scanner = NewScanner()
+	_, _ = scanner.ScanSorted(sorted, MethodMonth, true, NopObserver{})
+
+	numExif := td.numData - td.numExifError
+	if len(scanner.Data) != td.numData || len(scanner.Info) != numExif {
+		t.Errorf("Expected %d files and %d info got %d and %d\n",
+			td.numData, numExif, len(scanner.Data), len(scanner.Info))
+	}
+
+	// The time is read again when the directory is not enough.
+	tdYear := newTestDir(t, MethodYear, fileNoDefault)
+	yearSorted := tdYear.buildSortedDir(src, "year_sorted_", ActionCopy)
+
+	defer os.RemoveAll(yearSorted)
+
+	scanner = NewScanner()
+
+	method, err = scanner.ScanSorted(yearSorted, MethodDay, false, NopObserver{})
+	if err != nil || method != MethodYear {
+		t.Fatalf("Expected year got %s err %v\n", method, err)
+	}
+
+	if len(scanner.Info) != numExif {
+		t.Errorf("Expected %d files read got %d\n", numExif, len(scanner.Info))
+	}
+}
+
+func TestResortCatalogTimes(t *testing.T) {
+	t.Parallel()
+
+	td := newTestDir(t, MethodDay, fileNoDefault)
+	src := td.buildRoot()
+	sorted := td.buildSortedDir(src, "sorted_", ActionCopy)
+
+	defer os.RemoveAll(src)
+	defer os.RemoveAll(sorted)
+
+	dst, _ := ioutil.TempDir("", "resorted_")
+	defer os.RemoveAll(dst)
+
+	c, err := OpenCatalog(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	scanner := NewScanner()
+	_, _ = scanner.ScanSorted(sorted, MethodMonth, false, NopObserver{})
+
+	sorter, err := NewSorter(scanner, MethodMonth, WithCatalog(c))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = sorter.Transfer(dst, ActionCopy, NopObserver{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := c.Entries()
+	if err != nil || len(entries) == 0 {
+		t.Fatalf("Expected entries got %d err %v\n", len(entries), err)
+	}
+
+	// The directory only told the day, not the time of the media.
+	for _, entry := range entries {
+		if !entry.Time.IsZero() {
+			t.Errorf("%s: expected no time got %s\n", entry.Path, entry.Time)
+		}
+	}
+}
+
+func TestScanSortedInPlace(t *testing.T) {
+	t.Parallel()
+
+	td := newTestDir(t, MethodDay, fileNoDefault)
+	src := td.buildRoot()
+	sorted := td.buildSortedDir(src, "sorted_", ActionCopy)
+
+	defer os.RemoveAll(src)
+	defer os.RemoveAll(sorted)
+
+	scanner := NewScanner()
+	_, _ = scanner.ScanSorted(sorted, MethodYear, false, NopObserver{})
+
+	sorter, err := NewSorter(scanner, MethodYear)
+	if err != nil {
+		t.Fatalf("Unexpected error %s\n", err.Error())
+	}
+
+	err = sorter.Transfer(sorted, ActionMove, NopObserver{})
+	if err != nil {
+		t.Fatalf("Unexpected error %s\n", err.Error())
+	}
+
+	moved := make([]string, 0, len(scanner.Data))
+	for path := range scanner.Data {
+		moved = append(moved, path)
+	}
+
+	removed, err := RemoveEmptyDirs(sorted, moved)
+	if err != nil || removed == 0 {
+		t.Errorf("Expected removed dirs got %d err %v\n", removed, err)
+	}
+
+	method, err := mergeCheck(sorted, NewExtensions(), NewRules())
+	if err != nil || method != MethodYear {
+		t.Errorf("Expected year got %s err %v\n", method, err)
+	}
+
+	err = countFiles(t, sorted, td.numData, "Resorted")
+	if err != nil {
+		t.Errorf("%s\n", err.Error())
+	}
+}
+
+func TestResortInPlaceKeepsSorted(t *testing.T) {
+	t.Parallel()
+
+	td := newTestDir(t, MethodYear, fileNoDefault)
+	src := td.buildRoot()
+	sorted := td.buildSortedDir(src, "sorted_", ActionCopy)
+
+	defer os.RemoveAll(src)
+	defer os.RemoveAll(sorted)
+
+	// Media without a place is already where the location layout puts it
+	// and the second time around all of it is.
+	for i := 0; i < 2; i++ {
+		scanner := NewScanner()
+		_, _ = scanner.ScanSorted(sorted, MethodYear, true, NopObserver{})
+
+		sorter, err := NewSorter(scanner, MethodYear, WithLayout(LayoutLocation))
+		if err != nil {
+			t.Fatalf("Unexpected error %s\n", err.Error())
+		}
+
+		err = sorter.Transfer(sorted, ActionMove, NopObserver{})
+		if err != nil {
+			t.Fatalf("Unexpected error %s\n", err.Error())
+		}
+
+		if len(sorter.Duplicates) != 0 || len(sorter.TransferErrors) != 0 {
+			t.Errorf("%d: expected nothing removed got %v and %v\n", i,
+				sorter.Duplicates, sorter.TransferErrors)
+		}
+
+		err = countFiles(t, sorted, td.numData, "Resorted")
+		if err != nil {
+			t.Errorf("%d: %s\n", i, err.Error())
+		}
+	}
+}
+
+func TestScanSortedBad(t *testing.T) {
+	t.Parallel()
+
+	td := newTestDir(t, MethodDay, fileNoDefault)
+	src := td.buildRoot()
+
+	defer os.RemoveAll(src)
+
+	scanner := NewScanner()
+
+	_, err := scanner.ScanSorted(src, MethodYear, false, NopObserver{})
+
+	var dirErr *InvalidDirError
+	if !errors.As(err, &dirErr) {
+		t.Errorf("Expected InvalidDirError got %v\n", err)
+	}
+}
+
+func TestRemoveEmptyDirs(t *testing.T) {
+	root, err := ioutil.TempDir("", "RemoveEmptyDirs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	empty := filepath.Join(root, "a", "b", "c")
+	full := filepath.Join(root, "d", "e")
+	untouched := filepath.Join(root, "f")
+
+	_ = os.MkdirAll(empty, 0755)
+	_ = os.MkdirAll(full, 0755)
+	_ = os.MkdirAll(untouched, 0755)
+	_ = ioutil.WriteFile(filepath.Join(full, "x.jpg"), []byte("x"), 0600)
+
+	// The media moved out of empty and full, and out of root itself.
+	moved := []string{
+		filepath.Join(empty, "x.jpg"),
+		filepath.Join(empty, "y.jpg"),
+		filepath.Join(full, "y.jpg"),
+		filepath.Join(root, "z.jpg"),
+	}
+
+	removed, err := RemoveEmptyDirs(root, moved)
+	if err != nil || removed != 3 {
+		t.Errorf("Expected 3 removed got %d err %v\n", removed, err)
+	}
+
+	if !exists(full) || exists(filepath.Join(root, "a")) || !exists(root) ||
+		!exists(untouched) {
+		t.Errorf("Removed the wrong directories\n")
+	}
+}
@@ -0,0 +1,326 @@
+package exifsort
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// IgnoreFile is the name of the file that holds rules for the directory it is
+// in and everything below it.
+const IgnoreFile = ".exifsortignore"
+
+// The rule presets.
+const (
+	// PresetSynology : Synology metadata such as @eaDir
+	PresetSynology = "synology"
+	// PresetMacOS : Finder metadata such as .DS_Store and ._ files
+	PresetMacOS = "macos"
+	// PresetWindows : Explorer metadata such as Thumbs.db
+	PresetWindows = "windows"
+	// PresetPhotos : Internals of Photos and iPhoto libraries
+	PresetPhotos = "photos"
+)
+
+// RulePresets returns the names of the rule presets.
+func RulePresets() []string {
+	return []string{PresetSynology, PresetMacOS, PresetWindows, PresetPhotos}
+}
+
+func rulePresetPatterns(name string) ([]string, error) {
+	switch strings.ToLower(name) {
+	case PresetSynology:
+		patterns := make([]string, 0, len(SynologySkip()))
+		for _, str := range SynologySkip() {
+			patterns = append(patterns, "*"+str+"*")
+		}
+
+		return patterns, nil
+	case PresetMacOS:
+		return []string{".DS_Store", "._*", ".AppleDouble/", ".AppleDB",
+			".AppleDesktop", ".Spotlight-V100/", ".Trashes/", ".fseventsd/",
+			".TemporaryItems/", "Icon\r"}, nil
+	case PresetWindows:
+		return []string{"Thumbs.db", "ehthumbs.db", "desktop.ini",
+			"$RECYCLE.BIN/", "System Volume Information/"}, nil
+	case PresetPhotos:
+		return []string{"**/*.photoslibrary/database/",
+			"**/*.photoslibrary/private/", "**/*.photoslibrary/resources/",
+			"**/*.photoslibrary/scopes/", "**/*.photolibrary/Thumbnails/"}, nil
+	default:
+		return nil, fmt.Errorf("invalid preset %s", name)
+	}
+}
+
+// rulePattern is one line of rules.
+type rulePattern struct {
+	base     string   // Directory the pattern is relative to, "" is the root
+	segments []string // The pattern split by "/"
+	negate   bool     // Matching paths are included again
+	dirOnly  bool     // Only matches directories
+	fold     bool     // Case does not matter
+}
+
+func newRulePattern(base string, line string, fold bool) (rulePattern, bool) {
+	p := rulePattern{base: base, fold: fold}
+
+	line = strings.TrimRight(line, " ")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return p, false
+	}
+
+	if strings.HasPrefix(line, "!") {
+		p.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\`) {
+		// Escapes a leading "!" or "#"
+		line = line[1:]
+	}
+
+	if strings.HasSuffix(line, "/") {
+		p.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+
+	// A pattern with a "/" before its end only matches from its base.
+	// Otherwise it matches a name at any depth.
+	if !strings.Contains(line, "/") {
+		line = "**/" + line
+	}
+
+	line = strings.TrimPrefix(line, "/")
+	if line == "" {
+		return p, false
+	}
+
+	if fold {
+		line = strings.ToLower(line)
+	}
+
+	p.segments = strings.Split(line, "/")
+
+	return p, true
+}
+
+func segmentsMatch(patterns []string, names []string) bool {
+	if len(patterns) == 0 {
+		return len(names) == 0
+	}
+
+	if patterns[0] == "**" {
+		// Matches any number of directories including none.
+		for ii := 0; ii <= len(names); ii++ {
+			if segmentsMatch(patterns[1:], names[ii:]) {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	if len(names) == 0 {
+		return false
+	}
+
+	matched, err := path.Match(patterns[0], names[0])
+	if err != nil || !matched {
+		return false
+	}
+
+	return segmentsMatch(patterns[1:], names[1:])
+}
+
+// match reports if rel, a "/" separated path relative to the root, matches.
+func (p rulePattern) match(rel string, isDir bool) bool {
+	if p.dirOnly && !isDir {
+		return false
+	}
+
+	if p.base != "" {
+		if !strings.HasPrefix(rel, p.base+"/") {
+			return false
+		}
+
+		rel = strings.TrimPrefix(rel, p.base+"/")
+	}
+
+	if p.fold {
+		rel = strings.ToLower(rel)
+	}
+
+	return segmentsMatch(p.segments, strings.Split(rel, "/"))
+}
+
+// Rules decide which files and directories are left out of scanning and
+// merging. They follow .gitignore: "*", "?" and "[...]" match within a name,
+// "**" matches any number of directories, a leading "!" includes what an
+// earlier rule excluded, a trailing "/" only matches directories and a rule
+// containing "/" is relative to the root rather than matching at any depth.
+// The last rule to match a path decides. Nothing under an excluded directory
+// can be included again as it is never read.
+//
+// Presets come first, then IgnoreFile files found while walking and last the
+// rules added with Exclude and Include. Presets are case insensitive, other
+// rules are not.
+type Rules struct {
+	presets []rulePattern
+	files   []rulePattern
+	rules   []rulePattern
+}
+
+// NewRules returns Rules with the synology preset, which exifsort has always
+// skipped.
+func NewRules() *Rules {
+	var r Rules
+
+	// The preset exists so there is no error.
+	_ = r.AddPreset(PresetSynology)
+
+	return &r
+}
+
+// AddPreset adds the rules of a preset named by RulePresets. The name is case
+// insensitive.
+func (r *Rules) AddPreset(name string) error {
+	patterns, err := rulePresetPatterns(name)
+	if err != nil {
+		return err
+	}
+
+	for _, line := range patterns {
+		p, ok := newRulePattern("", line, true)
+		if ok {
+			r.presets = append(r.presets, p)
+		}
+	}
+
+	return nil
+}
+
+// Exclude adds a rule in .gitignore syntax.
+func (r *Rules) Exclude(pattern string) error {
+	p, ok := newRulePattern("", pattern, false)
+	if !ok {
+		return fmt.Errorf("invalid rule %q", pattern)
+	}
+
+	r.rules = append(r.rules, p)
+
+	return nil
+}
+
+// Include adds a rule that includes what matches pattern again.
+func (r *Rules) Include(pattern string) error {
+	return r.Exclude("!" + pattern)
+}
+
+// matchLast reports if the last pattern to match rel excludes it.
+func (r *Rules) matchLast(rel string, isDir bool) bool {
+	excluded := false
+
+	for _, patterns := range [][]rulePattern{r.presets, r.files, r.rules} {
+		for _, p := range patterns {
+			if p.match(rel, isDir) {
+				excluded = !p.negate
+			}
+		}
+	}
+
+	return excluded
+}
+
+// Excluded reports if path, relative to the root the rules apply to, is
+// excluded. IgnoreFile files are only read by Walk.
+func (r *Rules) Excluded(path string, isDir bool) bool {
+	rel := filepath.ToSlash(filepath.Clean(path))
+	names := strings.Split(rel, "/")
+
+	for ii := 1; ii < len(names); ii++ {
+		if r.matchLast(strings.Join(names[:ii], "/"), true) {
+			return true
+		}
+	}
+
+	return r.matchLast(rel, isDir)
+}
+
+func (r *Rules) readIgnoreFile(dir string, rel string) error {
+	file, err := os.Open(filepath.Join(dir, IgnoreFile))
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if rel == "." {
+		rel = ""
+	}
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		p, ok := newRulePattern(rel, scanner.Text(), false)
+		if ok {
+			r.files = append(r.files, p)
+		}
+	}
+
+	return scanner.Err()
+}
+
+// Walk walks root like filepath.Walk except that walkFn is not called for
+// excluded files or IgnoreFile files and excluded directories are not
+// descended into.
+func (r *Rules) Walk(root string, walkFn filepath.WalkFunc) error {
+	// Rules from the files only last as long as the walk.
+	w := *r
+	w.files = nil
+
+	return filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return walkFn(path, info, err)
+		}
+
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return walkFn(path, info, err)
+		}
+
+		rel = filepath.ToSlash(rel)
+
+		if rel != "." && w.matchLast(rel, info.IsDir()) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+
+			return nil
+		}
+
+		if !info.IsDir() && info.Name() == IgnoreFile {
+			return nil
+		}
+
+		if info.IsDir() {
+			err = w.readIgnoreFile(path, rel)
+			if err != nil {
+				return walkFn(path, info, err)
+			}
+		}
+
+		return walkFn(path, info, nil)
+	})
+}
+
+// rulesOrDefault lets callers leave their Rules nil for defaults.
+func rulesOrDefault(r *Rules) *Rules {
+	if r == nil {
+		return NewRules()
+	}
+
+	return r
+}
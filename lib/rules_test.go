@@ -0,0 +1,225 @@
+package exifsort
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestRulesSynology(t *testing.T) {
+	badInput := []string{
+		".DS_Store@SynoResource",
+		"2019@SynoEAStream",
+		"PSD_Work/@eaDir/",
+		"@eaDir",
+		"@syno",
+		"IMG_0269.JPG/SYNOFILE_THUMB_M_r1.jpg",
+	}
+
+	r := NewRules()
+
+	for _, input := range badInput {
+		if !r.Excluded(input, false) {
+			t.Errorf("Expected to skip for %s\n", input)
+		}
+	}
+
+	if r.Excluded("2019/IMG_0269.JPG", false) {
+		t.Errorf("Expected not to skip a photo\n")
+	}
+
+	var none Rules
+	if none.Excluded("@eaDir", true) {
+		t.Errorf("Expected empty rules to skip nothing\n")
+	}
+}
+
+func TestRulesPresets(t *testing.T) {
+	var r Rules
+
+	for _, preset := range RulePresets() {
+		err := r.AddPreset(preset)
+		if err != nil {
+			t.Fatalf("Unexpected error %s\n", err.Error())
+		}
+	}
+
+	if r.AddPreset("bogus") == nil {
+		t.Errorf("Expected error for bogus preset\n")
+	}
+
+	excluded := map[string]bool{
+		".DS_Store":                   false,
+		"2020/._IMG_1.JPG":            false,
+		"vacation/.AppleDouble":       true,
+		"2020/thumbs.db":              false,
+		"$RECYCLE.BIN":                true,
+		"My.photoslibrary/resources":  true,
+		"a/My.photoslibrary/Database": true,
+	}
+
+	for path, isDir := range excluded {
+		if !r.Excluded(path, isDir) {
+			t.Errorf("Expected %s to be excluded\n", path)
+		}
+	}
+
+	if r.Excluded("My.photoslibrary/originals/1.jpg", false) {
+		t.Errorf("Expected photos library originals to be included\n")
+	}
+}
+
+func TestRulesPatterns(t *testing.T) {
+	var r Rules
+
+	for _, rule := range []string{"*.tmp", "/raw/", "**/cache/**",
+		"edits/*.psd", "!keep.tmp", "# comment", `\#hash`} {
+		_ = r.Exclude(rule)
+	}
+
+	err := r.Include("raw/best")
+	if err != nil {
+		t.Fatalf("Unexpected error %s\n", err.Error())
+	}
+
+	expected := map[string]bool{
+		"a.tmp":            true,
+		"deep/down/a.tmp":  true,
+		"keep.tmp":         false,
+		"deep/keep.tmp":    false,
+		"raw":              true,
+		"raw/a.jpg":        true,
+		"raw/best":         true, // Parent is excluded
+		"deep/raw":         false,
+		"x/cache/y/a.jpg":  true,
+		"edits/a.psd":      true,
+		"deep/edits/a.psd": false,
+		"#hash":            true,
+		"a.TMP":            false,
+		"photos/a.jpg":     false,
+	}
+
+	for path, want := range expected {
+		isDir := filepath.Ext(path) == ""
+		if r.Excluded(path, isDir) != want {
+			t.Errorf("Expected excluded %t for %s\n", want, path)
+		}
+	}
+
+	if r.Exclude("") == nil || r.Exclude("# only a comment") == nil {
+		t.Errorf("Expected error for empty rules\n")
+	}
+}
+
+func testWriteFiles(t *testing.T, root string, files map[string]string) {
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+
+		err := os.MkdirAll(filepath.Dir(path), 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = ioutil.WriteFile(path, []byte(content), 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestRulesWalk(t *testing.T) {
+	root, err := ioutil.TempDir("", "RulesWalk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	testWriteFiles(t, root, map[string]string{
+		"a.jpg":               "",
+		"@eaDir/a.jpg":        "",
+		"trip/" + IgnoreFile:  "*.png\n!keep.png\nscratch/\n",
+		"trip/b.png":          "",
+		"trip/keep.png":       "",
+		"trip/scratch/c.jpg":  "",
+		"trip/day/d.png":      "",
+		"trip/day/e.jpg":      "",
+		"other/f.png":         "",
+		"other/scratch/g.jpg": "",
+		"skipme/h.jpg":        "",
+	})
+
+	r := NewRules()
+	_ = r.Exclude("skipme/")
+
+	var visited []string
+
+	err = r.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() && path != root && info.Name() == "@eaDir" {
+			t.Errorf("Walked into %s\n", path)
+		}
+
+		if !info.IsDir() {
+			rel, _ := filepath.Rel(root, path)
+			visited = append(visited, filepath.ToSlash(rel))
+		}
+
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error %s\n", err.Error())
+	}
+
+	sort.Strings(visited)
+
+	expected := []string{
+		"a.jpg",
+		"other/f.png",
+		"other/scratch/g.jpg",
+		"trip/day/e.jpg",
+		"trip/keep.png",
+	}
+
+	if !cmp.Equal(visited, expected) {
+		t.Errorf("Expected %v got %v\n", expected, visited)
+	}
+}
+
+func TestRulesScan(t *testing.T) {
+	t.Parallel()
+	td := newTestDir(t, MethodNone, fileNoDefault)
+
+	tmpPath := td.buildRoot()
+	defer os.RemoveAll(tmpPath)
+
+	synologyDir := filepath.Join(tmpPath, "@eaDir")
+
+	err := os.Mkdir(synologyDir, 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Not counted at all since we never look inside.
+	td.populateFiles(synologyDir, 4, exifPath, exifPath)
+
+	s := NewScanner()
+	_ = s.ScanDir(tmpPath, NopObserver{})
+
+	testCheckScanCounts(t, td, s)
+
+	s = NewScanner()
+	s.Rules = &Rules{}
+	_ = s.ScanDir(tmpPath, NopObserver{})
+
+	if len(s.Data) != td.numData+4 {
+		t.Errorf("Expected %d data without rules got %d\n",
+			td.numData+4, len(s.Data))
+	}
+}
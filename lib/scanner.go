@@ -3,11 +3,15 @@ package exifsort
 import (
 	"encoding/json"
 	"fmt"
-	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
 	"time"
+
+	"github.com/dsoprea/go-exif/v2"
 )
 
 // ScannerInput specifies how scanner receives data
@@ -22,22 +26,137 @@ const (
 	ScannerInputNone
 )
 
+// TimeSource tells where the time of a media file came from.
+type TimeSource int
+
+const (
+	// TimeSourceExif : the exif data of an image
+	TimeSourceExif TimeSource = iota
+	// TimeSourceMovie : the metadata of a movie
+	TimeSourceMovie
+	// TimeSourceModTime : the modtime of the file, exif or movie metadata
+	// could not be read or the file has none
+	TimeSourceModTime
+	// TimeSourcePath : the directory of a sorted file, only as fine as the
+	// method it is resorted by needs
+	TimeSourcePath
+	// TimeSourceNone : Error Value
+	TimeSourceNone
+)
+
+// Returns name of time source value (all lower case).
+func (t TimeSource) String() string {
+	return [...]string{"exif", "movie", "modtime", "path", "none"}[t]
+}
+
+// MarshalText saves time sources by name.
+func (t TimeSource) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+// UnmarshalText reads time sources saved by name.
+func (t *TimeSource) UnmarshalText(text []byte) error {
+	var err error
+
+	*t, err = TimeSourceParse(string(text))
+
+	return err
+}
+
+// TimeSources returns all time source values used excluding TimeSourceNone.
+func TimeSources() []TimeSource {
+	return []TimeSource{
+		TimeSourceExif,
+		TimeSourceMovie,
+		TimeSourceModTime,
+		TimeSourcePath,
+	}
+}
+
+// TimeSourceParse returns TimeSource from string (must be lower case).
+// Returns TimeSourceNone if invalid.
+func TimeSourceParse(str string) (TimeSource, error) {
+	for _, val := range TimeSources() {
+		if str == val.String() {
+			return val, nil
+		}
+	}
+
+	return TimeSourceNone, fmt.Errorf("invalid time source %s", str)
+}
+
 // Scanner is your API to scan directory of media.
 //
 // It holds errors and data results of the scan after scanning.
 type Scanner struct {
+	// Jobs is the number of files read at the same time by ScanDir. It is
+	// not saved.
+	Jobs int `json:"-"`
+	// Extensions decides how each file is scanned. Nil means
+	// NewExtensions(). It is not saved.
+	Extensions Extensions `json:"-"`
+	// Rules leave files and directories out of ScanDir. Nil means
+	// NewRules(). It is not saved.
+	Rules *Rules `json:"-"`
+	// Filters leave files outside the limits of their category out of
+	// ScanDir. Nil means no limits. It is not saved.
+	Filters Filters `json:"-"`
+	// Sniff makes ScanDir read the start of every file to tell its type
+	// instead of trusting its extension. It is not saved.
+	Sniff bool `json:"-"`
+	// PHash makes ScanDir decode JPEG and PNG images and store their
+	// perceptual hash in Hashes. It is not saved.
+	PHash             bool `json:"-"`
 	Input             ScannerInput
 	SkippedCount      int
+	NumSkippedTypes   map[string]int
+	Filtered          map[string]string
 	Data              map[string]time.Time
 	NumDataTypes      map[string]int
 	ExifErrors        map[string]string
 	NumExifErrorTypes map[string]int
 	ScanErrors        map[string]string
+	// Info holds the device and coordinates of the files in Data whose
+	// exif data has them.
+	Info map[string]MediaInfo
+	// Mismatches holds the files whose contents disagree with their
+	// extension, and the extension of their contents, when sniffing.
+	Mismatches map[string]string
+	// Hashes holds the perceptual hash of the images in Data when PHash is
+	// set.
+	Hashes map[string]ImageHash
+	// Sources holds where the time of the files in Data came from. Use
+	// Source, scans saved before it was added don't have it.
+	Sources map[string]TimeSource
 }
 
-// NumTotal returns the total number of files skipped, scanned and errors.
+// Source returns where the time of path in Data came from.
+func (s *Scanner) Source(path string) TimeSource {
+	source, present := s.Sources[path]
+	if present {
+		return source
+	}
+
+	_, exifErr := s.ExifErrors[path]
+
+	switch extensionsOrDefault(s.Extensions).Category(path) {
+	case CategoryExif:
+		if !exifErr {
+			return TimeSourceExif
+		}
+	case CategoryMovie:
+		if !exifErr {
+			return TimeSourceMovie
+		}
+	}
+
+	return TimeSourceModTime
+}
+
+// NumTotal returns the total number of files skipped, filtered, scanned and
+// errors.
 func (s *Scanner) NumTotal() int {
-	return s.SkippedCount + len(s.Data) + len(s.ScanErrors)
+	return s.SkippedCount + len(s.Filtered) + len(s.Data) + len(s.ScanErrors)
 }
 
 // We don't check if you have a path duplicate.
@@ -68,12 +187,45 @@ func (s *Scanner) storeExifError(path string, err error) {
 	}
 }
 
+func (s *Scanner) storeInfo(path string, info MediaInfo) {
+	if info.IsZero() {
+		return
+	}
+
+	s.Info[path] = info
+}
+
+func (s *Scanner) storeHash(path string, hash ImageHash) {
+	if hash.IsZero() {
+		return
+	}
+
+	s.Hashes[path] = hash
+}
+
+func (s *Scanner) storeFiltered(path string, reason string) {
+	s.Filtered[path] = reason
+}
+
+func (s *Scanner) storeMismatch(path string, extension string) {
+	s.Mismatches[path] = extension
+}
+
 func (s *Scanner) storeScanError(path string, err error) {
 	s.ScanErrors[path] = err.Error()
 }
 
-func (s *Scanner) storeSkipped() {
+func (s *Scanner) storeSkipped(path string) {
 	s.SkippedCount++
+
+	extension := filepath.Ext(path)
+
+	_, present := s.NumSkippedTypes[extension]
+	if present {
+		s.NumSkippedTypes[extension]++
+	} else {
+		s.NumSkippedTypes[extension] = 1
+	}
 }
 
 func exifTimeToStr(t time.Time) string {
@@ -106,77 +258,313 @@ func (s *Scanner) modTime(path string) (time.Time, error) {
 //
 // It returns an error if the file has no exif data and cannot be statted.
 func (s *Scanner) ScanFile(path string) (time.Time, error) {
-	time, err := ExifTimeGet(path)
+	result := s.scanTime(path, ExifTimeGet)
+	if result.exifErr != nil {
+		s.storeExifError(path, result.exifErr)
+	}
+
+	return result.time, result.err
+}
+
+// scanResult holds what we learned about one path. They are computed
+// concurrently and stored by one goroutine.
+type scanResult struct {
+	path     string
+	skipped  bool
+	sidecar  bool
+	filtered string
+	mismatch string
+	time     time.Time
+	info     MediaInfo
+	hash     ImageHash
+	source   TimeSource
+	exifErr  error
+	err      error
+}
+
+// scanTime reads the time of path with timeGet or else its modtime.
+func (s *Scanner) scanTime(path string,
+	timeGet func(path string) (time.Time, error)) scanResult {
+	result := scanResult{path: path, source: TimeSourceMovie}
+
+	result.time, result.exifErr = timeGet(path)
+	if result.exifErr != nil {
+		result.source = TimeSourceModTime
+		result.time, result.err = s.modTime(path)
+	}
+
+	return result
+}
+
+// scanExif reads the time and device of path from the root IFD rootIfdGet
+// finds, or else its modtime.
+func (s *Scanner) scanExif(path string,
+	rootIfdGet func(path string) (*exif.Ifd, error)) scanResult {
+	result := scanResult{path: path}
+
+	rootIfd, err := rootIfdGet(path)
+	if err == nil {
+		result.info = exifInfoFromRootIfd(rootIfd)
+		result.time, err = exifTimeFromRootIfd(rootIfd)
+	}
+
 	if err != nil {
-		s.storeExifError(path, err)
-		return s.modTime(path)
+		result.exifErr = err
+		result.source = TimeSourceModTime
+		result.time, result.err = s.modTime(path)
 	}
 
-	return time, nil
+	return result
 }
 
-func (s *Scanner) scanFunc(logger io.Writer) filepath.WalkFunc {
-	return func(path string, info os.FileInfo, err error) error {
-		var time time.Time
+func (s *Scanner) scanPath(path string, exts Extensions) scanResult {
+	var (
+		result   scanResult
+		mismatch string
+	)
 
-		if err != nil {
-			s.storeScanError(path, err)
-			fmt.Fprintf(logger, "Error: %s: (%s)\n", path, err.Error())
+	category := exts.Category(path)
+	if s.Sniff {
+		category, mismatch = sniffCategory(path, exts)
+	}
 
-			return nil
+	if category != CategorySkip {
+		filtered := s.Filters.filtered(path, category)
+		if filtered != "" {
+			return scanResult{path: path, filtered: filtered, mismatch: mismatch}
 		}
+	}
 
-		// Don't need to scan directories
-		if info.IsDir() {
-			return nil
+	switch category {
+	case CategorySkip:
+		result = scanResult{path: path, skipped: true}
+	case CategoryExif:
+		if mismatch != "" {
+			// The exif reader would trust the extension.
+			result = s.scanExif(path, exifRootIfdSearch)
+		} else {
+			result = s.scanExif(path, exifRootIfd)
 		}
+	case CategoryMovie:
+		result = s.scanTime(path, MovieTimeGet)
+	case CategoryModTime:
+		result = scanResult{path: path, source: TimeSourceModTime}
+		result.time, result.err = s.modTime(path)
+	case CategorySidecar:
+		// modtime is only used if we can't find the file it belongs to.
+		result = scanResult{path: path, sidecar: true, source: TimeSourceModTime}
+		result.time, result.err = s.modTime(path)
+	}
 
-		fileCategory := categorizeFile(path)
-		switch fileCategory {
-		case categorySkip:
-			s.storeSkipped()
-			return nil
-		case categoryExif:
-			time, err = s.ScanFile(path)
-		case categoryModTime:
-			time, err = s.modTime(path)
+	result.mismatch = mismatch
+
+	// Images that can't be decoded are still sorted, they just have no
+	// hash to find near duplicates with.
+	isImage := category == CategoryExif || category == CategoryModTime
+	if s.PHash && isImage && result.err == nil {
+		result.hash, _ = ImageHashGet(path)
+	}
+
+	return result
+}
+
+// sidecarKeys returns the names a sidecar of path may have without its own
+// extension. IMG_1.JPG may have IMG_1.xmp or IMG_1.JPG.xmp.
+func sidecarKeys(path string) []string {
+	path = strings.ToLower(path)
+	stem := strings.TrimSuffix(path, filepath.Ext(path))
+
+	return []string{stem, path}
+}
+
+// resolveSidecars gives each sidecar the time and device of the media file it
+// belongs to. Sidecars without one keep their modtime.
+func (s *Scanner) resolveSidecars(sidecars []scanResult, observer Observer) {
+	if len(sidecars) == 0 {
+		return
+	}
+
+	paths := make([]string, 0, len(s.Data))
+	for path := range s.Data {
+		paths = append(paths, path)
+	}
+
+	// Sorted so the same media file wins every time.
+	sort.Strings(paths)
+
+	media := make(map[string]string)
+
+	for _, path := range paths {
+		for _, key := range sidecarKeys(path) {
+			if _, present := media[key]; !present {
+				media[key] = path
+			}
 		}
+	}
+
+	for _, result := range sidecars {
+		result.sidecar = false
+		key := sidecarKeys(result.path)[0]
 
+		if path, present := media[key]; present {
+			result.time, result.err = s.Data[path], nil
+			result.info = s.Info[path]
+			result.source = s.Sources[path]
+		}
+
+		s.storeResult(result, observer)
+	}
+}
+
+func (s *Scanner) storeResult(result scanResult, observer Observer) {
+	path := result.path
+
+	if result.mismatch != "" {
+		s.storeMismatch(path, result.mismatch)
+	}
+
+	if result.filtered != "" {
+		s.storeFiltered(path, result.filtered)
+		observer.Skipped(path)
+
+		return
+	}
+
+	if result.skipped {
+		s.storeSkipped(path)
+		observer.Skipped(path)
+
+		return
+	}
+
+	if result.exifErr != nil {
+		s.storeExifError(path, result.exifErr)
+		observer.ExifError(path, result.exifErr)
+	}
+
+	if result.err != nil {
+		s.storeScanError(path, result.err)
+		observer.Error(path, result.err)
+
+		return
+	}
+
+	s.storeData(path, result.time)
+	s.storeInfo(path, result.info)
+	s.Sources[path] = result.source
+	s.storeHash(path, result.hash)
+	observer.FileScanned(path, result.time)
+}
+
+func (s *Scanner) scanFunc(paths chan<- string, results chan<- scanResult) filepath.WalkFunc {
+	return func(path string, info os.FileInfo, err error) error {
 		if err != nil {
-			s.storeScanError(path, err)
-			fmt.Fprintf(logger, "Error: %s: (%s)\n", path, err.Error())
-		} else {
-			s.storeData(path, time)
-			fmt.Fprintf(logger, "%s, %s\n", path, exifTimeToStr(time))
+			results <- scanResult{path: path, err: err}
+			return nil
 		}
 
+		// Don't need to scan directories
+		if info.IsDir() {
+			return nil
+		}
+
+		paths <- path
+
 		return nil
 	}
 }
 
+func (s *Scanner) numJobs() int {
+	if s.Jobs < 1 {
+		return 1
+	}
+
+	return s.Jobs
+}
+
 // ScanDir will examine the contents of every file in the src directory and
 // print it's time of creation as stored by exifdata as it scans.
 //
-// ScanDir only scans media files listed as constants as documented, other
-// files are skipped.
+// ScanDir only scans files whose extension is in Extensions, other files are
+// skipped. Files outside the limits of Filters are stored in Filtered. Files
+// and directories excluded by Rules are left out entirely. Sidecars take the
+// time of the media file they belong to. Up to Jobs files are read at the
+// same time.
 //
-// logger specifies where to send output while scanning.
-func (s *Scanner) ScanDir(src string, logger io.Writer) error {
+// observer receives the events generated while scanning. Its methods are
+// never called concurrently.
+func (s *Scanner) ScanDir(src string, observer Observer) error {
 	s.Input = ScannerInputDir
 
 	info, err := os.Stat(src)
-	if err != nil || !info.IsDir() {
+	if err != nil {
 		return err
 	}
 
-	// scanFunc never returns an error
-	// We don't want to walk for an hour and then fail on one error.
-	// Consult the walkstate for errors.
-	_ = filepath.Walk(src, s.scanFunc(logger))
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", src)
+	}
+
+	exts := extensionsOrDefault(s.Extensions)
+
+	s.scan(exts, observer, func(paths chan<- string, results chan<- scanResult) {
+		// scanFunc never returns an error
+		// We don't want to walk for an hour and then fail on one error.
+		// Consult the walkstate for errors.
+		_ = rulesOrDefault(s.Rules).Walk(src, s.scanFunc(paths, results))
+	})
 
 	return nil
 }
 
+// scan reads the paths walk sends to paths with Jobs goroutines and stores
+// their results, and the results walk sends itself, then resolves sidecars.
+func (s *Scanner) scan(exts Extensions, observer Observer,
+	walk func(paths chan<- string, results chan<- scanResult)) {
+	paths := make(chan string)
+	results := make(chan scanResult)
+	stored := make(chan struct{})
+
+	// Sidecars wait until every other file is stored.
+	var sidecars []scanResult
+
+	// Only this goroutine touches our maps.
+	go func() {
+		for result := range results {
+			if result.sidecar {
+				sidecars = append(sidecars, result)
+				continue
+			}
+
+			s.storeResult(result, observer)
+		}
+		close(stored)
+	}()
+
+	var workers sync.WaitGroup
+
+	for ii := 0; ii < s.numJobs(); ii++ {
+		workers.Add(1)
+
+		go func() {
+			defer workers.Done()
+
+			for path := range paths {
+				results <- s.scanPath(path, exts)
+			}
+		}()
+	}
+
+	walk(paths, results)
+
+	close(paths)
+	workers.Wait()
+	close(results)
+	<-stored
+
+	s.resolveSidecars(sidecars, observer)
+}
+
 // Save Scanner to a json file.
 func (s *Scanner) Save(jsonPath string) error {
 	json, err := json.MarshalIndent(s, "", "\t")
@@ -213,11 +601,17 @@ func (s *Scanner) Load(jsonPath string) error {
 func (s *Scanner) Reset() {
 	s.Input = ScannerInputNone
 	s.SkippedCount = 0
+	s.NumSkippedTypes = make(map[string]int)
+	s.Filtered = make(map[string]string)
 	s.Data = make(map[string]time.Time)
 	s.NumDataTypes = make(map[string]int)
 	s.ExifErrors = make(map[string]string)
 	s.NumExifErrorTypes = make(map[string]int)
 	s.ScanErrors = make(map[string]string)
+	s.Mismatches = make(map[string]string)
+	s.Info = make(map[string]MediaInfo)
+	s.Hashes = make(map[string]ImageHash)
+	s.Sources = make(map[string]TimeSource)
 }
 
 // NewScanner allocates a new Scanner.
@@ -101,6 +101,16 @@ func testCheckScanCounts(t *testing.T, td *testdir, s Scanner) {
 			td.numSkipped, s.SkippedCount)
 	}
 
+	numSkippedTypes := 0
+	for _, num := range s.NumSkippedTypes {
+		numSkippedTypes += num
+	}
+
+	if numSkippedTypes != s.SkippedCount {
+		t.Errorf("Expected %d Skipped by type got %d\n",
+			s.SkippedCount, numSkippedTypes)
+	}
+
 	if td.numScanError != len(s.ScanErrors) {
 		t.Errorf("Expected %d ScanErrors got %d\n",
 			td.numScanError, len(s.ScanErrors))
@@ -119,11 +129,36 @@ func TestScanDir(t *testing.T) {
 	defer os.RemoveAll(tmpPath)
 
 	s := NewScanner()
-	_ = s.ScanDir(tmpPath, ioutil.Discard)
+	_ = s.ScanDir(tmpPath, NopObserver{})
 
 	testCheckScanCounts(t, td, s)
 }
 
+func TestScanDirJobs(t *testing.T) {
+	t.Parallel()
+	td := newTestDir(t, MethodNone, fileNoDefault)
+
+	tmpPath := td.buildRoot()
+	defer os.RemoveAll(tmpPath)
+
+	td.populateSkipFiles(tmpPath, 5)
+
+	serial := NewScanner()
+	_ = serial.ScanDir(tmpPath, NopObserver{})
+
+	s := NewScanner()
+	s.Jobs = 4
+	_ = s.ScanDir(tmpPath, NopObserver{})
+
+	testCheckScanCounts(t, td, s)
+
+	// Jobs is not part of the results so clear it to compare.
+	s.Jobs = 0
+	if !cmp.Equal(s, serial) {
+		t.Errorf("Scanning with jobs does not match scanning serially\n")
+	}
+}
+
 func TestScanSkipDir(t *testing.T) {
 	t.Parallel()
 	td := newTestDir(t, MethodNone, fileNoDefault)
@@ -132,7 +167,7 @@ func TestScanSkipDir(t *testing.T) {
 	defer os.RemoveAll(tmpPath)
 
 	s := NewScanner()
-	_ = s.ScanDir(tmpPath, ioutil.Discard)
+	_ = s.ScanDir(tmpPath, NopObserver{})
 
 	testCheckScanCounts(t, td, s)
 }
@@ -150,7 +185,7 @@ func TestScanBadDir(t *testing.T) {
 	defer os.RemoveAll(tmpPath)
 
 	s := NewScanner()
-	_ = s.ScanDir(tmpPath, ioutil.Discard)
+	_ = s.ScanDir(tmpPath, NopObserver{})
 
 	testCheckScanCounts(t, td, s)
 }
@@ -166,7 +201,7 @@ func TestScanSaveLoad(t *testing.T) {
 	defer os.RemoveAll(jsonDir)
 
 	s := NewScanner()
-	_ = s.ScanDir(tmpPath, ioutil.Discard)
+	_ = s.ScanDir(tmpPath, NopObserver{})
 
 	jsonPath := filepath.Join(jsonDir, "scanned.json")
 
@@ -198,7 +233,7 @@ func TestScanBadSave(t *testing.T) {
 	defer os.RemoveAll(jsonDir)
 
 	s := NewScanner()
-	_ = s.ScanDir(tmpPath, ioutil.Discard)
+	_ = s.ScanDir(tmpPath, NopObserver{})
 
 	jsonPath := filepath.Join(jsonDir, "scanned.json")
 
@@ -226,7 +261,7 @@ func TestScanBadLoad(t *testing.T) {
 	defer os.RemoveAll(jsonDir)
 
 	s := NewScanner()
-	_ = s.ScanDir(tmpPath, ioutil.Discard)
+	_ = s.ScanDir(tmpPath, NopObserver{})
 
 	jsonPath := filepath.Join(jsonDir, "scanned.json")
 
@@ -243,3 +278,141 @@ func TestScanBadLoad(t *testing.T) {
 		t.Errorf("Unexpected Success from Load\n")
 	}
 }
+
+func TestScanNotDir(t *testing.T) {
+	s := NewScanner()
+
+	err := s.ScanDir(exifPath, NopObserver{})
+	if err == nil {
+		t.Errorf("Expected error scanning a file not a directory\n")
+	}
+}
+
+func TestScanExtensions(t *testing.T) {
+	t.Parallel()
+
+	tmpPath, err := ioutil.TempDir("", "ScanExtensions")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpPath)
+
+	td := newTestDir(t, MethodNone, fileNoDefault)
+	td.populateExifFiles(tmpPath, 3)
+	td.populateSkipFiles(tmpPath, 2)
+
+	s := NewScanner()
+	s.Extensions = NewExtensions()
+	s.Extensions.Remove(".jpg")
+	_ = s.ScanDir(tmpPath, NopObserver{})
+
+	if len(s.Data) != 0 || s.SkippedCount != 5 {
+		t.Errorf("Expected all files skipped got %d data %d skipped\n",
+			len(s.Data), s.SkippedCount)
+	}
+
+	if s.NumSkippedTypes[".jpg"] != 3 || s.NumSkippedTypes[".md"] != 2 {
+		t.Errorf("Unexpected skipped types %v\n", s.NumSkippedTypes)
+	}
+}
+
+func TestScanSidecar(t *testing.T) {
+	t.Parallel()
+
+	tmpPath, err := ioutil.TempDir("", "ScanSidecar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpPath)
+
+	content, err := ioutil.ReadFile(exifPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := map[string][]byte{
+		"IMG_1.JPG":     content,
+		"IMG_1.xmp":     []byte("xmp"),
+		"IMG_1.JPG.aae": []byte("aae"),
+		"lonely.xmp":    []byte("xmp"),
+	}
+
+	for name, data := range files {
+		err = ioutil.WriteFile(filepath.Join(tmpPath, name), data, 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s := NewScanner()
+	_ = s.ScanDir(tmpPath, NopObserver{})
+
+	media := s.Data[filepath.Join(tmpPath, "IMG_1.JPG")]
+
+	mediaInfo := s.Info[filepath.Join(tmpPath, "IMG_1.JPG")]
+
+	for _, name := range []string{"IMG_1.xmp", "IMG_1.JPG.aae"} {
+		sidecar := s.Data[filepath.Join(tmpPath, name)]
+		if !sidecar.Equal(media) {
+			t.Errorf("Expected %s time %s got %s\n", name, media, sidecar)
+		}
+
+		info := s.Info[filepath.Join(tmpPath, name)]
+		if info != mediaInfo {
+			t.Errorf("Expected %s info %v got %v\n", name, mediaInfo, info)
+		}
+	}
+
+	lonely := filepath.Join(tmpPath, "lonely.xmp")
+
+	modTime, err := testGetModTime(lonely)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !s.Data[lonely].Equal(modTime) {
+		t.Errorf("Expected lonely sidecar to have modtime %s got %s\n",
+			modTime, s.Data[lonely])
+	}
+}
+
+func TestScanInfo(t *testing.T) {
+	t.Parallel()
+
+	td := newTestDir(t, MethodNone, fileNoDefault)
+	root := td.buildRoot()
+
+	defer os.RemoveAll(root)
+
+	s := NewScanner()
+	_ = s.ScanDir(root, NopObserver{})
+
+	numExif := td.numData - td.numExifError
+	if len(s.Info) != numExif {
+		t.Fatalf("Expected %d files with info got %d\n", numExif, len(s.Info))
+	}
+
+	for path, info := range s.Info {
+		if info.Device() != "Apple iPhone 11 Pro" {
+			t.Errorf("%s: unexpected device %s\n", path, info.Device())
+		}
+	}
+
+	jsonPath := filepath.Join(root, "scan.json")
+
+	err := s.Save(jsonPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	loaded := NewScanner()
+
+	err = loaded.Load(jsonPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(loaded.Info) != numExif {
+		t.Errorf("Expected %d loaded files with info got %d\n", numExif, len(loaded.Info))
+	}
+}
@@ -0,0 +1,262 @@
+package exifsort
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Enough of the start of a file to recognize every format below. MPEG
+// transport streams repeat a sync byte every 188 bytes so we need two packets.
+const sniffLen = 512
+
+const (
+	tsPacketLen   = 188
+	m2tsPacketLen = 192
+	tsSync        = 0x47
+	bmffTypeStart = 4
+	bmffTypeEnd   = 8
+	bmffBrandEnd  = 12
+	riffTypeStart = 8
+	riffTypeEnd   = 12
+	tiffRawStart  = 8
+	tiffRawEnd    = 10
+)
+
+// The BMP file header is "BM", the file size, 4 reserved zero bytes and the
+// offset of the pixels. The size of the DIB header that follows tells its
+// version.
+const (
+	bmpSizeStart     = 2
+	bmpReservedStart = 6
+	bmpDIBStart      = 14
+	bmpHeaderEnd     = 18
+	bmpCoreHeader    = 12
+	bmpInfoHeader    = 40
+	bmpV4Header      = 108
+	bmpV5Header      = 124
+)
+
+// sniffFormat is a file format we recognize. ext is the extension it is
+// reported as and aliases are other extensions the format is fine to have.
+type sniffFormat struct {
+	ext     string
+	aliases []string
+	match   func(header []byte) bool
+}
+
+func sniffPrefix(prefix string) func(header []byte) bool {
+	return func(header []byte) bool {
+		return bytes.HasPrefix(header, []byte(prefix))
+	}
+}
+
+func sniffAt(header []byte, start int, end int, str string) bool {
+	return len(header) >= end && string(header[start:end]) == str
+}
+
+func sniffTIFF(header []byte) bool {
+	return sniffPrefix("II*\x00")(header) || sniffPrefix("MM\x00*")(header)
+}
+
+func sniffRIFF(kind string) func(header []byte) bool {
+	return func(header []byte) bool {
+		return sniffPrefix("RIFF")(header) &&
+			sniffAt(header, riffTypeStart, riffTypeEnd, kind)
+	}
+}
+
+// sniffBMP matches BMP files of size bytes. "BM" alone is too common a start.
+func sniffBMP(size int64) func(header []byte) bool {
+	return func(header []byte) bool {
+		if !sniffPrefix("BM")(header) || len(header) < bmpHeaderEnd {
+			return false
+		}
+
+		fileSize := binary.LittleEndian.Uint32(header[bmpSizeStart:])
+		reserved := binary.LittleEndian.Uint32(header[bmpReservedStart:])
+
+		if int64(fileSize) != size || reserved != 0 {
+			return false
+		}
+
+		switch binary.LittleEndian.Uint32(header[bmpDIBStart:]) {
+		case bmpCoreHeader, bmpInfoHeader, bmpV4Header, bmpV5Header:
+			return true
+		default:
+			return false
+		}
+	}
+}
+
+// bmffBrand returns the major brand of an ISO base media file such as MP4,
+// MOV or HEIF.
+func bmffBrand(header []byte) string {
+	if !sniffAt(header, bmffTypeStart, bmffTypeEnd, "ftyp") ||
+		len(header) < bmffBrandEnd {
+		return ""
+	}
+
+	return string(header[bmffTypeEnd:bmffBrandEnd])
+}
+
+func sniffBrand(brands ...string) func(header []byte) bool {
+	return func(header []byte) bool {
+		brand := bmffBrand(header)
+		for _, b := range brands {
+			if strings.HasPrefix(brand, b) {
+				return true
+			}
+		}
+
+		return false
+	}
+}
+
+func sniffTS(offset int, packetLen int) func(header []byte) bool {
+	return func(header []byte) bool {
+		return len(header) > offset+packetLen &&
+			header[offset] == tsSync && header[offset+packetLen] == tsSync
+	}
+}
+
+// The formats we recognize in a file of size bytes. Order matters, more
+// specific formats come first.
+func sniffFormats(size int64) []sniffFormat {
+	// QuickTime and its relatives are the same container.
+	movies := []string{".3g2", ".3gp", ".m4v", ".mov", ".mp4"}
+	// Many raw formats are TIFF files with another extension.
+	tiffs := []string{".arw", ".dng", ".nef", ".raw", ".tif", ".tiff"}
+
+	return []sniffFormat{
+		{".jpg", []string{".jpeg"}, sniffPrefix("\xff\xd8\xff")},
+		{".png", nil, sniffPrefix("\x89PNG\r\n\x1a\n")},
+		{".gif", nil, sniffPrefix("GIF8")},
+		{".psd", nil, sniffPrefix("8BPS")},
+		{".raf", nil, sniffPrefix("FUJIFILMCCD-RAW")},
+		{".orf", nil, sniffPrefix("IIRO")},
+		{".orf", nil, sniffPrefix("IIRS")},
+		{".rw2", nil, sniffPrefix("IIU\x00")},
+		{".cr2", nil, func(header []byte) bool {
+			return sniffTIFF(header) && sniffAt(header, tiffRawStart, tiffRawEnd, "CR")
+		}},
+		{".tif", tiffs, sniffTIFF},
+		{".webp", nil, sniffRIFF("WEBP")},
+		{".avi", nil, sniffRIFF("AVI ")},
+		{".heic", []string{".heif", ".hif"},
+			sniffBrand("heic", "heix", "heim", "heis", "hevc", "hevx", "mif1", "msf1")},
+		{".mov", movies, sniffBrand("qt  ")},
+		{".3gp", movies, sniffBrand("3gp")},
+		{".3g2", movies, sniffBrand("3g2")},
+		{".m4v", movies, sniffBrand("M4V")},
+		{".mp4", movies, func(header []byte) bool { return bmffBrand(header) != "" }},
+		{".wmv", []string{".asf", ".wma"},
+			sniffPrefix("\x30\x26\xb2\x75\x8e\x66\xcf\x11")},
+		{".mpg", []string{".mpeg"}, sniffPrefix("\x00\x00\x01\xba")},
+		{".mts", []string{".ts"}, sniffTS(0, tsPacketLen)},
+		{".m2ts", []string{".mts"}, sniffTS(m2tsPacketLen-tsPacketLen, m2tsPacketLen)},
+		{".bmp", nil, sniffBMP(size)},
+	}
+}
+
+// sniffHeader returns the format of a file of size bytes that starts with
+// header.
+func sniffHeader(header []byte, size int64) (sniffFormat, bool) {
+	for _, format := range sniffFormats(size) {
+		if format.match(header) {
+			return format, true
+		}
+	}
+
+	return sniffFormat{}, false
+}
+
+// sniffRead returns the start of the file at path and its size.
+func sniffRead(path string) ([]byte, int64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	header := make([]byte, sniffLen)
+
+	num, err := io.ReadFull(file, header)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, 0, err
+	}
+
+	return header[:num], info.Size(), nil
+}
+
+// Sniff returns the extension, such as ".jpg", of the format of the file at
+// path judged by its contents. It returns "" if the format is not one we
+// recognize.
+func Sniff(path string) (string, error) {
+	header, size, err := sniffRead(path)
+	if err != nil {
+		return "", err
+	}
+
+	format, found := sniffHeader(header, size)
+	if !found {
+		return "", nil
+	}
+
+	return format.ext, nil
+}
+
+// sniffMismatch returns the extension of format when it disagrees with the
+// extension of path. It returns "" when they agree.
+func sniffMismatch(path string, format sniffFormat) string {
+	extension := strings.ToLower(filepath.Ext(path))
+	if extension == format.ext {
+		return ""
+	}
+
+	for _, alias := range format.aliases {
+		if extension == alias {
+			return ""
+		}
+	}
+
+	return format.ext
+}
+
+// sniffCategory returns the category of path judged by its contents when its
+// extension disagrees with them, along with the extension of the contents.
+// Otherwise the extension decides as usual.
+func sniffCategory(path string, exts Extensions) (Category, string) {
+	category := exts.Category(path)
+
+	header, size, err := sniffRead(path)
+	if err != nil {
+		// Scanning will report what is wrong with the file.
+		return category, ""
+	}
+
+	format, found := sniffHeader(header, size)
+	if !found {
+		return category, ""
+	}
+
+	mismatch := sniffMismatch(path, format)
+	if mismatch == "" {
+		return category, ""
+	}
+
+	category, present := exts[format.ext]
+	if !present {
+		category = CategorySkip
+	}
+
+	return category, mismatch
+}
@@ -0,0 +1,137 @@
+package exifsort
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSniffHeader(t *testing.T) {
+	ts := make([]byte, tsPacketLen+1)
+	ts[0], ts[tsPacketLen] = tsSync, tsSync
+
+	headers := map[string]string{
+		"\xff\xd8\xff\xe1":                  ".jpg",
+		"\x89PNG\r\n\x1a\n":                 ".png",
+		"GIF89a":                            ".gif",
+		"II*\x00\x08\x00\x00\x00":           ".tif",
+		"MM\x00*\x00\x00\x00\x08":           ".tif",
+		"II*\x00\x10\x00\x00\x00CR\x02\x00": ".cr2",
+		"IIRO\x08\x00\x00\x00":              ".orf",
+		"IIU\x00\x08\x00\x00\x00":           ".rw2",
+		"FUJIFILMCCD-RAW 0201":              ".raf",
+		"RIFF\x00\x00\x00\x00WEBPVP8 ":      ".webp",
+		"RIFF\x00\x00\x00\x00AVI LIST":      ".avi",
+		"\x00\x00\x00\x18ftypheic\x00\x00":  ".heic",
+		"\x00\x00\x00\x14ftypqt  \x00\x00":  ".mov",
+		"\x00\x00\x00\x18ftypisom\x00\x00":  ".mp4",
+		"\x00\x00\x00\x18ftyp3gp5\x00\x00":  ".3gp",
+		"\x00\x00\x01\xba\x44\x00":          ".mpg",
+		"0&\xb2u\x8ef\xcf\x11\xa6\xd9":      ".wmv",
+		string(ts):                          ".mts",
+		// BMP headers of 18 bytes with their size, reserved bytes and DIB
+		// header size right and then wrong.
+		"BM\x12\x00\x00\x00\x00\x00\x00\x00\x1a\x00\x00\x00\x28\x00\x00\x00": ".bmp",
+		"BM\x12\x00\x00\x00\x00\x00\x00\x00\x1a\x00\x00\x00\x0c\x00\x00\x00": ".bmp",
+		"BM\x13\x00\x00\x00\x00\x00\x00\x00\x1a\x00\x00\x00\x28\x00\x00\x00": "",
+		"BM\x12\x00\x00\x00\x01\x00\x00\x00\x1a\x00\x00\x00\x28\x00\x00\x00": "",
+		"BM\x12\x00\x00\x00\x00\x00\x00\x00\x1a\x00\x00\x00\x29\x00\x00\x00": "",
+		"BMW owners manual, chapter one...":                                  "",
+		"hello, this is not media at all...":                                 "",
+		"":                                                                   "",
+	}
+
+	for header, expected := range headers {
+		format, found := sniffHeader([]byte(header), int64(len(header)))
+		if format.ext != expected || found != (expected != "") {
+			t.Errorf("Expected %q for %q got %q\n", expected, header,
+				format.ext)
+		}
+	}
+}
+
+func TestSniffMismatch(t *testing.T) {
+	jpeg, _ := sniffHeader([]byte("\xff\xd8\xff\xe1"), 4)
+	tiff, _ := sniffHeader([]byte("II*\x00\x08\x00\x00\x00"), 8)
+
+	agree := map[string]sniffFormat{
+		"IMG_1.JPG":  jpeg,
+		"IMG_1.jpeg": jpeg,
+		"DSC_1.NEF":  tiff,
+		"DSC_1.dng":  tiff,
+	}
+
+	for path, format := range agree {
+		if sniffMismatch(path, format) != "" {
+			t.Errorf("Expected %s to agree with %s\n", path, format.ext)
+		}
+	}
+
+	if sniffMismatch("IMG_1.png", jpeg) != ".jpg" {
+		t.Errorf("Expected IMG_1.png to be a .jpg mismatch\n")
+	}
+}
+
+func TestSniffScan(t *testing.T) {
+	t.Parallel()
+
+	tmpPath, err := ioutil.TempDir("", "SniffScan")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpPath)
+
+	content, err := ioutil.ReadFile(exifPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names := []string{"good.jpg", "misnamed.png", "f1234567", "notes.txt"}
+	for _, name := range names {
+		err = ioutil.WriteFile(filepath.Join(tmpPath, name), content, 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err = ioutil.WriteFile(filepath.Join(tmpPath, "README.md"),
+		[]byte("# Not media"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Without sniffing the extension decides.
+	s := NewScanner()
+	_ = s.ScanDir(tmpPath, NopObserver{})
+
+	if len(s.Data) != 2 || len(s.ExifErrors) != 1 || len(s.Mismatches) != 0 {
+		t.Errorf("Expected 2 data 1 exif error got %d data %d exif errors\n",
+			len(s.Data), len(s.ExifErrors))
+	}
+
+	s = NewScanner()
+	s.Sniff = true
+	_ = s.ScanDir(tmpPath, NopObserver{})
+
+	if len(s.Data) != 4 || len(s.ExifErrors) != 0 || s.SkippedCount != 1 {
+		t.Errorf("Expected 4 data 1 skipped got %d data %d skipped %v\n",
+			len(s.Data), s.SkippedCount, s.ExifErrors)
+	}
+
+	for _, name := range []string{"misnamed.png", "f1234567", "notes.txt"} {
+		if s.Mismatches[filepath.Join(tmpPath, name)] != ".jpg" {
+			t.Errorf("Expected %s to be a .jpg mismatch\n", name)
+		}
+	}
+
+	if len(s.Mismatches) != 3 {
+		t.Errorf("Expected 3 mismatches got %v\n", s.Mismatches)
+	}
+
+	ext, err := Sniff(filepath.Join(tmpPath, "misnamed.png"))
+	if err != nil || !strings.EqualFold(ext, ".jpg") {
+		t.Errorf("Expected Sniff to return .jpg got %q (%v)\n", ext, err)
+	}
+}
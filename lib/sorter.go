@@ -3,9 +3,10 @@ package exifsort
 import (
 	"errors"
 	"fmt"
-	"io"
 	"os"
 	"path/filepath"
+	"sort"
+	"time"
 )
 
 // Sorter is your API to perform sorting actions after a scan.
@@ -17,10 +18,148 @@ import (
 // It holds the index of sorted media and errors found in constructing or
 // transferring it.
 type Sorter struct {
-	idx            index
+	// One index per device or place label. Media without one, or sorted
+	// by LayoutDate, is in "".
+	idxs         map[string]index
+	rng          DateRange
+	cameras      Cameras
+	near         Near
+	layout       Layout
+	gazetteer    *Gazetteer
+	eventGap     time.Duration
+	eventLabels  EventLabels
+	manifest     *Manifest
+	catalog      *Catalog
+	verify       bool
+	collision    Collision
+	duplicate    Duplicate
+	duplicateDir string
+	naming       Naming
+	media        mediaMap
+	// order has the paths of media in the order they are transferred.
+	order []string
+	// times, infos and sources are what the scan found, for the catalog.
+	times          map[string]time.Time
+	infos          map[string]MediaInfo
+	sources        map[string]TimeSource
 	IndexErrors    map[string]string
 	TransferErrors map[string]string
 	Duplicates     []string
+	// Collisions maps the media left where it was because of its collision
+	// policy to the file that has its name.
+	Collisions map[string]string
+	// Replaced are the older files in dst CollisionNewer replaced.
+	Replaced []string
+	// OutOfRange is the number of files left out by the date range.
+	OutOfRange int
+	// OtherCameras is the number of files left out by the cameras.
+	OtherCameras int
+	// FarAway is the number of files left out by near.
+	FarAway int
+}
+
+// SorterOption changes how a Sorter indexes media.
+type SorterOption func(s *Sorter)
+
+// WithDateRange only sorts media with times in r.
+func WithDateRange(r DateRange) SorterOption {
+	return func(s *Sorter) {
+		s.rng = r
+	}
+}
+
+// WithCameras only sorts media taken by one of cameras.
+func WithCameras(cameras Cameras) SorterOption {
+	return func(s *Sorter) {
+		s.cameras = cameras
+	}
+}
+
+// WithNear only sorts media taken within near.
+func WithNear(near Near) SorterOption {
+	return func(s *Sorter) {
+		s.near = near
+	}
+}
+
+// WithGazetteer names the places of LayoutLocation with g instead of
+// NewGazetteer().
+func WithGazetteer(g *Gazetteer) SorterOption {
+	return func(s *Sorter) {
+		s.gazetteer = g
+	}
+}
+
+// WithEventGap starts a new event of MethodEvent when there is more than gap
+// between two photos instead of EventGap.
+func WithEventGap(gap time.Duration) SorterOption {
+	return func(s *Sorter) {
+		s.eventGap = gap
+	}
+}
+
+// WithEventLabels names the events of MethodEvent with labels.
+func WithEventLabels(labels EventLabels) SorterOption {
+	return func(s *Sorter) {
+		s.eventLabels = labels
+	}
+}
+
+// WithManifest records the media transferred in m, which must be the
+// manifest of dst.
+func WithManifest(m *Manifest) SorterOption {
+	return func(s *Sorter) {
+		s.manifest = m
+	}
+}
+
+// WithCatalog records the media transferred in c, which must be the catalog
+// of dst. Media c has anywhere in dst already is a duplicate.
+func WithCatalog(c *Catalog) SorterOption {
+	return func(s *Sorter) {
+		s.catalog = c
+	}
+}
+
+// WithVerify reads every file back after it is transferred and compares its
+// SHA-256 with the source. Media is moved by copying it and removing the
+// source once it matches.
+func WithVerify(verify bool) SorterOption {
+	return func(s *Sorter) {
+		s.verify = verify
+	}
+}
+
+// WithCollision handles media whose name is taken by collision instead of
+// CollisionRename.
+func WithCollision(collision Collision) SorterOption {
+	return func(s *Sorter) {
+		s.collision = collision
+	}
+}
+
+// WithDuplicates handles the duplicates found in src by duplicate instead of
+// DuplicateAuto. dir is where DuplicateMove moves them.
+func WithDuplicates(duplicate Duplicate, dir string) SorterOption {
+	return func(s *Sorter) {
+		s.duplicate = duplicate
+		s.duplicateDir = dir
+	}
+}
+
+// WithNaming renames media whose name is taken by naming instead of
+// NamingCounter.
+func WithNaming(naming Naming) SorterOption {
+	return func(s *Sorter) {
+		s.naming = naming
+	}
+}
+
+// WithLayout sorts media into the directories of layout.
+func WithLayout(layout Layout) SorterOption {
+	return func(s *Sorter) {
+		s.layout = layout
+	}
 }
 
 func (s *Sorter) ensureFullPath(path string) error {
@@ -32,6 +171,10 @@ func (s *Sorter) storeDuplicate(path string) {
 	s.Duplicates = append(s.Duplicates, path)
 }
 
+func (s *Sorter) storeCollision(path string, taker string) {
+	s.Collisions[path] = taker
+}
+
 // We don't check if you have a path duplicate.
 func (s *Sorter) storeIndexError(path string, err error) {
 	s.IndexErrors[path] = err.Error()
@@ -44,7 +187,8 @@ func (s *Sorter) storeTransferError(path string, err error) {
 
 // Transfer will transfer files  after indexing according to action.
 // Transfer will fail if dst directory does not exist and is not accessible.
-func (s *Sorter) Transfer(dst string, action Action, logger io.Writer) error {
+// observer receives the events generated while transferring.
+func (s *Sorter) Transfer(dst string, action Action, observer Observer) error {
 	if action != ActionCopy && action != ActionMove {
 		return fmt.Errorf("invalid action %s", action)
 	}
@@ -55,55 +199,460 @@ func (s *Sorter) Transfer(dst string, action Action, logger io.Writer) error {
 	}
 
 	// Let's get rid of all the duplciates we know of before we transfer.
-	for _, toRemove := range s.Duplicates {
-		err := os.Remove(toRemove)
-		if err != nil {
-			s.storeTransferError(toRemove, err)
-		}
+	for _, dup := range s.Duplicates {
+		s.removeDuplicate(dup, action, observer)
 	}
 
-	mediaMap := s.idx.GetAll()
+	for _, newPath := range s.order {
+		oldPath := s.media[newPath]
+
+		newPath, err = s.target(oldPath, filepath.Join(dst, newPath))
+
+		var dupErr *duplicateError
+
+		switch {
+		case errors.As(err, &dupErr):
+			s.storeDuplicate(oldPath)
+			s.removeDuplicate(oldPath, action, observer)
+
+			continue
+		case err != nil:
+			s.storeTransferError(oldPath, err)
+			observer.Error(oldPath, err)
 
-	for newPath, oldPath := range mediaMap {
-		newPath = filepath.Join(dst, newPath)
+			return err
+		case newPath == "":
+			continue
+		}
 
 		err = s.ensureFullPath(newPath)
 		if err != nil {
 			return err
 		}
 
-		switch action {
-		case ActionCopy:
-			err = copyFile(oldPath, newPath)
-		case ActionMove:
-			err = moveFile(oldPath, newPath)
+		// Only a newer file is transferred to a name that is taken.
+		replace := exists(newPath)
+		if replace {
+			err = replaceFile(oldPath, newPath, action, s.verify)
+		} else {
+			err = transferFile(oldPath, newPath, action, s.verify)
+		}
+
+		var mismatch *mismatchError
+		if errors.As(err, &mismatch) {
+			// The source is still there, we can go on.
+			s.storeTransferError(oldPath, err)
+			observer.Error(oldPath, err)
+
+			continue
 		}
 
 		if err != nil {
 			s.storeTransferError(oldPath, err)
+			observer.Error(oldPath, err)
+
 			return err
 		}
 
-		fmt.Fprintf(logger, "Transferred %s\n", newPath)
+		if replace {
+			s.Replaced = append(s.Replaced, newPath)
+		}
+
+		observer.Transferred(oldPath, newPath)
+
+		err = s.record(oldPath, newPath, action)
+		if err != nil {
+			s.storeTransferError(newPath, err)
+			observer.Error(newPath, err)
+		}
 	}
 
 	return nil
 }
 
+// removeDuplicate handles the duplicate path by the duplicate policy.
+func (s *Sorter) removeDuplicate(path string, action Action, observer Observer) {
+	removed, err := disposeDuplicate(path, s.duplicate, action, s.duplicateDir)
+	if err != nil {
+		s.storeTransferError(path, err)
+		observer.Error(path, err)
+
+		return
+	}
+
+	if !removed {
+		return
+	}
+
+	observer.DuplicateRemoved(path)
+
+	if s.manifest != nil {
+		s.manifest.Remove(path)
+	}
+
+	// Only when sorting in place does dst have path.
+	if s.catalog != nil {
+		err = s.catalog.Remove(path)
+		if err != nil {
+			s.storeTransferError(path, err)
+			observer.Error(path, err)
+		}
+	}
+}
+
+// target returns the path to transfer oldPath to when newPath may already
+// exist in dst, or "" to leave it where it is. It returns a duplicateError
+// if dst has the same file.
+func (s *Sorter) target(oldPath string, newPath string) (string, error) {
+	// Sorting in place finds media that is already where it belongs.
+	if sameFile(oldPath, newPath) {
+		return "", nil
+	}
+
+	if s.catalog != nil {
+		archived, found, err := s.catalog.Archived(oldPath)
+		if err != nil || found {
+			return "", duplicateOf(oldPath, archived, err)
+		}
+	}
+
+	if !exists(newPath) {
+		return newPath, nil
+	}
+
+	if s.collision == CollisionRename {
+		dir := filepath.Dir(newPath)
+
+		base, err := uniqueName(oldPath, s.naming, func(filename string) string {
+			path := filepath.Join(dir, filename)
+			if exists(path) {
+				return path
+			}
+
+			return ""
+		})
+
+		return filepath.Join(dir, base), err
+	}
+
+	replace, err := collide(s.collision, oldPath, newPath)
+
+	switch {
+	case err != nil:
+		return "", err
+	case replace:
+		return newPath, nil
+	default:
+		s.storeCollision(oldPath, newPath)
+		return "", nil
+	}
+}
+
+// resolveCollisions applies the collision policy to the media the index
+// renamed because another media file took its name first.
+func (s *Sorter) resolveCollisions(all mediaMap) (mediaMap, error) {
+	if s.collision == CollisionRename {
+		return all, nil
+	}
+
+	// The paths of the media that want each name.
+	wanted := make(map[string][]string)
+
+	for newPath, oldPath := range all {
+		name := filepath.Join(filepath.Dir(newPath), filepath.Base(oldPath))
+		wanted[name] = append(wanted[name], newPath)
+	}
+
+	resolved := make(mediaMap)
+
+	for name, newPaths := range wanted {
+		// The first indexed has the name, the others were renamed.
+		sort.Slice(newPaths, func(i, j int) bool {
+			return newPaths[i] == name || newPaths[j] != name && newPaths[i] < newPaths[j]
+		})
+
+		taker := all[newPaths[0]]
+
+		if len(newPaths) > 1 && s.collision == CollisionFail {
+			return nil, &collisionError{all[newPaths[1]], taker}
+		}
+
+		var losers []string
+
+		for _, newPath := range newPaths[1:] {
+			oldPath := all[newPath]
+
+			if s.collision == CollisionNewer {
+				newer, err := isNewer(oldPath, taker)
+				if err != nil {
+					return nil, err
+				}
+
+				if newer {
+					oldPath, taker = taker, oldPath
+				}
+			}
+
+			losers = append(losers, oldPath)
+		}
+
+		for _, loser := range losers {
+			s.storeCollision(loser, taker)
+		}
+
+		resolved[name] = taker
+	}
+
+	return resolved, nil
+}
+
+// hashNames gives NamingHash names to the media the index renamed.
+func (s *Sorter) hashNames(all mediaMap) mediaMap {
+	renamed := make([]string, 0)
+
+	for newPath, oldPath := range all {
+		if filepath.Base(newPath) != filepath.Base(oldPath) {
+			renamed = append(renamed, newPath)
+		}
+	}
+
+	// Hash names rarely collide, but if they do the counter must not
+	// depend on map order.
+	sort.Strings(renamed)
+
+	for _, newPath := range renamed {
+		oldPath := all[newPath]
+		dir := filepath.Dir(newPath)
+
+		delete(all, newPath)
+
+		base, err := uniqueName(oldPath, NamingHash, func(filename string) string {
+			return all[filepath.Join(dir, filename)]
+		})
+		if err != nil {
+			s.storeIndexError(oldPath, err)
+			continue
+		}
+
+		all[filepath.Join(dir, base)] = oldPath
+	}
+
+	return all
+}
+
+// transferOrder returns the paths of media in the order of paths.
+func (s *Sorter) transferOrder(paths []string) []string {
+	newPaths := make(map[string]string, len(s.media))
+	for newPath, oldPath := range s.media {
+		newPaths[oldPath] = newPath
+	}
+
+	order := make([]string, 0, len(s.media))
+
+	for _, oldPath := range paths {
+		newPath, present := newPaths[oldPath]
+		if present {
+			order = append(order, newPath)
+		}
+	}
+
+	return order
+}
+
+// record adds the media transferred to newPath to the manifest and catalog.
+func (s *Sorter) record(oldPath string, newPath string, action Action) error {
+	var err error
+
+	switch {
+	case s.manifest == nil:
+	case action == ActionMove:
+		err = s.manifest.Moved(oldPath, newPath)
+	default:
+		err = s.manifest.Add(newPath)
+	}
+
+	switch {
+	case err != nil || s.catalog == nil:
+		return err
+	case action == ActionMove && inDir(s.catalog.root, oldPath):
+		// Sorted in place, the catalog has it already.
+		return s.catalog.Moved(oldPath, newPath)
+	}
+
+	// A time only the directory told is not the time of the media.
+	t := s.times[oldPath]
+	if s.sources[oldPath] == TimeSourcePath {
+		t = time.Time{}
+	}
+
+	return s.catalog.Add(newPath, oldPath, t, s.infos[oldPath])
+}
+
+// mediaAll returns the media of every index with paths relative to dst.
+func (s *Sorter) mediaAll() mediaMap {
+	all := make(mediaMap)
+
+	for label, idx := range s.idxs {
+		for newPath, oldPath := range idx.GetAll() {
+			dir, base := filepath.Split(newPath)
+
+			switch {
+			case label == "":
+			case s.layout == LayoutDevice:
+				newPath = filepath.Join(dir, label, base)
+			case s.layout == LayoutLocation:
+				newPath = filepath.Join(filepath.Clean(dir)+" "+label, base)
+			}
+
+			all[newPath] = oldPath
+		}
+	}
+
+	return all
+}
+
+// label returns the device or place the layout sorts media of info by.
+func (s *Sorter) label(info MediaInfo) string {
+	switch s.layout {
+	case LayoutDevice:
+		return info.Device()
+	case LayoutLocation:
+		if info.GPS == nil {
+			return ""
+		}
+
+		place, found := s.gazetteer.Nearest(*info.GPS)
+		if !found {
+			return ""
+		}
+
+		return dirName(place.Name)
+	default:
+		return ""
+	}
+}
+
+// labelIndex returns the index for the media of label.
+func (s *Sorter) labelIndex(label string, method Method) (index, error) {
+	idx, present := s.idxs[label]
+	if present {
+		return idx, nil
+	}
+
+	idx, err := s.newIndex(method)
+	if err != nil {
+		return nil, err
+	}
+
+	s.idxs[label] = idx
+
+	return idx, nil
+}
+
+func (s *Sorter) newIndex(method Method) (index, error) {
+	idx, err := newIndex(method)
+	if err != nil {
+		return nil, err
+	}
+
+	if events, ok := idx.(*eventIndex); ok {
+		if s.eventGap > 0 {
+			events.gap = s.eventGap
+		}
+
+		events.labels = s.eventLabels
+	}
+
+	return idx, nil
+}
+
+// sortedPaths returns the paths of data in time order, then by path so we
+// index the same way every time.
+func sortedPaths(data map[string]time.Time) []string {
+	paths := make([]string, 0, len(data))
+	for path := range data {
+		paths = append(paths, path)
+	}
+
+	sort.Slice(paths, func(i, j int) bool {
+		ti, tj := data[paths[i]], data[paths[j]]
+		if !ti.Equal(tj) {
+			return ti.Before(tj)
+		}
+
+		return paths[i] < paths[j]
+	})
+
+	return paths
+}
+
 // Reset clears data so Sorter can be reused.
 func (s *Sorter) Reset(scanner Scanner, method Method) error {
 	s.IndexErrors = make(map[string]string)
 	s.TransferErrors = make(map[string]string)
+	s.Duplicates = nil
+	s.Collisions = make(map[string]string)
+	s.Replaced = nil
+	s.OutOfRange = 0
+	s.OtherCameras = 0
+	s.FarAway = 0
+	s.idxs = make(map[string]index)
+	s.media = make(mediaMap)
+	s.order = nil
+	s.times = scanner.Data
+	s.infos = scanner.Info
+	s.sources = scanner.Sources
 
-	idx, err := newIndex(method)
+	if s.layout >= LayoutNone {
+		return fmt.Errorf("invalid layout %s", s.layout)
+	}
+
+	if s.collision >= CollisionNone || s.duplicate >= DuplicateNone {
+		return fmt.Errorf("invalid policies %s and %s", s.collision, s.duplicate)
+	}
+
+	if s.naming >= NamingNone {
+		return fmt.Errorf("invalid naming %s", s.naming)
+	}
+
+	if s.layout == LayoutLocation {
+		s.gazetteer = gazetteerOrDefault(s.gazetteer)
+	}
+
+	// Even an empty scan must have a valid method.
+	_, err := newIndex(method)
 	if err != nil {
 		return err
 	}
 
-	s.idx = idx
+	// Index and transfer in the same order every time so the names are the
+	// same every time.
+	paths := sortedPaths(scanner.Data)
+
+	for _, path := range paths {
+		time := scanner.Data[path]
+		if !s.rng.Contains(time) {
+			s.OutOfRange++
+			continue
+		}
+
+		info := scanner.Info[path]
+		if !s.cameras.Match(info) {
+			s.OtherCameras++
+			continue
+		}
+
+		if !s.near.Contains(info.GPS) {
+			s.FarAway++
+			continue
+		}
 
-	for path, time := range scanner.Data {
-		err = s.idx.Put(path, time)
+		idx, err := s.labelIndex(s.label(info), method)
+		if err != nil {
+			return err
+		}
+
+		err = idx.Put(path, time)
 		if err == nil {
 			continue
 		}
@@ -118,6 +667,17 @@ func (s *Sorter) Reset(scanner Scanner, method Method) error {
 		s.storeIndexError(path, err)
 	}
 
+	s.media, err = s.resolveCollisions(s.mediaAll())
+	if err != nil {
+		return err
+	}
+
+	if s.naming == NamingHash {
+		s.media = s.hashNames(s.media)
+	}
+
+	s.order = s.transferOrder(paths)
+
 	return nil
 }
 
@@ -125,10 +685,15 @@ func (s *Sorter) Reset(scanner Scanner, method Method) error {
 // the method desired to sort.
 //
 // The structure of how it will organize the the dst directory is specified by
-// 'method'. This routine will index via the method speficied.
-func NewSorter(scanner Scanner, method Method) (*Sorter, error) {
+// 'method'. This routine will index via the method speficied. opts change
+// what is indexed and how.
+func NewSorter(scanner Scanner, method Method, opts ...SorterOption) (*Sorter, error) {
 	var s Sorter
 
+	for _, opt := range opts {
+		opt(&s)
+	}
+
 	err := s.Reset(scanner, method)
 	if err != nil {
 		return nil, err
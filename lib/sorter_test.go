@@ -4,12 +4,15 @@ import (
 	"errors"
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 )
 
 func testTransfer(t *testing.T, td *testdir, method Method, action Action) error {
 	scanner := NewScanner()
-	_ = scanner.ScanDir(td.root, ioutil.Discard)
+	_ = scanner.ScanDir(td.root, NopObserver{})
 
 	dst, _ := ioutil.TempDir("", "sort_dst_")
 	defer os.RemoveAll(dst)
@@ -19,7 +22,7 @@ func testTransfer(t *testing.T, td *testdir, method Method, action Action) error
 		return err
 	}
 
-	err = sorter.Transfer(dst, action, ioutil.Discard)
+	err = sorter.Transfer(dst, action, NopObserver{})
 	if err != nil {
 		return err
 	}
@@ -31,7 +34,8 @@ func testTransfer(t *testing.T, td *testdir, method Method, action Action) error
 
 	switch {
 	case action == ActionCopy:
-		err := countFiles(t, td.root, td.numTotal(), "Src Copy")
+		// Copying leaves duplicates where they are.
+		err := countFiles(t, td.root, td.numTotal()+td.numDuplicates, "Src Copy")
 		if err != nil {
 			return err
 		}
@@ -147,12 +151,499 @@ func TestSortNoOutputDir(t *testing.T) {
 	defer os.RemoveAll(src)
 
 	scanner := NewScanner()
-	_ = scanner.ScanDir(src, ioutil.Discard)
+	_ = scanner.ScanDir(src, NopObserver{})
 
 	sorter, _ := NewSorter(scanner, MethodYear)
 
-	err := sorter.Transfer("dst", ActionMove, ioutil.Discard)
+	err := sorter.Transfer("dst", ActionMove, NopObserver{})
 	if err == nil {
 		t.Fatalf("Unexpected Success\n")
 	}
 }
+
+func TestSortDateRange(t *testing.T) {
+	for _, method := range []Method{MethodYear, MethodMonth, MethodDay} {
+		method := method
+		t.Run(method.String(), func(t *testing.T) {
+			t.Parallel()
+			td := newTestDir(t, method, fileNoDefault)
+
+			src := td.buildTimeSpreadRoot()
+			defer os.RemoveAll(src)
+
+			scanner := NewScanner()
+			_ = scanner.ScanDir(src, NopObserver{})
+
+			sorter, err := NewSorter(scanner, method,
+				WithDateRange(td.testTimeSpreadRange()))
+			if err != nil {
+				t.Fatalf("Unexpected error %s\n", err.Error())
+			}
+
+			// Only the nested directory is in range.
+			const inRange = 25
+
+			if sorter.OutOfRange != td.numData-inRange {
+				t.Errorf("Expected %d out of range got %d\n",
+					td.numData-inRange, sorter.OutOfRange)
+			}
+
+			dst, _ := ioutil.TempDir("", "sort_dst_")
+			defer os.RemoveAll(dst)
+
+			err = sorter.Transfer(dst, ActionCopy, NopObserver{})
+			if err != nil {
+				t.Fatalf("Unexpected error %s\n", err.Error())
+			}
+
+			err = countFiles(t, dst, inRange, "Dst Data")
+			if err != nil {
+				t.Errorf("%s\n", err.Error())
+			}
+		})
+	}
+}
+
+func TestSortDeviceLayout(t *testing.T) {
+	t.Parallel()
+
+	td := newTestDir(t, MethodYear, fileNoDefault)
+	src := td.buildRoot()
+
+	defer os.RemoveAll(src)
+
+	scanner := NewScanner()
+	_ = scanner.ScanDir(src, NopObserver{})
+
+	sorter, err := NewSorter(scanner, MethodYear, WithLayout(LayoutDevice))
+	if err != nil {
+		t.Fatalf("Unexpected error %s\n", err.Error())
+	}
+
+	dst, _ := ioutil.TempDir("", "sort_dst_")
+	defer os.RemoveAll(dst)
+
+	err = sorter.Transfer(dst, ActionCopy, NopObserver{})
+	if err != nil {
+		t.Fatalf("Unexpected error %s\n", err.Error())
+	}
+
+	// The exif files are all from one year and one phone.
+	phoneDir := filepath.Join(dst, "2020", "Apple iPhone 11 Pro")
+
+	err = countFiles(t, phoneDir, td.numData-td.numExifError, "Phone Dir")
+	if err != nil {
+		t.Errorf("%s\n", err.Error())
+	}
+
+	err = countFiles(t, dst, td.numData, "Dst Data")
+	if err != nil {
+		t.Errorf("%s\n", err.Error())
+	}
+}
+
+func TestSortCameras(t *testing.T) {
+	t.Parallel()
+
+	td := newTestDir(t, MethodYear, fileNoDefault)
+	src := td.buildRoot()
+
+	defer os.RemoveAll(src)
+
+	scanner := NewScanner()
+	_ = scanner.ScanDir(src, NopObserver{})
+
+	sorter, err := NewSorter(scanner, MethodYear, WithCameras(Cameras{"iphone"}))
+	if err != nil {
+		t.Fatalf("Unexpected error %s\n", err.Error())
+	}
+
+	if sorter.OtherCameras != td.numExifError {
+		t.Errorf("Expected %d other cameras got %d\n",
+			td.numExifError, sorter.OtherCameras)
+	}
+
+	_, err = NewSorter(scanner, MethodYear, WithLayout(LayoutNone))
+	if err == nil {
+		t.Errorf("Expected error for layout none\n")
+	}
+}
+
+func TestSortLocationLayout(t *testing.T) {
+	t.Parallel()
+
+	td := newTestDir(t, MethodMonth, fileNoDefault)
+	src := td.buildRoot()
+
+	defer os.RemoveAll(src)
+
+	scanner := NewScanner()
+	_ = scanner.ScanDir(src, NopObserver{})
+
+	sorter, err := NewSorter(scanner, MethodMonth, WithLayout(LayoutLocation))
+	if err != nil {
+		t.Fatalf("Unexpected error %s\n", err.Error())
+	}
+
+	dst, _ := ioutil.TempDir("", "sort_dst_")
+	defer os.RemoveAll(dst)
+
+	err = sorter.Transfer(dst, ActionCopy, NopObserver{})
+	if err != nil {
+		t.Fatalf("Unexpected error %s\n", err.Error())
+	}
+
+	// The exif files were all taken in Saratoga in one month.
+	placeDir := filepath.Join(dst, "2020", "2020_04 Saratoga")
+
+	err = countFiles(t, placeDir, td.numData-td.numExifError, "Place Dir")
+	if err != nil {
+		t.Errorf("%s\n", err.Error())
+	}
+
+	err = countFiles(t, dst, td.numData, "Dst Data")
+	if err != nil {
+		t.Errorf("%s\n", err.Error())
+	}
+}
+
+func TestSortNear(t *testing.T) {
+	t.Parallel()
+
+	td := newTestDir(t, MethodYear, fileNoDefault)
+	src := td.buildRoot()
+
+	defer os.RemoveAll(src)
+
+	scanner := NewScanner()
+	_ = scanner.ScanDir(src, NopObserver{})
+
+	nearCount := map[Near]int{
+		// Saratoga
+		{Coordinates{Latitude: 37.26, Longitude: -122.02}, 10}: td.numExifError,
+		// Lisbon
+		{Coordinates{Latitude: 38.72, Longitude: -9.14}, 10}: td.numData,
+	}
+
+	for near, farAway := range nearCount {
+		sorter, err := NewSorter(scanner, MethodYear, WithNear(near))
+		if err != nil {
+			t.Fatalf("Unexpected error %s\n", err.Error())
+		}
+
+		if sorter.FarAway != farAway {
+			t.Errorf("%v: expected %d far away got %d\n", near, farAway, sorter.FarAway)
+		}
+	}
+}
+
+func TestSortEvents(t *testing.T) {
+	t.Parallel()
+
+	td := newTestDir(t, MethodEvent, fileNoDefault)
+	src := td.buildTimeSpreadRoot()
+
+	defer os.RemoveAll(src)
+
+	scanner := NewScanner()
+	_ = scanner.ScanDir(src, NopObserver{})
+
+	// Ten days apart the three directories of buildTimeSpreadRoot are three
+	// events unless the gap is longer.
+	expected := map[time.Duration]map[string]int{
+		EventGap: {
+			"2000_01_01": 5,
+			"2000_01_11": 25,
+			"2000_01_21": 5,
+		},
+		30 * 24 * time.Hour: {
+			"2000_01_01-2000_01_21": td.numData,
+		},
+	}
+
+	for gap, events := range expected {
+		sorter, err := NewSorter(scanner, MethodEvent, WithEventGap(gap))
+		if err != nil {
+			t.Fatalf("Unexpected error %s\n", err.Error())
+		}
+
+		dst, _ := ioutil.TempDir("", "sort_dst_")
+		defer os.RemoveAll(dst)
+
+		err = sorter.Transfer(dst, ActionCopy, NopObserver{})
+		if err != nil {
+			t.Fatalf("Unexpected error %s\n", err.Error())
+		}
+
+		for event, count := range events {
+			err = countFiles(t, filepath.Join(dst, "2000", event), count, event)
+			if err != nil {
+				t.Errorf("%s\n", err.Error())
+			}
+		}
+	}
+}
+
+func TestSortManifest(t *testing.T) {
+	t.Parallel()
+
+	td := newTestDir(t, MethodMonth, fileNoDefault)
+	src := td.buildRoot()
+
+	defer os.RemoveAll(src)
+
+	dst, _ := ioutil.TempDir("", "sort_dst_")
+	defer os.RemoveAll(dst)
+
+	m, _ := LoadManifest(dst)
+
+	scanner := NewScanner()
+	_ = scanner.ScanDir(src, NopObserver{})
+
+	sorter, err := NewSorter(scanner, MethodMonth, WithManifest(m))
+	if err != nil {
+		t.Fatalf("Unexpected error %s\n", err.Error())
+	}
+
+	err = sorter.Transfer(dst, ActionCopy, NopObserver{})
+	if err != nil || len(sorter.TransferErrors) != 0 {
+		t.Fatalf("Expected no errors got %v err %v\n", sorter.TransferErrors, err)
+	}
+
+	check, err := m.Check(nil)
+	if err != nil || check.Verified != td.numData || len(check.Unexpected) != 0 {
+		t.Errorf("Expected %d verified and none unexpected got %d and %d err %v\n",
+			td.numData, check.Verified, len(check.Unexpected), err)
+	}
+}
+
+// testCollisionRoot returns a root with two files named x.jpg from 2020, the
+// one in b newer than the one in a.
+func testCollisionRoot(t *testing.T) (string, string, string) {
+	root, _ := ioutil.TempDir("", "collision_")
+	older := filepath.Join(root, "a", "x.jpg")
+	newer := filepath.Join(root, "b", "x.jpg")
+
+	_ = os.MkdirAll(filepath.Dir(older), 0755)
+	_ = os.MkdirAll(filepath.Dir(newer), 0755)
+
+	if copyFile(exifPath, older) != nil || copyFile("../data/diff_exif.jpg", newer) != nil {
+		t.Fatalf("Cannot build %s\n", root)
+	}
+
+	past := time.Now().Add(-time.Hour)
+	_ = os.Chtimes(older, past, past)
+
+	return root, older, newer
+}
+
+func TestSortCollisionPolicies(t *testing.T) {
+	t.Parallel()
+
+	// Which file each policy leaves at 2020/x.jpg and which it leaves out.
+	expected := map[Collision][2]int{
+		CollisionRename: {0, -1},
+		CollisionSkip:   {0, 1},
+		CollisionNewer:  {1, 0},
+	}
+
+	for collision, files := range expected {
+		root, older, newer := testCollisionRoot(t)
+		defer os.RemoveAll(root)
+
+		paths := []string{older, newer}
+
+		scanner := NewScanner()
+		_ = scanner.ScanDir(root, NopObserver{})
+
+		sorter, err := NewSorter(scanner, MethodYear, WithCollision(collision))
+		if err != nil {
+			t.Fatalf("%s: unexpected error %s\n", collision, err.Error())
+		}
+
+		dst, _ := ioutil.TempDir("", "sort_dst_")
+		defer os.RemoveAll(dst)
+
+		err = sorter.Transfer(dst, ActionCopy, NopObserver{})
+		if err != nil {
+			t.Fatalf("%s: unexpected error %s\n", collision, err.Error())
+		}
+
+		equal, _ := isEqual(paths[files[0]], filepath.Join(dst, "2020", "x.jpg"))
+		if !equal {
+			t.Errorf("%s: expected %s at x.jpg\n", collision, paths[files[0]])
+		}
+
+		switch {
+		case files[1] == -1 && len(sorter.Collisions) != 0:
+			t.Errorf("%s: expected no collisions got %v\n", collision, sorter.Collisions)
+		case files[1] != -1 && sorter.Collisions[paths[files[1]]] != paths[files[0]]:
+			t.Errorf("%s: expected %s left out got %v\n", collision, paths[files[1]], sorter.Collisions)
+		}
+	}
+
+	root, _, _ := testCollisionRoot(t)
+	defer os.RemoveAll(root)
+
+	scanner := NewScanner()
+	_ = scanner.ScanDir(root, NopObserver{})
+
+	_, err := NewSorter(scanner, MethodYear, WithCollision(CollisionFail))
+	if err == nil {
+		t.Errorf("Expected error for a collision\n")
+	}
+}
+
+func TestSortCollisionInDst(t *testing.T) {
+	t.Parallel()
+
+	root, older, _ := testCollisionRoot(t)
+	defer os.RemoveAll(root)
+
+	_ = os.Remove(filepath.Join(root, "b", "x.jpg"))
+
+	for _, collision := range []Collision{CollisionSkip, CollisionNewer} {
+		dst, _ := ioutil.TempDir("", "sort_dst_")
+		defer os.RemoveAll(dst)
+
+		// dst has another x.jpg, older than ours for newer to replace.
+		taken := filepath.Join(dst, "2020", "x.jpg")
+		_ = os.MkdirAll(filepath.Dir(taken), 0755)
+		_ = copyFile(noExifPath, taken)
+
+		past := time.Now().Add(-2 * time.Hour)
+		_ = os.Chtimes(taken, past, past)
+
+		scanner := NewScanner()
+		_ = scanner.ScanDir(root, NopObserver{})
+
+		sorter, _ := NewSorter(scanner, MethodYear, WithCollision(collision))
+
+		err := sorter.Transfer(dst, ActionCopy, NopObserver{})
+		if err != nil {
+			t.Fatalf("%s: unexpected error %s\n", collision, err.Error())
+		}
+
+		equal, _ := isEqual(older, taken)
+		if collision == CollisionSkip && (equal || sorter.Collisions[older] != taken) {
+			t.Errorf("Expected %s skipped got %v\n", older, sorter.Collisions)
+		}
+
+		if collision == CollisionNewer && (!equal || len(sorter.Replaced) != 1) {
+			t.Errorf("Expected %s replaced got %v\n", taken, sorter.Replaced)
+		}
+	}
+}
+
+func TestSortDuplicatePolicies(t *testing.T) {
+	t.Parallel()
+
+	td := newTestDir(t, MethodYear, fileNoDefault)
+	src := td.buildDuplicateWithinThisRoot()
+
+	defer os.RemoveAll(src)
+
+	dupDir, _ := ioutil.TempDir("", "duplicates_")
+	defer os.RemoveAll(dupDir)
+
+	scanner := NewScanner()
+	_ = scanner.ScanDir(src, NopObserver{})
+
+	sorter, err := NewSorter(scanner, MethodYear, WithDuplicates(DuplicateMove, dupDir))
+	if err != nil {
+		t.Fatalf("Unexpected error %s\n", err.Error())
+	}
+
+	dst, _ := ioutil.TempDir("", "sort_dst_")
+	defer os.RemoveAll(dst)
+
+	err = sorter.Transfer(dst, ActionCopy, NopObserver{})
+	if err != nil {
+		t.Fatalf("Unexpected error %s\n", err.Error())
+	}
+
+	err = countFiles(t, dupDir, td.numDuplicates, "Duplicates Dir")
+	if err != nil {
+		t.Errorf("%s\n", err.Error())
+	}
+
+	err = countFiles(t, src, td.numTotal(), "Src Copy")
+	if err != nil {
+		t.Errorf("%s\n", err.Error())
+	}
+
+	_, err = NewSorter(scanner, MethodYear, WithDuplicates(DuplicateNone, ""))
+	if err == nil {
+		t.Errorf("Expected error for an invalid policy\n")
+	}
+}
+
+// testTree returns the files below root relative to it.
+func testTree(t *testing.T, root string) []string {
+	var files []string
+
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			rel, _ := filepath.Rel(root, path)
+			files = append(files, rel)
+		}
+
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return files
+}
+
+func TestSortNaming(t *testing.T) {
+	t.Parallel()
+
+	root, older, newer := testCollisionRoot(t)
+	defer os.RemoveAll(root)
+
+	scanner := NewScanner()
+	_ = scanner.ScanDir(root, NopObserver{})
+
+	var trees [2][]string
+
+	for i := range trees {
+		dst, _ := ioutil.TempDir("", "naming_")
+		defer os.RemoveAll(dst)
+
+		sorter, err := NewSorter(scanner, MethodYear, WithNaming(NamingHash))
+		if err != nil {
+			t.Fatalf("Unexpected error %s\n", err.Error())
+		}
+
+		err = sorter.Transfer(dst, ActionCopy, NopObserver{})
+		if err != nil {
+			t.Fatalf("Unexpected error %s\n", err.Error())
+		}
+
+		trees[i] = testTree(t, dst)
+	}
+
+	if strings.Join(trees[0], ",") != strings.Join(trees[1], ",") {
+		t.Errorf("Expected the same tree got %v and %v\n", trees[0], trees[1])
+	}
+
+	// The one indexed second is named by its contents.
+	second := newer
+	if scanner.Data[newer].Before(scanner.Data[older]) {
+		second = older
+	}
+
+	name, _ := hashName(second)
+
+	expected := []string{filepath.Join("2020", "x.jpg"), filepath.Join("2020", name)}
+	if strings.Join(trees[0], ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected %v got %v\n", expected, trees[0])
+	}
+
+	_, err := NewSorter(scanner, MethodYear, WithNaming(NamingNone))
+	if err == nil {
+		t.Errorf("Expected error for an invalid naming\n")
+	}
+}
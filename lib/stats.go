@@ -0,0 +1,201 @@
+package exifsort
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SpikeReason is why a day of media looks wrong.
+type SpikeReason int
+
+const (
+	// SpikeEpoch : media dated on a day clocks start counting from, such as
+	// 1970-01-01, which is what unset clocks and zeroed timestamps give
+	SpikeEpoch SpikeReason = iota
+	// SpikeModTime : most media of the day got its time from modtime, as
+	// happens when a whole copy loses its metadata
+	SpikeModTime
+	// SpikeVolume : many times more media than a usual day
+	SpikeVolume
+	// SpikeNone : Error Value
+	SpikeNone
+)
+
+// Returns name of spike reason value (all lower case).
+func (r SpikeReason) String() string {
+	return [...]string{"epoch", "modtime", "volume", "none"}[r]
+}
+
+// MarshalText saves spike reasons by name.
+func (r SpikeReason) MarshalText() ([]byte, error) {
+	return []byte(r.String()), nil
+}
+
+// The limits of what is a spike.
+const (
+	// spikeMinFiles is the fewest media of a day that can be a modtime or
+	// volume spike.
+	spikeMinFiles = 10
+	// spikeFactor is how many times the media of the median day a volume
+	// spike has.
+	spikeFactor = 10
+)
+
+// epochDays are the days clocks start from: Unix, FAT and QuickTime.
+func epochDays() []string {
+	return []string{"1970-01-01", "1980-01-01", "1904-01-01"}
+}
+
+const dayFormat = "2006-01-02"
+
+// Spike is a day whose media likely have the wrong time.
+type Spike struct {
+	// Day is YYYY-MM-DD.
+	Day   string
+	Count int
+	// ModTime is how many of them got their time from modtime.
+	ModTime int
+	Reason  SpikeReason
+}
+
+// Stats describes a library of media.
+type Stats struct {
+	// Files is the number of media with a time.
+	Files int
+	// Bytes is the size of them all.
+	Bytes int64
+	// Years, Months, Cameras, Extensions and Sources count the media by
+	// year (YYYY), month (YYYY-MM), device, extension (lower case) and
+	// where their time came from.
+	Years      map[string]int
+	Months     map[string]int
+	Cameras    map[string]int
+	Extensions map[string]int
+	Sources    map[string]int
+	// ExifErrors is how many media times came from a fallback because their
+	// metadata could not be read.
+	ExifErrors int
+	// ScanErrors is how many files could not be scanned at all.
+	ScanErrors int
+	// Spikes are the days whose media likely have the wrong time in day
+	// order.
+	Spikes []Spike
+	// Unreadable holds the media whose size could not be read, such as
+	// files gone since a scan was saved.
+	Unreadable map[string]string
+}
+
+func isEpoch(t time.Time) bool {
+	for _, day := range epochDays() {
+		if t.Format(dayFormat) == day || t.UTC().Format(dayFormat) == day {
+			return true
+		}
+	}
+
+	return false
+}
+
+// medianDay returns the media of the median day.
+func medianDay(days map[string]int) int {
+	counts := make([]int, 0, len(days))
+
+	for _, count := range days {
+		counts = append(counts, count)
+	}
+
+	if len(counts) == 0 {
+		return 0
+	}
+
+	sort.Ints(counts)
+
+	return counts[len(counts)/2]
+}
+
+// findSpikes returns the spikes of days, the media of each day, with
+// modTimes, the media of each day that got its time from modtime, and epochs,
+// the days whose media are on an epoch.
+func findSpikes(days map[string]int, modTimes map[string]int, epochs map[string]bool) []Spike {
+	var spikes []Spike
+
+	median := medianDay(days)
+
+	for day, count := range days {
+		spike := Spike{day, count, modTimes[day], SpikeNone}
+
+		switch {
+		case epochs[day]:
+			spike.Reason = SpikeEpoch
+		case count < spikeMinFiles:
+			continue
+		case spike.ModTime*2 > count:
+			spike.Reason = SpikeModTime
+		case count > median*spikeFactor:
+			spike.Reason = SpikeVolume
+		default:
+			continue
+		}
+
+		spikes = append(spikes, spike)
+	}
+
+	sort.Slice(spikes, func(i, j int) bool { return spikes[i].Day < spikes[j].Day })
+
+	return spikes
+}
+
+// NewStats returns the stats of the media of scanner. The size of each file
+// is read from disk.
+func NewStats(scanner *Scanner) Stats {
+	stats := Stats{
+		Files:      len(scanner.Data),
+		Years:      make(map[string]int),
+		Months:     make(map[string]int),
+		Cameras:    make(map[string]int),
+		Extensions: make(map[string]int),
+		Sources:    make(map[string]int),
+		ExifErrors: len(scanner.ExifErrors),
+		ScanErrors: len(scanner.ScanErrors),
+		Unreadable: make(map[string]string),
+	}
+
+	days := make(map[string]int)
+	modTimes := make(map[string]int)
+	epochs := make(map[string]bool)
+
+	for path, t := range scanner.Data {
+		source := scanner.Source(path)
+		day := t.Format(dayFormat)
+
+		stats.Years[t.Format("2006")]++
+		stats.Months[t.Format("2006-01")]++
+		stats.Cameras[scanner.Info[path].Device()]++
+		stats.Extensions[strings.ToLower(filepath.Ext(path))]++
+		stats.Sources[source.String()]++
+
+		days[day]++
+
+		if source == TimeSourceModTime {
+			modTimes[day]++
+		}
+
+		if isEpoch(t) {
+			epochs[day] = true
+		}
+
+		info, err := os.Stat(path)
+		if err != nil {
+			stats.Unreadable[path] = err.Error()
+			continue
+		}
+
+		stats.Bytes += info.Size()
+	}
+
+	stats.Spikes = findSpikes(days, modTimes, epochs)
+
+	return stats
+}
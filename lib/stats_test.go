@@ -0,0 +1,90 @@
+package exifsort
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestStats(t *testing.T) {
+	t.Parallel()
+
+	dir, _ := ioutil.TempDir("", "stats_")
+	defer os.RemoveAll(dir)
+
+	scanner := NewScanner()
+
+	// A month of one photo a day with exif data.
+	for day := 1; day <= 30; day++ {
+		path := filepath.Join(dir, fmt.Sprintf("photo%d.jpg", day))
+		_ = ioutil.WriteFile(path, []byte("jpeg"), 0600)
+
+		scanner.Data[path] = time.Date(2020, 4, day, 12, 0, 0, 0, time.Local)
+		scanner.Info[path] = MediaInfo{Make: "Apple", Model: "iPhone 11 Pro"}
+		scanner.Sources[path] = TimeSourceExif
+	}
+
+	// A day of movies that lost their metadata, a busy day and files on the
+	// Unix epoch that are gone.
+	for i := 0; i < spikeMinFiles; i++ {
+		path := filepath.Join(dir, fmt.Sprintf("movie%d.MOV", i))
+		_ = ioutil.WriteFile(path, []byte("mov"), 0600)
+
+		scanner.Data[path] = time.Date(2021, 1, 2, 12, 0, 0, 0, time.Local)
+		scanner.ExifErrors[path] = "no moov atom"
+	}
+
+	for i := 0; i < spikeMinFiles*2; i++ {
+		path := filepath.Join(dir, fmt.Sprintf("party%d.jpg", i))
+		_ = ioutil.WriteFile(path, []byte("jpeg"), 0600)
+
+		scanner.Data[path] = time.Date(2020, 4, 11, 20, 0, 0, 0, time.Local)
+		scanner.Sources[path] = TimeSourceExif
+	}
+
+	gone := filepath.Join(dir, "gone.jpg")
+	scanner.Data[gone] = time.Unix(0, 0)
+	scanner.Sources[gone] = TimeSourceModTime
+	scanner.ScanErrors[filepath.Join(dir, "broken.jpg")] = "permission denied"
+
+	stats := NewStats(&scanner)
+
+	counts := []struct {
+		name     string
+		expected int
+		got      int
+	}{
+		{"Files", 61, stats.Files},
+		{"Bytes", 50*4 + spikeMinFiles*3, int(stats.Bytes)},
+		{"2020", 50, stats.Years["2020"]},
+		{"2020-04", 50, stats.Months["2020-04"]},
+		{"iPhone", 30, stats.Cameras["Apple iPhone 11 Pro"]},
+		{".mov", spikeMinFiles, stats.Extensions[".mov"]},
+		{"exif", 50, stats.Sources["exif"]},
+		{"modtime", spikeMinFiles + 1, stats.Sources["modtime"]},
+		{"ExifErrors", spikeMinFiles, stats.ExifErrors},
+		{"ScanErrors", 1, stats.ScanErrors},
+		{"Unreadable", 1, len(stats.Unreadable)},
+	}
+
+	for _, c := range counts {
+		if c.expected != c.got {
+			t.Errorf("%s: expected %d got %d\n", c.name, c.expected, c.got)
+		}
+	}
+
+	expected := []SpikeReason{SpikeEpoch, SpikeVolume, SpikeModTime}
+
+	if len(stats.Spikes) != len(expected) {
+		t.Fatalf("Expected %d spikes got %v\n", len(expected), stats.Spikes)
+	}
+
+	for i, spike := range stats.Spikes {
+		if spike.Reason != expected[i] {
+			t.Errorf("Expected %s got %s on %s\n", expected[i], spike.Reason, spike.Day)
+		}
+	}
+}
@@ -101,19 +101,33 @@ func (td *testdir) numTotal() int {
 	return td.numData + td.numScanError + td.numSkipped
 }
 
-func (td *testdir) incrementTimeByMethod(delta int) {
+func (td *testdir) addTimeByMethod(t time.Time, delta int) time.Time {
 	switch td.method {
 	case MethodYear:
-		td.time = td.time.AddDate(delta, 0, 0)
-	case MethodMonth:
-		td.time = td.time.AddDate(0, delta, 0)
-	case MethodDay:
-		td.time = td.time.AddDate(0, 0, delta)
+		return t.AddDate(delta, 0, 0)
+	case MethodMonth, MethodYearMonth:
+		return t.AddDate(0, delta, 0)
+	case MethodDay, MethodEvent:
+		return t.AddDate(0, 0, delta)
+	case MethodWeek:
+		return t.AddDate(0, 0, delta*daysPerWeek)
+	case MethodQuarter:
+		return t.AddDate(0, delta*monthsPerQuarter, 0)
 	case MethodNone:
-		return
+		return t
 	default:
 		td.t.Fatalf("Invalid Method %s", td.method)
 	}
+
+	return t
+}
+
+func (td *testdir) incrementTimeByMethod(delta int) {
+	if td.method == MethodNone {
+		return
+	}
+
+	td.time = td.addTimeByMethod(td.time, delta)
 	td.numTimeSpread++
 }
 
@@ -341,16 +355,32 @@ func (td *testdir) buildDuplicateWithinThisRoot() string {
 
 func (td *testdir) buildSortedDir(src string, dst string, action Action) string {
 	scanner := NewScanner()
-	_ = scanner.ScanDir(src, ioutil.Discard)
+	_ = scanner.ScanDir(src, NopObserver{})
 
 	dst, _ = ioutil.TempDir("", dst)
 
 	sorter, _ := NewSorter(scanner, td.method)
-	_ = sorter.Transfer(dst, action, ioutil.Discard)
+	_ = sorter.Transfer(dst, action, NopObserver{})
 
 	return dst
 }
 
+// The time of the first files of a testdir.
+func testTimeStart() time.Time {
+	return time.Date(2000, time.January, 1, 12, 0, 0, 0, time.Local)
+}
+
+// testTimeSpreadRange selects the middle files of buildTimeSpreadRoot.
+func (td *testdir) testTimeSpreadRange() DateRange {
+	t := testTimeStart()
+	start := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.Local)
+
+	return DateRange{
+		After:  td.addTimeByMethod(start, 10),
+		Before: td.addTimeByMethod(start, 11),
+	}
+}
+
 func newTestDir(t *testing.T, method Method, fileNo int) *testdir {
 	var td testdir
 
@@ -358,7 +388,7 @@ func newTestDir(t *testing.T, method Method, fileNo int) *testdir {
 	td.fileNo = fileNo
 	td.root, _ = ioutil.TempDir("", "root")
 	td.t = t
-	td.time = time.Date(2000, time.January, 1, 12, 0, 0, 0, time.Local)
+	td.time = testTimeStart()
 	td.method = method
 
 	return &td
@@ -0,0 +1,255 @@
+package exifsort
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Verifier is your API to audit a sorted directory. It reads the time of
+// every file again and checks it against the directory the file is in.
+//
+// It holds what it found wrong and what Fix did about it.
+type Verifier struct {
+	// Jobs is the number of files read at the same time.
+	Jobs int
+	// Extensions decides how each file is read. Nil means NewExtensions().
+	Extensions Extensions
+	// Rules leave files and directories out. Nil means NewRules().
+	Rules *Rules
+	// Method is the method the directory is sorted by, the one most of its
+	// media follows.
+	Method Method
+	// Verified is the number of media files in the right directory.
+	Verified int
+	// Misplaced maps the media in the wrong directory to where it belongs.
+	Misplaced map[string]string
+	// Unreadable holds the files we could not read a time from.
+	Unreadable map[string]string
+	// Strays are the files that are not media.
+	Strays []string
+	// Undated are the media only dated by their modtime. A copy changes
+	// it so they are neither verified nor misplaced.
+	Undated []string
+	// Moved maps the misplaced media Fix moved to where it is now.
+	Moved map[string]string
+	// Errors holds the misplaced media Fix could not move.
+	Errors map[string]string
+	// Manifest records the media Fix moves when it is not nil. It must be
+	// the manifest of the directory.
+	Manifest *Manifest
+	// Catalog records the media Fix moves when it is not nil. It must be
+	// the catalog of the directory.
+	Catalog *Catalog
+	root    string
+}
+
+// strayObserver remembers the files skipped while scanning.
+type strayObserver struct {
+	Observer
+	strays []string
+}
+
+func (o *strayObserver) Skipped(path string) {
+	if base := filepath.Base(path); base != ManifestName && base != CatalogName {
+		o.strays = append(o.strays, path)
+	}
+
+	o.Observer.Skipped(path)
+}
+
+// verifyMethod returns the method most of the media in root follows.
+func verifyMethod(root string, data map[string]time.Time) (Method, error) {
+	counts := make(map[Method]int)
+
+	for path := range data {
+		counts[mergePathValid(root, path)]++
+	}
+
+	best, bestCount, tied := MethodNone, 0, false
+
+	for _, method := range Methods() {
+		switch {
+		case counts[method] > bestCount:
+			best, bestCount, tied = method, counts[method], false
+		case counts[method] == bestCount && bestCount != 0:
+			tied = true
+		}
+	}
+
+	if best == MethodNone || tied {
+		return MethodNone, fmt.Errorf("%s is not sorted by one method", root)
+	}
+
+	return best, nil
+}
+
+// verifyPathStr returns where path with time belongs in root sorted by idx.
+// The device or place of the directory it is in is kept.
+func verifyPathStr(root string, path string, t time.Time, idx index) string {
+	dir := mergeRelDir(root, path)
+	newPath := idx.PathStr(t, filepath.Base(path))
+	newDir, base := filepath.Split(newPath)
+
+	trimmed := mergeTrimPlace(dir)
+
+	switch {
+	case trimmed != dir && mergeStrToMethod(trimmed) != MethodNone:
+		place := strings.SplitN(filepath.Base(dir), " ", 2)[1]
+		newPath = filepath.Join(filepath.Clean(newDir)+" "+place, base)
+	case mergeStrToMethod(dir) == MethodNone && mergeDirToMethod(dir) != MethodNone:
+		newPath = filepath.Join(newDir, filepath.Base(dir), base)
+	}
+
+	return filepath.Join(root, newPath)
+}
+
+// Verify reads every file in the directory and stores the media whose time is
+// outside the period of its directory in Misplaced, files without a time in
+// Unreadable, media only dated by modtime in Undated and files that are not
+// media in Strays.
+//
+// It returns an error if the directory can't be read or its media doesn't
+// mostly follow one method.
+func (v *Verifier) Verify(observer Observer) error {
+	scanner := NewScanner()
+	scanner.Jobs = v.Jobs
+	scanner.Extensions = v.Extensions
+	scanner.Rules = v.Rules
+
+	strays := &strayObserver{Observer: observer}
+
+	err := scanner.ScanDir(v.root, strays)
+	if err != nil {
+		return err
+	}
+
+	v.Strays = strays.strays
+	v.Unreadable = scanner.ScanErrors
+
+	v.Method, err = verifyMethod(v.root, scanner.Data)
+	if err != nil {
+		return err
+	}
+
+	idx, _ := newIndex(v.Method)
+	exts := extensionsOrDefault(v.Extensions)
+
+	for path, t := range scanner.Data {
+		valid := mergePathValid(v.root, path) == v.Method
+
+		_, exifErr := scanner.ExifErrors[path]
+		if valid && (exifErr || exts.Category(path) == CategoryModTime) {
+			v.Undated = append(v.Undated, path)
+			continue
+		}
+
+		start, end, ok := mergePathPeriod(v.root, path)
+		if valid && ok && !t.Before(start) && t.Before(end) {
+			v.Verified++
+			continue
+		}
+
+		v.Misplaced[path] = verifyPathStr(v.root, path, t, idx)
+	}
+
+	return nil
+}
+
+// record moves the media moved from oldPath to newPath in the manifest and
+// catalog.
+func (v *Verifier) record(oldPath string, newPath string) error {
+	if v.Manifest != nil {
+		err := v.Manifest.Moved(oldPath, newPath)
+		if err != nil {
+			return err
+		}
+	}
+
+	if v.Catalog == nil {
+		return nil
+	}
+
+	return v.Catalog.Moved(oldPath, newPath)
+}
+
+// Fix moves the Misplaced media to where it belongs and removes the
+// directories left empty. Media is renamed if its name is taken, and left
+// where it is if it is a duplicate of the file already there. Media is moved
+// in path order so it is renamed the same way every time.
+func (v *Verifier) Fix(observer Observer) error {
+	oldPaths := make([]string, 0, len(v.Misplaced))
+	for oldPath := range v.Misplaced {
+		oldPaths = append(oldPaths, oldPath)
+	}
+
+	sort.Strings(oldPaths)
+
+	for _, oldPath := range oldPaths {
+		newPath := v.Misplaced[oldPath]
+		dir := filepath.Dir(newPath)
+
+		base, err := uniqueName(oldPath, NamingCounter, func(filename string) string {
+			path := filepath.Join(dir, filename)
+			if exists(path) {
+				return path
+			}
+
+			return ""
+		})
+		if err == nil {
+			newPath = filepath.Join(dir, base)
+
+			err = os.MkdirAll(dir, 0755)
+		}
+
+		if err == nil {
+			err = moveFile(oldPath, newPath)
+		}
+
+		if err != nil {
+			v.Errors[oldPath] = err.Error()
+			observer.Error(oldPath, err)
+
+			continue
+		}
+
+		v.Moved[oldPath] = newPath
+		observer.Transferred(oldPath, newPath)
+
+		err = v.record(oldPath, newPath)
+		if err != nil {
+			v.Errors[newPath] = err.Error()
+			observer.Error(newPath, err)
+		}
+	}
+
+	_, err := RemoveEmptyDirs(v.root, oldPaths)
+
+	return err
+}
+
+// Reset clears data so Verifier can verify root.
+func (v *Verifier) Reset(root string) {
+	v.root = root
+	v.Method = MethodNone
+	v.Verified = 0
+	v.Misplaced = make(map[string]string)
+	v.Unreadable = make(map[string]string)
+	v.Strays = nil
+	v.Undated = nil
+	v.Moved = make(map[string]string)
+	v.Errors = make(map[string]string)
+}
+
+// NewVerifier returns a Verifier of the sorted directory root.
+func NewVerifier(root string) *Verifier {
+	var v Verifier
+
+	v.Reset(root)
+
+	return &v
+}
@@ -0,0 +1,140 @@
+package exifsort
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// verifyMisplace moves one exif file of the sorted dir root to 2019 and adds
+// a stray. It returns where the file was.
+func verifyMisplace(t *testing.T, root string) string {
+	var exifPath string
+
+	_ = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err == nil && strings.Contains(filepath.Base(path), "with_exif") {
+			exifPath = path
+		}
+
+		return nil
+	})
+
+	if exifPath == "" {
+		t.Fatalf("No exif file in %s\n", root)
+	}
+
+	wrongDir := filepath.Join(root, "2019", "2019_01")
+	_ = os.MkdirAll(wrongDir, 0755)
+
+	err := os.Rename(exifPath, filepath.Join(wrongDir, filepath.Base(exifPath)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = ioutil.WriteFile(filepath.Join(root, "notes.txt"), []byte("notes"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return exifPath
+}
+
+func TestVerify(t *testing.T) {
+	t.Parallel()
+
+	td := newTestDir(t, MethodMonth, fileNoDefault)
+	src := td.buildRoot()
+	sorted := td.buildSortedDir(src, "sorted_", ActionCopy)
+
+	defer os.RemoveAll(src)
+	defer os.RemoveAll(sorted)
+
+	exifPath := verifyMisplace(t, sorted)
+
+	v := NewVerifier(sorted)
+
+	err := v.Verify(NopObserver{})
+	if err != nil {
+		t.Fatalf("Unexpected error %s\n", err.Error())
+	}
+
+	if v.Method != MethodMonth {
+		t.Errorf("Expected month got %s\n", v.Method)
+	}
+
+	if len(v.Misplaced) != 1 || len(v.Strays) != 1 || len(v.Undated) != td.numExifError {
+		t.Errorf("Expected 1 misplaced, 1 stray and %d undated got %d %d %d\n",
+			td.numExifError, len(v.Misplaced), len(v.Strays), len(v.Undated))
+	}
+
+	if v.Verified != td.numData-td.numExifError-1 {
+		t.Errorf("Expected %d verified got %d\n", td.numData-td.numExifError-1, v.Verified)
+	}
+
+	for _, newPath := range v.Misplaced {
+		if newPath != exifPath {
+			t.Errorf("Expected %s to belong in %s\n", newPath, exifPath)
+		}
+	}
+
+	err = v.Fix(NopObserver{})
+	if err != nil || len(v.Moved) != 1 || len(v.Errors) != 0 {
+		t.Fatalf("Expected 1 moved got %d err %v\n", len(v.Moved), err)
+	}
+
+	if !exists(exifPath) || exists(filepath.Join(sorted, "2019")) {
+		t.Errorf("Fix did not move %s back\n", exifPath)
+	}
+
+	v.Reset(sorted)
+
+	err = v.Verify(NopObserver{})
+	if err != nil || len(v.Misplaced) != 0 {
+		t.Errorf("Expected nothing misplaced got %d err %v\n", len(v.Misplaced), err)
+	}
+}
+
+func TestVerifyPathStr(t *testing.T) {
+	root := filepath.Join("a", "sorted")
+	idx, _ := newIndex(MethodMonth)
+	when := testDate(2020, 4, 27)
+
+	expected := map[string]string{
+		filepath.Join(root, "2019", "2019_01", "x.jpg"):          filepath.Join(root, "2020", "2020_04", "x.jpg"),
+		filepath.Join(root, "2019", "2019_01 Lisbon", "x.jpg"):   filepath.Join(root, "2020", "2020_04 Lisbon", "x.jpg"),
+		filepath.Join(root, "2019", "2019_01", "Apple", "x.jpg"): filepath.Join(root, "2020", "2020_04", "Apple", "x.jpg"),
+		filepath.Join(root, "misc stuff", "x.jpg"):               filepath.Join(root, "2020", "2020_04", "x.jpg"),
+	}
+
+	for path, newPath := range expected {
+		got := verifyPathStr(root, path, when, idx)
+		if got != newPath {
+			t.Errorf("%s: expected %s got %s\n", path, newPath, got)
+		}
+	}
+}
+
+func TestVerifyBad(t *testing.T) {
+	t.Parallel()
+
+	td := newTestDir(t, MethodMonth, fileNoDefault)
+	src := td.buildRoot()
+
+	defer os.RemoveAll(src)
+
+	v := NewVerifier(src)
+
+	err := v.Verify(NopObserver{})
+	if err == nil {
+		t.Errorf("Expected error verifying an unsorted dir\n")
+	}
+
+	v.Reset(filepath.Join(src, "missing"))
+
+	err = v.Verify(NopObserver{})
+	if err == nil {
+		t.Errorf("Expected error verifying a missing dir\n")
+	}
+}